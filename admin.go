@@ -0,0 +1,68 @@
+package eidos
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// adminStatus represents the response of the status endpoint
+type adminStatus struct {
+	Filename       string    `json:"filename"`
+	Size           int64     `json:"size"`
+	LastWrite      time.Time `json:"last_write"`
+	LastRotation   time.Time `json:"last_rotation"`
+	DroppedRecords uint64    `json:"dropped_records"`
+}
+
+// AdminHandler returns a http.Handler exposing the administrative endpoints
+// of the logger. If the handler is mounted under a prefix, then it should be
+// wrapped with http.StripPrefix.
+//
+//	POST /rotate - rotates the log file, an optional "tag" query parameter
+//	               is embedded into the backup filename
+//	POST /flush  - commits the content of the log file to the disk
+//	GET  /status - returns the status of the log file in json format
+func (l *Logger) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rotate", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		if err := l.Rotate(r.URL.Query().Get("tag")); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/flush", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		if err := l.Sync(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		l.mutex.Lock()
+		status := adminStatus{
+			Filename:       l.Filename,
+			Size:           l.currentSize(),
+			LastWrite:      l.lastWrite,
+			LastRotation:   l.lastRotation,
+			DroppedRecords: l.DroppedRecords(),
+		}
+		l.mutex.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(status)
+	})
+	return mux
+}
@@ -0,0 +1,102 @@
+package eidos
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sync"
+)
+
+const (
+	// copyBufferSize represents the size of the buffers of the copies
+	// of the log files, ex- the compression and the copy truncate
+	copyBufferSize = 32 * 1024
+	// maxWriteBufferSize represents the maximum size of the buffers of
+	// the string writes, which are returned to the pool, so a large
+	// string write does not pin a large buffer in the pool
+	maxWriteBufferSize = 64 * 1024
+)
+
+var (
+	// copyBuffers holds the buffers of the copies of the log files
+	copyBuffers = sync.Pool{New: func() interface{} {
+		buffer := make([]byte, copyBufferSize)
+		return &buffer
+	}}
+	// writeBuffers holds the buffers of the string writes
+	writeBuffers = sync.Pool{New: func() interface{} { return new([]byte) }}
+	// blockBuffers holds the buffers of the compressed blocks
+	// of the parallel gzip compression
+	blockBuffers = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+	// lz4Tables holds the hash tables of the lz4 block compression
+	lz4Tables = sync.Pool{New: func() interface{} {
+		table := make([]int32, 1<<lz4HashLog)
+		return &table
+	}}
+	// snappyTables holds the hash tables of the snappy block compression
+	snappyTables = sync.Pool{New: func() interface{} {
+		table := make([]int32, 1<<snappyHashLog)
+		return &table
+	}}
+	// gzipWriters holds the gzip writers of every compression level from
+	// gzip.HuffmanOnly to gzip.BestCompression, as a gzip writer allocates
+	// the large compression state, which can be reused after a reset
+	gzipWriters [gzip.BestCompression - gzip.HuffmanOnly + 1]sync.Pool
+)
+
+// copyFileData copies the data from src to dst through a pooled buffer
+func copyFileData(dst io.Writer, src io.Reader) (int64, error) {
+	buffer := copyBuffers.Get().(*[]byte)
+	defer copyBuffers.Put(buffer)
+	return io.CopyBuffer(dst, src, *buffer)
+}
+
+// getWriteBuffer returns an empty buffer with the capacity of at least size
+func getWriteBuffer(size int) *[]byte {
+	buffer := writeBuffers.Get().(*[]byte)
+	if cap(*buffer) < size {
+		*buffer = make([]byte, 0, size)
+	}
+	return buffer
+}
+
+// putWriteBuffer returns the buffer to the pool, unless it is too large
+func putWriteBuffer(buffer *[]byte) {
+	if cap(*buffer) <= maxWriteBufferSize {
+		writeBuffers.Put(buffer)
+	}
+}
+
+// pooledGzipWriter is a gzip writer, which is returned to
+// the pool of its compression level, when it is closed
+type pooledGzipWriter struct {
+	*gzip.Writer
+	level int
+}
+
+// getGzipWriter returns a gzip writer of the compression level
+// writing to w, which is reused from the pool, if available
+func getGzipWriter(w io.Writer, level int) (*pooledGzipWriter, error) {
+	if writer, ok := gzipWriters[level-gzip.HuffmanOnly].Get().(*gzip.Writer); ok {
+		writer.Reset(w)
+		return &pooledGzipWriter{Writer: writer, level: level}, nil
+	}
+	writer, err := gzip.NewWriterLevel(w, level)
+	if err != nil {
+		return nil, err
+	}
+	return &pooledGzipWriter{Writer: writer, level: level}, nil
+}
+
+// Close writes the end of the gzip stream and returns the gzip
+// writer to the pool. The writer must not be used after the Close.
+func (z *pooledGzipWriter) Close() error {
+	if z.Writer == nil {
+		return nil
+	}
+	err := z.Writer.Close()
+	z.Writer.Reset(nil)
+	gzipWriters[z.level-gzip.HuffmanOnly].Put(z.Writer)
+	z.Writer = nil
+	return err
+}
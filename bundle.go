@@ -0,0 +1,189 @@
+package eidos
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// bundleExtension represents the extension of the bundles of the rotated log files
+const bundleExtension = ".tar"
+
+// bundling returns true if the rotated log files are bundled into tar archives
+func (o *Options) bundling() bool {
+	return o.BundleBackups > 0 || o.BundleDaily
+}
+
+// bundleSuffix returns the suffix of the bundles of the log file, ex- ".tar.gz"
+func bundleSuffix(options *Options) string {
+	if options.Compress {
+		return bundleExtension + options.compressor().Ext()
+	}
+	return bundleExtension
+}
+
+// bundleBackups bundles the rotated log files, which are not bundled yet,
+// into tar archives. Every BundleBackups rotated log files are bundled
+// into an archive, or if BundleDaily is enabled, the rotated log files
+// of a day are bundled into an archive once the day is over. The archive
+// is named after its oldest rotated log file, ex- app-2020-10-15T10-30-00.000.tar.gz
+// and it is compressed, if compression is enabled. The callback is triggered
+// with the name of the archive.
+func (l *Logger) bundleBackups() {
+	l.bundlePending()
+}
+
+// bundlePending bundles the rotated log files, which are not bundled yet,
+// like the bundleBackups. It returns the names of the archives keyed by
+// the paths of the rotated log files bundled into them.
+func (l *Logger) bundlePending() map[string]string {
+	// The rotated log files are bundled by a single thread at a time
+	l.bundleMutex.Lock()
+	defer l.bundleMutex.Unlock()
+
+	options := l.RotationOption
+	backups, err := backupFiles(l.Filename, options)
+	if err != nil {
+		return nil
+	}
+
+	codec := newBackupCodec(l.Filename, options)
+
+	// Collecting the rotated log files, which are not bundled yet,
+	// the oldest rotated log file will be the first element
+	var pending []backupFile
+	for _, f := range backups {
+		if codec.suffix(f.Name()) != bundleSuffix(options) {
+			pending = append(pending, f)
+		}
+	}
+
+	var groups [][]backupFile
+	if options.BundleDaily {
+		// Grouping the rotated log files by the day of the rotation.
+		// The rotated log files of the current day are not bundled.
+		today := options.now().In(options.location()).Format(dailyFileTimeFormat)
+		var day string
+		for _, f := range pending {
+			parsed, err := codec.parse(f.Name())
+			if err != nil || parsed.time.Format(dailyFileTimeFormat) >= today {
+				continue
+			}
+			if len(groups) == 0 || parsed.time.Format(dailyFileTimeFormat) != day {
+				day = parsed.time.Format(dailyFileTimeFormat)
+				groups = append(groups, nil)
+			}
+			groups[len(groups)-1] = append(groups[len(groups)-1], f)
+		}
+	} else {
+		// Grouping every BundleBackups rotated log files
+		for len(pending) >= options.BundleBackups {
+			groups = append(groups, pending[:options.BundleBackups])
+			pending = pending[options.BundleBackups:]
+		}
+	}
+
+	bundled := make(map[string]string)
+	for _, group := range groups {
+		bundleFileName, err := l.writeBundle(group)
+		if err != nil {
+			l.reportError(err)
+			continue
+		}
+		for _, f := range group {
+			bundled[f.path] = bundleFileName
+		}
+		event := l.rotationEvent(bundleFileName, RotationBundle, options.now())
+		if options.SynchronousCallbacks {
+			l.notifyRotation(event)
+		} else {
+			l.goTracked(func() { l.notifyRotation(event) })
+		}
+	}
+	return bundled
+}
+
+// writeBundle writes the rotated log files into a tar archive, compresses
+// the archive, if compression is enabled, and removes the rotated log files.
+// It returns the name of the archive.
+func (l *Logger) writeBundle(backups []backupFile) (string, error) {
+	options := l.RotationOption
+	oldest := backups[0]
+	bundleFileName := filepath.Join(filepath.Dir(oldest.path), newBackupCodec(l.Filename, options).stem(oldest.Name())+bundleExtension)
+
+	// The archive is written into a temporary file, which is renamed
+	// on success, so a crash never leaves behind a truncated archive
+	tempFileName := bundleFileName + tempFileExtension
+	if err := writeTar(tempFileName, backups); err != nil {
+		os.Remove(tempFileName)
+		return "", err
+	}
+	if err := os.Rename(tempFileName, bundleFileName); err != nil {
+		os.Remove(tempFileName)
+		return "", fmt.Errorf("failed to rename bundle file: %v", err)
+	}
+
+	// The bundled log files are no longer required
+	for _, f := range backups {
+		_ = os.Remove(f.path)
+		_ = os.Remove(f.path + checksumExtension)
+	}
+
+	if !options.Compress {
+		return bundleFileName, nil
+	}
+
+	// If the compression fails, the uncompressed archive is retained
+	compressedFileName := compressedFileName(l.Filename, bundleFileName, options)
+	if err := l.compressFile(bundleFileName, compressedFileName, options.compressor()); err != nil {
+		return bundleFileName, nil
+	}
+	return compressedFileName, nil
+}
+
+// writeTar writes the rotated log files into a tar archive
+func writeTar(name string, backups []backupFile) error {
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, backups[0].Mode())
+	if err != nil {
+		return fmt.Errorf("failed to open bundle file: %v", err)
+	}
+	defer file.Close()
+
+	tarWriter := tar.NewWriter(file)
+	for _, f := range backups {
+		if err := appendTar(tarWriter, f); err != nil {
+			return err
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle file: %v", err)
+	}
+	return file.Close()
+}
+
+// appendTar appends the rotated log file to the tar archive
+func appendTar(tarWriter *tar.Writer, backup backupFile) error {
+	file, err := os.Open(backup.path)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %v", err)
+	}
+	defer file.Close()
+
+	header, err := tar.FileInfoHeader(backup.FileInfo, "")
+	if err != nil {
+		return fmt.Errorf("failed to create bundle header: %v", err)
+	}
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write bundle header: %v", err)
+	}
+	n, err := copyFileData(tarWriter, io.LimitReader(file, backup.Size()))
+	if err == nil && n < backup.Size() {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write log file to bundle: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package eidos
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// CallbackOverflow represents the policy of the delivery of the rotated file
+// names to the Callback.Execute, when the callback queue is full
+type CallbackOverflow int
+
+const (
+	// CallbackBlock waits for the callback queue to have a room
+	CallbackBlock CallbackOverflow = iota
+	// CallbackDropOldest drops the oldest rotated file name in the callback queue
+	CallbackDropOldest
+	// CallbackDropNewest drops the rotated file name, which is being queued
+	CallbackDropNewest
+)
+
+// String returns the name of the callback overflow policy
+func (o CallbackOverflow) String() string {
+	switch o {
+	case CallbackBlock:
+		return "block"
+	case CallbackDropOldest:
+		return "drop-oldest"
+	case CallbackDropNewest:
+		return "drop-newest"
+	}
+	return fmt.Sprintf("CallbackOverflow(%d)", int(o))
+}
+
+// DroppedCallbacks returns the number of the rotated file names, which were
+// dropped by the CallbackOverflow policy without calling the Callback.Execute
+func (l *Logger) DroppedCallbacks() uint64 {
+	l.callbackMutex.Lock()
+	defer l.callbackMutex.Unlock()
+	return l.callbackDrops
+}
+
+// queueCallback queues the rotated file name for the Callback.Execute. If
+// the callback queue is full, then the rotated file name is delivered as
+// per the CallbackOverflow policy. The queued rotated file names are
+// awaited by the Shutdown.
+func (l *Logger) queueCallback(rotatedFileName string) {
+	// The callback daemon has exited, so the callbacks
+	// are called directly, ex- after a close
+	if l.ctx.Err() != nil {
+		l.executeCallbacks(rotatedFileName)
+		return
+	}
+
+	l.inflight.add()
+	switch l.RotationOption.CallbackOverflow {
+	case CallbackDropNewest:
+		select {
+		case l.callbackQueue <- rotatedFileName:
+		default:
+			l.dropCallback()
+		}
+	case CallbackDropOldest:
+		for {
+			select {
+			case l.callbackQueue <- rotatedFileName:
+				return
+			default:
+			}
+			// Making a room for the rotated file name, the queue
+			// may have been drained by the callback daemon meanwhile
+			select {
+			case <-l.callbackQueue:
+				l.dropCallback()
+			default:
+			}
+		}
+	default:
+		select {
+		case l.callbackQueue <- rotatedFileName:
+		case <-l.ctx.Done():
+			// The callback daemon has exited
+			l.executeCallbacks(rotatedFileName)
+			l.inflight.done()
+		}
+	}
+}
+
+// dropCallback accounts a rotated file name dropped from the callback queue
+func (l *Logger) dropCallback() {
+	l.inflight.done()
+	l.callbackMutex.Lock()
+	defer l.callbackMutex.Unlock()
+	l.callbackDrops++
+}
+
+// runCallbacks calls the Callback.Execute with the rotated file names
+// received from the callback queue, till the logger is closed or its
+// context is cancelled. The queued rotated file names are drained
+// before exiting.
+func (l *Logger) runCallbacks() {
+	for {
+		select {
+		case rotatedFileName := <-l.callbackQueue:
+			l.executeCallbacks(rotatedFileName)
+			l.inflight.done()
+		case <-l.ctx.Done():
+			for {
+				select {
+				case rotatedFileName := <-l.callbackQueue:
+					l.executeCallbacks(rotatedFileName)
+					l.inflight.done()
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// executeCallbacks calls the Callback.Execute and
+// the Callback.ExecuteContext with the rotated file name
+func (l *Logger) executeCallbacks(rotatedFileName string) {
+	l.callback.Execute(rotatedFileName)
+	l.callback.ExecuteContext(l.ctx, rotatedFileName)
+}
+
+// deliver calls the Callback.Deliver with the rotated file name, retrying
+// the failed deliveries up to the CallbackRetries times, with a backoff of
+// CallbackBackoff doubled after every failure, till the logger is closed.
+// The delivered rotated file
+// name is removed from the pending callbacks of the state file, if the
+// PersistCallbacks is enabled. An undelivered rotated file name is retained
+// in the state file, so it is delivered again after a restart.
+func (l *Logger) deliver(rotatedFileName string) {
+	backoff := l.RotationOption.CallbackBackoff
+	for attempt := 0; ; attempt++ {
+		err := l.callback.Deliver(rotatedFileName)
+		if err == nil {
+			l.updatePendingCallbacks(rotatedFileName, false)
+			return
+		}
+		if attempt >= l.RotationOption.CallbackRetries {
+			l.reportError(fmt.Errorf("failed to deliver rotated file %s: %v", rotatedFileName, err))
+			return
+		}
+
+		// The retries are abandoned, when the logger is closed
+		select {
+		case <-time.After(backoff):
+		case <-l.ctx.Done():
+			return
+		}
+		backoff *= 2
+	}
+}
+
+// updatePendingCallbacks adds or removes the rotated file name to or from
+// the pending callbacks of the state file, if PersistCallbacks is enabled
+func (l *Logger) updatePendingCallbacks(rotatedFileName string, pending bool) {
+	if !l.RotationOption.PersistCallbacks {
+		return
+	}
+	l.updateState(func(s *state) {
+		var pendingCallbacks []string
+		for _, f := range s.PendingCallbacks {
+			if f != rotatedFileName {
+				pendingCallbacks = append(pendingCallbacks, f)
+			}
+		}
+		if pending {
+			pendingCallbacks = append(pendingCallbacks, rotatedFileName)
+		}
+		s.PendingCallbacks = pendingCallbacks
+	})
+}
+
+// resumeCallbacks delivers the rotated file names, which were pending in the
+// state file, when the previous process exited. The rotated files removed
+// meanwhile, ex- by the retention, are dropped from the pending callbacks.
+func (l *Logger) resumeCallbacks() {
+	for _, rotatedFileName := range readState(l.Filename).PendingCallbacks {
+		if _, err := os.Stat(rotatedFileName); err != nil {
+			l.updatePendingCallbacks(rotatedFileName, false)
+			continue
+		}
+		rotatedFileName := rotatedFileName
+		l.goTracked(func() { l.deliver(rotatedFileName) })
+	}
+}
@@ -0,0 +1,113 @@
+// Command eidos runs the maintenance verbs of the eidos logger against the
+// log files of a service, ex- migrating the historical backups after
+// switching the compression format.
+//
+//	eidos recompress -format xz /var/log/app/app.log
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aka-achu/eidos"
+)
+
+// formats maps the names of the compression formats to the formats
+var formats = map[string]eidos.CompressionFormat{
+	eidos.Gzip.String():   eidos.Gzip,
+	eidos.LZ4.String():    eidos.LZ4,
+	eidos.Snappy.String(): eidos.Snappy,
+	eidos.XZ.String():     eidos.XZ,
+	eidos.Zip.String():    eidos.Zip,
+}
+
+// errUsage is returned for the invalid arguments, after the usage is printed
+var errUsage = errors.New("invalid arguments")
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, errUsage) {
+			fmt.Fprintln(os.Stderr, "eidos:", err)
+		}
+		os.Exit(2)
+	}
+}
+
+// run runs the verb of the args, the result of the verb is written to the
+// stdout and the usage of the verb to the stderr
+func run(args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, "usage: eidos recompress [flags] <log file>")
+		return errUsage
+	}
+	switch args[0] {
+	case "recompress":
+		return recompress(args[1:], stdout, stderr)
+	}
+	fmt.Fprintf(stderr, "eidos: unknown verb %q\n", args[0])
+	return errUsage
+}
+
+// recompress re-encodes the gzip compressed backups of the log file into the
+// requested compression format, retaining their names and modification times
+func recompress(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("recompress", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "", "compression format of the re-encoded backups: gzip, lz4, snappy, xz or zip")
+	level := flags.Int("level", 0, "gzip compression level of the re-encoded backups")
+	timeFormat := flags.String("time-format", "", "layout of the timestamps in the backup file names")
+	backupDir := flags.String("backup-dir", "", "directory of the backups, relative to the directory of the log file")
+	sequence := flags.Bool("sequence", false, "the backups are named with a sequence number, ex- app.log.1")
+	partitioned := flags.Bool("date-partitioned", false, "the backups are placed under the YYYY/MM/DD directories")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: eidos recompress [flags] <log file>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	compressionFormat, ok := formats[*format]
+	if flags.NArg() != 1 || !ok {
+		flags.Usage()
+		return errUsage
+	}
+	// The compression level is only applicable for the gzip format
+	levelSet := false
+	flags.Visit(func(f *flag.Flag) {
+		levelSet = levelSet || f.Name == "level"
+	})
+	if levelSet && compressionFormat != eidos.Gzip {
+		fmt.Fprintf(stderr, "eidos: -level is not applicable for the %v format\n", compressionFormat)
+		return errUsage
+	}
+
+	options := &eidos.Options{
+		CompressionFormat:      compressionFormat,
+		CompressionLevel:       *level,
+		BackupTimeFormat:       *timeFormat,
+		BackupDir:              *backupDir,
+		DatePartitionedBackups: *partitioned,
+		ManualRotation:         true,
+	}
+	if *sequence {
+		options.BackupNaming = eidos.SequenceNaming
+	}
+	logger, err := eidos.New(flags.Arg(0), options, &eidos.Callback{})
+	if err != nil {
+		return err
+	}
+	defer logger.Close()
+
+	// The compression is not enabled, so the logger neither compresses the
+	// uncompressed backups nor removes the temporary files of the
+	// compressions in progress of the service at the startup
+	recompressed, err := logger.Recompress()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "recompressed %d backups\n", recompressed)
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRun_Recompress(t *testing.T) {
+	// Creating a gzip compressed backup of the previous day
+	dir, _ := ioutil.TempDir("", "eidos_cmd")
+	defer os.RemoveAll(dir)
+	yesterday := time.Now().UTC().AddDate(0, 0, -1)
+	backupFileName := filepath.Join(dir, "app-"+yesterday.Format("2006-01-02T15-04-05.000")+".log")
+	var compressed bytes.Buffer
+	gzWriter := gzip.NewWriter(&compressed)
+	_, _ = gzWriter.Write([]byte("eidos\n"))
+	_ = gzWriter.Close()
+	_ = ioutil.WriteFile(backupFileName+".gz", compressed.Bytes(), 0644)
+
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"recompress", "-format", "xz", filepath.Join(dir, "app.log")}, &stdout, &stderr)
+	if err != nil || stdout.String() != "recompressed 1 backups\n" {
+		t.Fatalf("Error. Failed to recompress the backup, %v %q", err, stdout.String())
+	}
+	if _, err := os.Stat(backupFileName + ".xz"); err != nil {
+		t.Fatalf("Error. The backup should be re-encoded into the xz format, %v", err)
+	}
+	if _, err := os.Stat(backupFileName + ".gz"); !os.IsNotExist(err) {
+		t.Fatalf("Error. The gzip compressed backup should be removed")
+	}
+}
+
+func TestRun_Usage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	for _, args := range [][]string{
+		nil,
+		{"compress"},
+		{"recompress", "app.log"},
+		{"recompress", "-format", "bzip2", "app.log"},
+		{"recompress", "-format", "xz", "-level", "9", "app.log"},
+	} {
+		if err := run(args, &stdout, &stderr); err != errUsage {
+			t.Fatalf("Error. The usage should be printed for %q, %v", args, err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package eidos
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// backupCodec generates and parses the names of the rotated log files of a
+// log file, ex- app-2020-10-15T10-30-00.000-deploy.log.gz is made of the
+// prefix "app", the timestamp, the tag "deploy", the extension ".log" and
+// the compression extension ".gz". The rotation, the compression, the
+// bundling, the retention and the listing of the rotated log files share
+// the codec, so they always agree on the names of the rotated log files.
+type backupCodec struct {
+	// prefix is the base name of the log file without the extension
+	prefix string
+	// ext is the extension of the log file, ex- ".log"
+	ext     string
+	options *Options
+}
+
+// parsedBackup represents the parts of the name of a rotated log file
+type parsedBackup struct {
+	// time is the rotation time parsed from the timestamp
+	time time.Time
+	// tag is the tag and the sequence suffix, if any, following the timestamp
+	tag string
+	// suffix is the extension of the log file followed by the compression
+	// extension, if any, ex- ".log.gz", or the suffix of a bundle
+	suffix string
+}
+
+// sequence returns the collision sequence of the rotated log file, which is
+// appended to the tag, if any, for the rotations within the same timestamp,
+// ex- 2 for the tag "deploy-2" or "2". It is 0 for the first rotation.
+func (p parsedBackup) sequence() int {
+	number := p.tag
+	if index := strings.LastIndex(number, "-"); index >= 0 {
+		number = number[index+1:]
+	}
+	sequence, err := strconv.Atoi(number)
+	if err != nil || sequence < 0 {
+		return 0
+	}
+	return sequence
+}
+
+// newBackupCodec returns the codec of the rotated log files of the log file
+func newBackupCodec(file string, options *Options) backupCodec {
+	filename := filepath.Base(file)
+	ext := filepath.Ext(filename)
+	return backupCodec{prefix: filename[:len(filename)-len(ext)], ext: ext, options: options}
+}
+
+// format returns the base name of the rotated log file for the rotation time
+// and the tag. The timestamp is formatted in the timezone of the time.
+func (c backupCodec) format(t time.Time, tag string) string {
+	timeStamp := t.Format(c.options.BackupTimeFormat)
+	if tag != "" {
+		timeStamp += "-" + tag
+	}
+	return c.prefix + "-" + timeStamp + c.ext
+}
+
+// suffix returns the suffix of the rotated log file, which follows the
+// timestamp and the tag, ex- ".log", ".log.gz" or ".tar.gz" for a bundle
+func (c backupCodec) suffix(name string) string {
+	if c.options.bundling() && strings.HasSuffix(name, bundleSuffix(c.options)) {
+		return bundleSuffix(c.options)
+	}
+	for _, extension := range c.options.compressionExtensions() {
+		if strings.HasSuffix(name, c.ext+extension) {
+			return c.ext + extension
+		}
+	}
+	return c.ext
+}
+
+// stem returns the name of the rotated log file without its suffix
+func (c backupCodec) stem(name string) string {
+	return strings.TrimSuffix(name, c.suffix(name))
+}
+
+// compressed returns true if the rotated log file is compressed
+// in any of the known compression formats
+func (c backupCodec) compressed(name string) bool {
+	if c.options.BackupNaming == SequenceNaming {
+		_, extension, _ := sequenceNumber(c.prefix+c.ext, name)
+		return extension != ""
+	}
+	return c.suffix(name) != c.ext
+}
+
+// uncompressedName returns the name of the rotated log file without
+// the compression extension, ex- app-2020-10-15T10-30-00.000.log for
+// app-2020-10-15T10-30-00.000.log.gz. The bundles are never uncompressed.
+func (c backupCodec) uncompressedName(name string) string {
+	suffix := c.suffix(name)
+	if suffix == c.ext || c.options.bundling() && suffix == bundleSuffix(c.options) ||
+		!strings.HasSuffix(name, suffix) {
+		return name
+	}
+	return name[:len(name)-len(suffix)] + c.ext
+}
+
+// parse parses the name of the rotated log file. The timestamp may be
+// followed by a tag or a sequence suffix, ex- "-deploy" or "-1", so the
+// trailing "-" separated parts are removed till the timestamp can be parsed.
+// The timestamp is parsed in the timezone, which was used to generate it.
+func (c backupCodec) parse(name string) (parsedBackup, error) {
+	// The timestamp follows the prefix and a hyphen, ex- app-2020-10-15T10-30-00.000.log
+	suffix := c.suffix(name)
+	if len(name) < len(c.prefix)+1+len(suffix) || !strings.HasPrefix(name, c.prefix+"-") ||
+		!strings.HasSuffix(name, suffix) {
+		return parsedBackup{}, fmt.Errorf("invalid backup filename %q", name)
+	}
+	tagged := name[len(c.prefix)+1 : len(name)-len(suffix)]
+	timeStamp := tagged
+	for {
+		t, err := time.ParseInLocation(c.options.BackupTimeFormat, timeStamp, c.options.location())
+		if err == nil {
+			return parsedBackup{
+				time:   t,
+				tag:    strings.TrimPrefix(tagged[len(timeStamp):], "-"),
+				suffix: suffix,
+			}, nil
+		}
+		index := strings.LastIndex(timeStamp, "-")
+		if index == -1 {
+			return parsedBackup{}, err
+		}
+		timeStamp = timeStamp[:index]
+	}
+}
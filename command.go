@@ -0,0 +1,68 @@
+package eidos
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// CommandNotifier returns a Callback.Rotated function, which runs the external
+// command with the args and the name of the rotated log file as the last
+// argument, like the postrotate scripts of the logrotate. The command is
+// killed, if it does not finish within the timeout, and the combined output
+// and the error of the command are passed to the output function, if any.
+func CommandNotifier(timeout time.Duration, output func(file string, out []byte, err error), name string, args ...string) func(RotationEvent) {
+	return func(event RotationEvent) {
+		file := event.rotatedFile()
+		out, err := runCommand(timeout, name, append(append([]string{}, args...), file)...)
+		if output != nil {
+			output(file, out, err)
+		}
+	}
+}
+
+// runCommand runs the command and returns its combined output. On a timeout
+// the command is killed and it returns the output written till then along
+// with the timeout error, without waiting for the children of the command,
+// which may still hold the output pipe open.
+func runCommand(timeout time.Duration, name string, args ...string) ([]byte, error) {
+	// The output is read from a pipe owned by the runCommand, instead of the
+	// pipe of the exec.Cmd, so it can be closed on the timeout
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout, cmd.Stderr = writer, writer
+	err = cmd.Start()
+	// The write end is held only by the command and its children
+	_ = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, _ = out.ReadFrom(reader)
+		done <- cmd.Wait()
+	}()
+	select {
+	case err := <-done:
+		return out.Bytes(), err
+	case <-ctx.Done():
+		// The command is killed by the context, and closing the pipe
+		// stops the reading of the output of the children, if any
+		_ = reader.Close()
+		<-done
+		return out.Bytes(), fmt.Errorf("command timed out after %v", timeout)
+	}
+}
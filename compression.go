@@ -0,0 +1,212 @@
+package eidos
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// CompressionFormat represents the format of the compressed log files
+type CompressionFormat int
+
+const (
+	// Gzip compresses the rotated log files in the gzip format, ex- app.log.gz
+	Gzip CompressionFormat = iota
+	// LZ4 compresses the rotated log files in the lz4 frame format, ex- app.log.lz4.
+	// It is much cheaper than gzip, which suits the latency sensitive hosts.
+	LZ4
+	// Snappy compresses the rotated log files in the snappy framing format, ex- app.log.sz
+	Snappy
+	// XZ compresses the rotated log files in the xz format, ex- app.log.xz.
+	// It is slower than gzip but produces smaller files, which suits
+	// the backups destined for the long term cold storage.
+	XZ
+	// Zip compresses every rotated log file into a zip archive holding
+	// the rotated log file, ex- app.log.zip, which can be opened natively
+	// on the windows hosts.
+	Zip
+)
+
+// String returns the name of the compression format
+func (f CompressionFormat) String() string {
+	switch f {
+	case Gzip:
+		return "gzip"
+	case LZ4:
+		return "lz4"
+	case Snappy:
+		return "snappy"
+	case XZ:
+		return "xz"
+	case Zip:
+		return "zip"
+	}
+	return fmt.Sprintf("CompressionFormat(%d)", int(f))
+}
+
+// extension returns the file extension of the compression format
+func (f CompressionFormat) extension() string {
+	switch f {
+	case LZ4:
+		return ".lz4"
+	case Snappy:
+		return ".sz"
+	case XZ:
+		return ".xz"
+	case Zip:
+		return ".zip"
+	}
+	return ".gz"
+}
+
+// compressionExtensions returns the extensions of the compressed log files,
+// which are the extensions of all the built-in compression formats and of
+// the Compressor, if any. The compressed log files of all the extensions are
+// recognized as the backups, so toggling the compression or switching the
+// compression format does not orphan the previously compressed log files.
+func (o *Options) compressionExtensions() []string {
+	var extensions []string
+	if o.Compressor != nil {
+		extensions = append(extensions, o.Compressor.Ext())
+	}
+	for format := Gzip; format <= Zip; format++ {
+		extensions = append(extensions, format.extension())
+	}
+	return extensions
+}
+
+// newWriter returns a writer compressing the data into w. The header is
+// applicable for the gzip and the zip formats, the compression level and
+// the workers are only applicable for the gzip format.
+func (f CompressionFormat) newWriter(w io.Writer, header gzip.Header, compressionLevel, workers int) (io.WriteCloser, error) {
+	switch f {
+	case LZ4:
+		return newLZ4Writer(w), nil
+	case Snappy:
+		return newSnappyWriter(w), nil
+	case XZ:
+		return newXZWriter(w), nil
+	case Zip:
+		return newZipWriter(w, header)
+	}
+	if workers > 1 {
+		gzWriter := newParallelGzipWriter(w, compressionLevel, workers)
+		gzWriter.header = header
+		return gzWriter, nil
+	}
+	gzWriter, err := getGzipWriter(w, compressionLevel)
+	if err != nil {
+		return nil, err
+	}
+	gzWriter.Header = header
+	return gzWriter, nil
+}
+
+// zipWriter writes the data as the only file of a zip archive
+type zipWriter struct {
+	io.Writer
+	archive *zip.Writer
+}
+
+// newZipWriter returns a zipWriter writing the data as a file of the
+// archive named and timestamped as per the header
+func newZipWriter(w io.Writer, header gzip.Header) (*zipWriter, error) {
+	archive := zip.NewWriter(w)
+	file, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     header.Name,
+		Method:   zip.Deflate,
+		Modified: header.ModTime,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &zipWriter{Writer: file, archive: archive}, nil
+}
+
+// Close writes the central directory of the archive
+func (z *zipWriter) Close() error {
+	return z.archive.Close()
+}
+
+// Compressor compresses the rotated log files. It can be implemented
+// to plug in a custom or a proprietary compression.
+type Compressor interface {
+	// Compress compresses the source file into the destination file, which
+	// is a temporary file renamed by the logger on success. The Compress
+	// should not remove the source file, the logger removes it after the
+	// rename, so a failed compression never loses the rotated log file.
+	Compress(source, destination string) error
+	// Ext returns the extension of the compressed files, ex- ".gz"
+	Ext() string
+}
+
+// formatCompressor compresses the log files in a built-in compression format
+type formatCompressor struct {
+	format           CompressionFormat
+	compressionLevel int
+	workers          int
+}
+
+// Compress compresses the source file into the destination file
+func (c formatCompressor) Compress(source, destination string) error {
+	file, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %v", err)
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat log file: %v", err)
+	}
+
+	compressedFile, err := os.OpenFile(destination, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fileInfo.Mode())
+	if err != nil {
+		return fmt.Errorf("failed to open compressed log file: %v", err)
+	}
+	defer compressedFile.Close()
+
+	// The gzip header holds the name and the modification time of the
+	// log file, so "gunzip -N" restores them and the tooling can read
+	// the provenance without parsing the backup file name
+	header := gzip.Header{
+		Name:    filepath.Base(source),
+		ModTime: fileInfo.ModTime(),
+	}
+
+	// Using the requested compression format and level to compress the log files
+	compressWriter, err := c.format.newWriter(compressedFile, header, c.compressionLevel, c.workers)
+	if err != nil {
+		return err
+	}
+
+	if _, err := copyFileData(compressWriter, file); err != nil {
+		return err
+	}
+
+	if err := compressWriter.Close(); err != nil {
+		return err
+	}
+	return compressedFile.Close()
+}
+
+// Ext returns the extension of the compression format
+func (c formatCompressor) Ext() string {
+	return c.format.extension()
+}
+
+// compressor returns the Compressor of the options, the built-in
+// compression format is used if no Compressor is provided
+func (o *Options) compressor() Compressor {
+	if o.Compressor != nil {
+		return o.Compressor
+	}
+	return formatCompressor{
+		format:           o.CompressionFormat,
+		compressionLevel: o.CompressionLevel,
+		workers:          o.CompressionWorkers,
+	}
+}
@@ -0,0 +1,164 @@
+package eidos
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule represents a parsed standard five field cron expression
+// "minute hour day-of-month month day-of-week". Every field holds a bit set
+// of the values on which the field matches.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar indicate if the day-of-month or the day-of-week
+	// field was "*". As per cron, when both the day fields are restricted,
+	// the schedule matches if either of the fields matches.
+	domStar, dowStar bool
+	location         *time.Location
+}
+
+// cronField holds the allowed range of a cron field
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day-of-month", 1, 31},
+	{"month", 1, 12},
+	{"day-of-week", 0, 6},
+}
+
+// parseCronExpression parses a five field cron expression, the next rotation
+// instants will be calculated in the provided location
+func parseCronExpression(expression string, location *time.Location) (*cronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("invalid cron expression %q, expected %d fields", expression, len(cronFields))
+	}
+
+	var bits [5]uint64
+	for index, field := range fields {
+		b, err := parseCronField(field, cronFields[index])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q-%v", expression, err)
+		}
+		bits[index] = b
+	}
+
+	return &cronSchedule{
+		minute:   bits[0],
+		hour:     bits[1],
+		dom:      bits[2],
+		month:    bits[3],
+		dow:      bits[4],
+		domStar:  fields[2] == "*",
+		dowStar:  fields[4] == "*",
+		location: location,
+	}, nil
+}
+
+// parseCronField parses a comma separated list of "*", "value", "start-end"
+// with an optional "/step" and returns the matching values as a bit set
+func parseCronField(field string, f cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if index := strings.Index(part, "/"); index >= 0 {
+			s, err := strconv.Atoi(part[index+1:])
+			if err != nil || s <= 0 {
+				return 0, fmt.Errorf("invalid step in %s field %q", f.name, part)
+			}
+			step = s
+			part = part[:index]
+		}
+
+		start, end := f.min, f.max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if start, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid range in %s field %q", f.name, part)
+			}
+			if end, err = strconv.Atoi(bounds[1]); err != nil {
+				return 0, fmt.Errorf("invalid range in %s field %q", f.name, part)
+			}
+		default:
+			value, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value in %s field %q", f.name, part)
+			}
+			start = value
+			// A single value with a step, ex- "5/15", runs till the end of the range
+			end = value
+			if step > 1 {
+				end = f.max
+			}
+		}
+
+		max := f.max
+		// Sunday can be represented by both 0 and 7 in the day-of-week field
+		if f.name == "day-of-week" {
+			max = 7
+		}
+		if start < f.min || end > max || start > end {
+			return 0, fmt.Errorf("out of range %s field %q", f.name, part)
+		}
+
+		for value := start; value <= end; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+
+	// Folding the day-of-week value 7 into 0, both represent Sunday
+	if f.name == "day-of-week" && bits&(1<<7) != 0 {
+		bits = bits&^(1<<7) | 1
+	}
+	return bits, nil
+}
+
+// matchDay checks whether the day of the given time satisfies the
+// day-of-month and day-of-week fields
+func (c *cronSchedule) matchDay(t time.Time) bool {
+	domMatch := c.dom&(1<<uint(t.Day())) != 0
+	dowMatch := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// next returns the first instant after t which matches the cron expression
+func (c *cronSchedule) next(t time.Time) time.Time {
+	t = t.In(c.location).Truncate(time.Minute).Add(time.Minute)
+
+	// If no matching instant can be found in the next five years, then the
+	// expression can never be satisfied (ex- "0 0 30 2 *")
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, c.location)
+			continue
+		}
+		if !c.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, c.location)
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, c.location)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
@@ -0,0 +1,85 @@
+package eidos
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// writeResult is the result of a write done by the writer goroutine
+type writeResult struct {
+	n   int
+	err error
+}
+
+// deadlineWriter writes to the log file in a dedicated goroutine, so a write
+// hanging on a dying disk or a stale NFS mount fails with the ErrWriteTimeout
+// after the timeout, instead of holding the lock of the logger forever. While
+// a timed out write has not returned, the next writes fail without waiting.
+// The writer is guarded by the lock of the logger.
+type deadlineWriter struct {
+	timeout  time.Duration
+	timer    *time.Timer
+	requests chan []byte
+	results  chan writeResult
+	// buffer holds a copy of the data being written, as the data of a
+	// timed out write is reused by the caller. It is owned by the writer
+	// goroutine, while a write is in flight.
+	buffer []byte
+	// busy is true while a timed out write has not returned
+	busy bool
+}
+
+// newDeadlineWriter starts the writer goroutine of the writer w
+func newDeadlineWriter(w io.Writer, timeout time.Duration) *deadlineWriter {
+	d := &deadlineWriter{
+		timeout:  timeout,
+		timer:    time.NewTimer(timeout),
+		requests: make(chan []byte, 1),
+		results:  make(chan writeResult, 1),
+	}
+	d.timer.Stop()
+	go func() {
+		for p := range d.requests {
+			n, err := w.Write(p)
+			d.results <- writeResult{n: n, err: err}
+		}
+	}()
+	return d
+}
+
+// Write writes the data in the writer goroutine and waits for the timeout
+func (d *deadlineWriter) Write(p []byte) (int, error) {
+	if d.busy {
+		select {
+		case <-d.results:
+			// The timed out write has returned, its result was already
+			// reported to the caller of the timed out write
+			d.busy = false
+		default:
+			return 0, fmt.Errorf("%w: previous write still pending", ErrWriteTimeout)
+		}
+	}
+
+	d.buffer = append(d.buffer[:0], p...)
+	d.requests <- d.buffer
+	d.timer.Reset(d.timeout)
+	select {
+	case result := <-d.results:
+		if !d.timer.Stop() {
+			select {
+			case <-d.timer.C:
+			default:
+			}
+		}
+		return result.n, result.err
+	case <-d.timer.C:
+		d.busy = true
+		return 0, fmt.Errorf("%w: %v", ErrWriteTimeout, d.timeout)
+	}
+}
+
+// close stops the writer goroutine, once the pending write, if any, returns
+func (d *deadlineWriter) close() {
+	close(d.requests)
+}
@@ -0,0 +1,100 @@
+package eidos
+
+import (
+	"bytes"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// deduplicator collapses the consecutive identical lines written within the
+// window into a summary line, ex- "last message repeated 42 times". The lines
+// are filtered and written holding the filterMutex of the logger, so the summary
+// is written right before the next different line. The mutex is never held
+// while locking the logger, so the logger can drain the summary holding its
+// own lock, ex- while closing the log file.
+type deduplicator struct {
+	mutex  sync.Mutex
+	window time.Duration
+	// last is the last written line and start is the time it was written
+	last  []byte
+	start time.Time
+	// repeated is the number of the collapsed repetitions of the last line
+	repeated int
+}
+
+// filter appends the lines of p to out, collapsing the lines identical to
+// the last written line within the window. The summary of the collapsed lines
+// is appended before the next written line. A trailing data without any
+// newline is compared as a line.
+func (d *deduplicator) filter(out, p []byte, now time.Time) []byte {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for len(p) > 0 {
+		end := bytes.IndexByte(p, '\n') + 1
+		if end == 0 {
+			end = len(p)
+		}
+		line := p[:end]
+		p = p[end:]
+
+		if bytes.Equal(line, d.last) && now.Sub(d.start) < d.window {
+			d.repeated++
+			continue
+		}
+		out = d.summary(out)
+		out = append(out, line...)
+		d.last = append(d.last[:0], line...)
+		d.start = now
+	}
+	return out
+}
+
+// expire appends the summary of the collapsed lines to out, if the window
+// of the last written line has elapsed, so a storm of the identical lines
+// is summarized once in every window, even if nothing else is written
+func (d *deduplicator) expire(out []byte, now time.Time) []byte {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if now.Sub(d.start) < d.window {
+		return out
+	}
+	return d.summary(out)
+}
+
+// drain appends the summary of the collapsed lines to out, if any
+func (d *deduplicator) drain(out []byte) []byte {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.summary(out)
+}
+
+// summary appends the summary line of the collapsed lines to out, if any
+func (d *deduplicator) summary(out []byte) []byte {
+	if d.repeated == 0 {
+		return out
+	}
+	out = append(out, "last message repeated "...)
+	out = strconv.AppendInt(out, int64(d.repeated), 10)
+	out = append(out, " times\n"...)
+	d.repeated = 0
+	return out
+}
+
+// writeRepeated writes the summary of the collapsed lines,
+// whose window has elapsed without any different line
+func (l *Logger) writeRepeated() {
+	// The synchronous callbacks are called after unlocking the logger
+	defer l.runPendingRotations()
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.lifecycle == lifecycleClosed {
+		return
+	}
+	if summary := l.dedup.expire(nil, l.RotationOption.now()); len(summary) > 0 {
+		if _, err := l.writeLocked(summary); err != nil {
+			l.reportError(err)
+		}
+	}
+}
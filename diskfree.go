@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package eidos
+
+import "errors"
+
+func freeDiskPercent(_ string) (float64, error) {
+	return 0, errors.New("free disk space is not supported on this platform")
+}
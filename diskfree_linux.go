@@ -0,0 +1,16 @@
+package eidos
+
+import "syscall"
+
+// freeDiskPercent returns the percentage of the free
+// space of the filesystem on which the path resides
+func freeDiskPercent(path string) (float64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	if stat.Blocks == 0 {
+		return 100, nil
+	}
+	return float64(stat.Bavail) * 100 / float64(stat.Blocks), nil
+}
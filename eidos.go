@@ -1,37 +1,69 @@
 package eidos
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 )
 
-// Implements io.WriteCloser
-var _ io.WriteCloser = (*Logger)(nil)
-
-// callbackExecutor acts as a communication pipeline in between the main thread and the callback daemon thread
-var callbackExecutor chan string
+// Implements io.WriteCloser, io.StringWriter and io.ReaderFrom
+var (
+	_ io.WriteCloser  = (*Logger)(nil)
+	_ io.StringWriter = (*Logger)(nil)
+	_ io.ReaderFrom   = (*Logger)(nil)
+)
 
-// New initialized the *Logger object and run daemons
+// New initialized the *Logger object and run daemons. The options and the
+// callback are copied, so the loggers created with the same options are
+// fully independent, except the shared compression pool.
 func New(filename string, options *Options, callback *Callback) (*Logger, error) {
-	// If the callback.Execute does not contain any functions,
-	// initialize with a empty method.
-	if callback.Execute == nil {
-		callback.Execute = func(s string) {}
+	return NewWithContext(context.Background(), filename, options, callback)
+}
+
+// NewWithContext initialized the *Logger object and run daemons, which are
+// terminated when the ctx is cancelled or the logger is closed, so the
+// lifetime of the logger can be tied to the run loop of a service. The
+// logger is closed, when the ctx is cancelled.
+func NewWithContext(ctx context.Context, filename string, options *Options, callback *Callback) (*Logger, error) {
+	// Copying the options and the callback, so the defaults applied by the
+	// normalize are not shared by the loggers created with the same options
+	copiedOptions, copiedCallback := *options, *callback
+	options, callback = &copiedOptions, &copiedCallback
+	options.normalize()
+	callback.normalize()
+
+	// Checking for the valid sizes, a zero size is replaced by the default
+	if options.Size < 0 {
+		return nil, fmt.Errorf("%w %v", ErrInvalidSize, options.Size)
+	}
+	if options.SizeBytes < 0 {
+		return nil, fmt.Errorf("%w: size bytes %v", ErrInvalidSize, options.SizeBytes)
+	}
+	if options.MinSize < 0 {
+		return nil, fmt.Errorf("%w: min size %v", ErrInvalidSize, options.MinSize)
 	}
 
-	// If the options does not have any .Size value,
-	// initialize with defaultMaxSize.
-	if options.Size == 0 {
-		options.Size = defaultMaxSize
+	// Checking for a valid period, a zero period is replaced by the default
+	if options.Period < 0 {
+		return nil, fmt.Errorf("%w %v", ErrInvalidPeriod, options.Period)
 	}
 
-	// If the option does not have any .Period value,
-	// initialize with defaultMaxPeriod.
-	if options.Period == time.Duration(0) {
-		options.Period = defaultMaxPeriod
+	// Checking for the valid retention limits, the zero
+	// retention period retains the log files for ever
+	if options.RetentionPeriod < 0 {
+		return nil, fmt.Errorf("%w: retention period %v", ErrInvalidRetention, options.RetentionPeriod)
+	}
+	if options.MaxTotalSize < 0 {
+		return nil, fmt.Errorf("%w: max total size %v", ErrInvalidRetention, options.MaxTotalSize)
 	}
 
 	// If the filename is empty, then the log files will be
@@ -40,22 +72,259 @@ func New(filename string, options *Options, callback *Callback) (*Logger, error)
 		filename = filepath.Join(os.TempDir(), "eidos_logs", filepath.Base(os.Args[0])+"-eidos.log")
 	}
 
-	// Checking for a valid compression level
-	switch options.CompressionLevel {
-	case 0, 1, 9:
-		break
-	default:
-		options.CompressionLevel = 0
+	// Checking for a valid compression level, all the gzip compression
+	// levels from gzip.HuffmanOnly to gzip.BestCompression are supported
+	if options.CompressionLevel < gzip.HuffmanOnly || options.CompressionLevel > gzip.BestCompression {
+		return nil, fmt.Errorf("%w %v", ErrInvalidCompressionLevel, options.CompressionLevel)
+	}
+
+	// The schedules are evaluated in the same timezone
+	// which is used for the backup file names
+	location := options.location()
+
+	// Checking for a valid free disk space threshold
+	if options.MinFreeDiskPercent < 0 || options.MinFreeDiskPercent >= 100 {
+		return nil, fmt.Errorf("%w: min free disk percent %v", ErrInvalidOption, options.MinFreeDiskPercent)
+	}
+
+	// The backup time format should round trip, so the retention
+	// can parse the timestamps of the backup files
+	now := options.now()
+	if strings.ContainsAny(options.BackupTimeFormat, `/\`) ||
+		now.Format(options.BackupTimeFormat) == now.AddDate(1, 1, 1).Format(options.BackupTimeFormat) {
+		return nil, fmt.Errorf("%w: backup time format %q", ErrInvalidOption, options.BackupTimeFormat)
+	}
+	if _, err := time.Parse(options.BackupTimeFormat, now.Format(options.BackupTimeFormat)); err != nil {
+		return nil, fmt.Errorf("%w: backup time format %q-%v", ErrInvalidOption, options.BackupTimeFormat, err)
+	}
+
+	// Checking for a valid retention duration
+	if options.RetentionDuration < 0 {
+		return nil, fmt.Errorf("%w: retention duration %v", ErrInvalidRetention, options.RetentionDuration)
+	}
+
+	// Checking for a valid cleanup interval
+	if options.CleanupInterval < 0 {
+		return nil, fmt.Errorf("%w: cleanup interval %v", ErrInvalidRetention, options.CleanupInterval)
+	}
+
+	// Checking for a valid compression delay
+	if options.CompressAfter < 0 {
+		return nil, fmt.Errorf("%w: compress after %v", ErrInvalidOption, options.CompressAfter)
+	}
+
+	// Checking for a valid bundling of the rotated log files. The bundled
+	// log files are named after the timestamps and compressed together.
+	if options.BundleBackups < 0 {
+		return nil, fmt.Errorf("%w: bundle backups %v", ErrInvalidOption, options.BundleBackups)
+	}
+	if options.bundling() &&
+		(options.BundleBackups > 0 && options.BundleDaily || options.BackupNaming == SequenceNaming || options.CompressAfter > 0) {
+		return nil, fmt.Errorf("%w: bundled backups can not be used with daily bundles, sequence naming or compress after", ErrIncompatibleOptions)
+	}
+
+	// The rotated backups are placed under the BackupDir, so they can not be
+	// shifted in place like the sequence named backups, or be an active file.
+	// A relative BackupDir is relative to the directory of the log file.
+	if options.BackupDir != "" {
+		if options.BackupNaming == SequenceNaming || options.TimestampedActiveFile || options.DailyFile {
+			return nil, fmt.Errorf("%w: backup dir can not be used with sequence naming, timestamped active file or daily file", ErrIncompatibleOptions)
+		}
+		if !filepath.IsAbs(options.BackupDir) {
+			options.BackupDir = filepath.Join(filepath.Dir(filename), options.BackupDir)
+		}
+		if err := os.MkdirAll(options.BackupDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create backup dir-%v", err)
+		}
+	}
+
+	// The compressed backups are placed under the CompressDir, so
+	// they can not be shifted in place like the sequence named backups.
+	// A relative CompressDir is relative to the directory of the log file.
+	if options.CompressDir != "" {
+		if options.BackupNaming == SequenceNaming {
+			return nil, fmt.Errorf("%w: compress dir can not be used with sequence naming", ErrIncompatibleOptions)
+		}
+		if !filepath.IsAbs(options.CompressDir) {
+			options.CompressDir = filepath.Join(filepath.Dir(filename), options.CompressDir)
+		}
+		if err := os.MkdirAll(options.CompressDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create compress dir-%v", err)
+		}
+	}
+
+	// The streamed log file is compressed in place, so it can not be
+	// shifted, copied, reopened by name or compressed after the rotation
+	if options.StreamCompression {
+		if !options.Compress || options.CompressionFormat != Gzip || options.Compressor != nil {
+			return nil, fmt.Errorf("%w: stream compression requires compress with the gzip compression format", ErrIncompatibleOptions)
+		}
+		if options.BackupNaming == SequenceNaming || options.CopyTruncate || options.TimestampedActiveFile ||
+			options.DailyFile || options.CompressAfter > 0 || options.bundling() {
+			return nil, fmt.Errorf("%w: stream compression can not be used with sequence naming, copy truncate, timestamped active file, daily file, compress after or bundled backups", ErrIncompatibleOptions)
+		}
+	}
+
+	// The expired backups are moved into the ArchiveDir. A relative
+	// ArchiveDir is relative to the directory of the log file.
+	if options.ArchiveDir != "" {
+		if !filepath.IsAbs(options.ArchiveDir) {
+			options.ArchiveDir = filepath.Join(filepath.Dir(filename), options.ArchiveDir)
+		}
+		if err := os.MkdirAll(options.ArchiveDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create archive dir-%v", err)
+		}
+	}
+
+	// Checking for a valid number of compression workers
+	if options.CompressionWorkers < 0 {
+		return nil, fmt.Errorf("%w: compression workers %v", ErrInvalidOption, options.CompressionWorkers)
+	}
+
+	// Checking for a valid callback queue size and overflow policy
+	if options.CallbackQueueSize < 0 {
+		return nil, fmt.Errorf("%w: callback queue size %v", ErrInvalidOption, options.CallbackQueueSize)
+	}
+	if options.CallbackOverflow < CallbackBlock || options.CallbackOverflow > CallbackDropNewest {
+		return nil, fmt.Errorf("%w: callback overflow %v", ErrInvalidOption, options.CallbackOverflow)
+	}
+
+	// Checking for a valid number of callback retries and backoff
+	if options.CallbackRetries < 0 {
+		return nil, fmt.Errorf("%w: callback retries %v", ErrInvalidOption, options.CallbackRetries)
+	}
+	if options.CallbackBackoff < 0 {
+		return nil, fmt.Errorf("%w: callback backoff %v", ErrInvalidOption, options.CallbackBackoff)
+	}
+
+	// Checking for a valid write buffer size and flush interval
+	if options.BufferSize < 0 {
+		return nil, fmt.Errorf("%w: buffer size %v", ErrInvalidOption, options.BufferSize)
+	}
+	if options.FlushInterval < 0 {
+		return nil, fmt.Errorf("%w: flush interval %v", ErrInvalidOption, options.FlushInterval)
+	}
+
+	// Checking for a valid rate limit, burst, unit and policy
+	if options.RateLimit < 0 || math.IsNaN(options.RateLimit) || math.IsInf(options.RateLimit, 0) {
+		return nil, fmt.Errorf("%w: rate limit %v", ErrInvalidOption, options.RateLimit)
+	}
+	if options.RateLimitBurst < 0 {
+		return nil, fmt.Errorf("%w: rate limit burst %v", ErrInvalidOption, options.RateLimitBurst)
+	}
+	if options.RateLimitUnit < RateLimitLines || options.RateLimitUnit > RateLimitBytes {
+		return nil, fmt.Errorf("%w: rate limit unit %v", ErrInvalidOption, options.RateLimitUnit)
+	}
+	if options.RateLimitPolicy < RateLimitDrop || options.RateLimitPolicy > RateLimitBlock {
+		return nil, fmt.Errorf("%w: rate limit policy %v", ErrInvalidOption, options.RateLimitPolicy)
+	}
+
+	// Checking for the valid sampling rules
+	var lineSampler *sampler
+	if len(options.Sampling) > 0 {
+		var err error
+		if lineSampler, err = newSampler(options.Sampling); err != nil {
+			return nil, err
+		}
+	}
+
+	// Checking for a valid window of the collapsed lines
+	if options.DedupWindow < 0 {
+		return nil, fmt.Errorf("%w: dedup window %v", ErrInvalidOption, options.DedupWindow)
+	}
+
+	// Checking for a valid write timeout
+	if options.WriteTimeout < 0 {
+		return nil, fmt.Errorf("%w: write timeout %v", ErrInvalidOption, options.WriteTimeout)
+	}
+
+	// Checking for a valid compression format
+	if options.CompressionFormat < Gzip || options.CompressionFormat > Zip {
+		return nil, fmt.Errorf("%w: compression format %v", ErrInvalidOption, options.CompressionFormat)
+	}
+
+	// Checking for a valid backup naming convention
+	if options.BackupNaming != TimestampNaming && options.BackupNaming != SequenceNaming {
+		return nil, fmt.Errorf("%w: backup naming %v", ErrInvalidOption, options.BackupNaming)
+	}
+
+	// The sequence named backups are shifted in place, so they can not be partitioned
+	if options.BackupNaming == SequenceNaming && options.DatePartitionedBackups {
+		return nil, fmt.Errorf("%w: sequence named backups can not be date partitioned", ErrIncompatibleOptions)
+	}
+
+	// The timestamped active file is never renamed or truncated
+	if options.TimestampedActiveFile &&
+		(options.BackupNaming == SequenceNaming || options.DatePartitionedBackups || options.CopyTruncate) {
+		return nil, fmt.Errorf("%w: timestamped active file can not be used with sequence naming, date partitions or copy truncate", ErrIncompatibleOptions)
+	}
+
+	// The daily log file is never renamed or truncated, and it is
+	// only switched to the next day's file by the daily rotation
+	if options.DailyFile {
+		if options.BackupNaming == SequenceNaming || options.DatePartitionedBackups ||
+			options.CopyTruncate || options.TimestampedActiveFile || options.ManualRotation {
+			return nil, fmt.Errorf("%w: daily file can not be used with sequence naming, date partitions, copy truncate, timestamped active file or manual rotation", ErrIncompatibleOptions)
+		}
+	}
+
+	// Checking for a valid rotation jitter
+	if options.RotationJitter < 0 {
+		return nil, fmt.Errorf("%w: rotation jitter %v", ErrInvalidPeriod, options.RotationJitter)
+	}
+
+	// Parsing the cron expression or the rotation boundary, if provided.
+	// The cron expression takes precedence over the rotation boundary.
+	var rotationSchedule schedule
+	switch {
+	case options.Schedule != "":
+		cron, err := parseCronExpression(options.Schedule, location)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidOption, err)
+		}
+		if cron.next(options.now()).IsZero() {
+			return nil, fmt.Errorf("%w: cron expression %q never matches", ErrInvalidOption, options.Schedule)
+		}
+		rotationSchedule = cron
+	case options.RotationBoundary != NoBoundary:
+		if options.RotationBoundary < NoBoundary || options.RotationBoundary > Monthly {
+			return nil, fmt.Errorf("%w: rotation boundary %v", ErrInvalidOption, options.RotationBoundary)
+		}
+		rotationSchedule = &boundarySchedule{boundary: options.RotationBoundary, location: location}
 	}
 
 	// Initializing a Logger object
 	l := &Logger{
 		Filename:       filename,
 		RotationOption: options,
+		callback:       callback,
+		lastRotation:   options.now(),
 	}
 
-	// Initializing callbackExecutor channel
-	callbackExecutor = make(chan string)
+	// The context of the callbacks is cancelled, when the logger is closed
+	l.ctx, l.cancel = context.WithCancel(ctx)
+
+	// Initializing the callback queue of the logger
+	l.callbackQueue = make(chan string, options.CallbackQueueSize)
+	l.periodReset = make(chan struct{}, 1)
+
+	// Initializing the rate limiter of the writes, if requested
+	if options.RateLimit > 0 {
+		l.limiter = newRateLimiter(options.RateLimit, options.RateLimitBurst, options.now())
+	}
+
+	// Initializing the holding of the partial lines, if requested
+	if options.BufferPartialLines {
+		l.partial = &partialLine{}
+	}
+
+	// Initializing the sampling of the lines, if requested
+	l.sampler = lineSampler
+
+	// Initializing the collapsing of the repeated lines, if requested
+	if options.DedupWindow > 0 {
+		l.dedup = &deduplicator{window: options.DedupWindow}
+	}
 
 	// Checking the requested directory structure exist or not.
 	// if not, creating directory structure for the log files
@@ -65,104 +334,547 @@ func New(filename string, options *Options, callback *Callback) (*Logger, error)
 		}
 	}
 
-	// Initializing a rotationTicker of interval options.Period
-	l.rotationTicker = time.NewTicker(options.Period)
+	// Only one open logger of the process can write to a log file
+	if err := register(l); err != nil {
+		l.cancel()
+		return nil, err
+	}
 
-	// Running daemon go-routine for period based
-	// rotation of log files
-	go func() {
-		for {
-			select {
-			case _ = <-l.rotationTicker.C:
+	now = options.now()
+	switch {
+	case options.ManualRotation:
+	case rotationSchedule != nil:
+		// Scheduling the schedule based rotation of log files
+		l.schedule(l.jittered(&task{
+			name: "rotation",
+			next: func(_, now time.Time) time.Time { return rotationSchedule.next(now) },
+			run:  l.timedRotate,
+			due:  rotationSchedule.next(now),
+		}))
+	case !options.DisablePeriodRotation && options.PersistRotationDeadline:
+		// Scheduling the period based rotation of log
+		// files with a persisted deadline
+		l.schedule(l.jittered(l.persistedPeriodTask(options.Period)))
+	case !options.DisablePeriodRotation:
+		// Scheduling the period based rotation of log files. The
+		// period is restarted after a manual or a size based rotation.
+		l.schedule(l.jittered(&task{
+			name:    "rotation",
+			next:    every(options.Period),
+			run:     l.timedRotate,
+			restart: func(now time.Time) time.Time { return now.Add(options.Period) },
+			due:     now.Add(options.Period),
+		}))
+	}
+
+	// Delivering the rotated file names, which were pending
+	// in the state file, when the previous process exited
+	if options.PersistCallbacks {
+		l.resumeCallbacks()
+	}
+
+	// Validating the retention parameters. If the value of RetentionPeriod,
+	// RetentionDuration and MaxTotalSize is 0 then the logs files will be
+	// retained for ever.
+	if l.RotationOption.retention() > 0 || l.RotationOption.MaxTotalSize > 0 {
+		// Scheduling the cleanUpLogs once in every CleanupInterval
+		l.schedule(&task{
+			name: "retention",
+			next: every(l.RotationOption.cleanupInterval()),
+			run: func() {
+				l.cleanUpOldLogs()
+				l.saveState()
+			},
+			due: now.Add(l.RotationOption.cleanupInterval()),
+		})
+	}
+
+	// Scheduling the marker file based rotation of log files.
+	// An existing marker file does not trigger a rotation.
+	if options.RotationMarker != "" {
+		modTime := markerModTime(options.RotationMarker)
+		l.schedule(&task{
+			name: "marker",
+			next: every(markerCheckInterval),
+			run: func() {
+				fileInfo, err := os.Stat(options.RotationMarker)
+				if err != nil || !fileInfo.ModTime().After(modTime) {
+					return
+				}
+				modTime = fileInfo.ModTime()
 				l.Rotate()
-			}
-		}
-	}()
+			},
+			due: now.Add(markerCheckInterval),
+		})
+	}
 
-	// Running daemon go-routine for execution of callback method
-	// callback.Execute waits to receive data from callbackExecutor
-	// channel which is used to send rotated filename / compressed
-	// filename from the postRotation thread to daemon thread.
-	go func() {
-		for {
-			callback.Execute(<-callbackExecutor)
+	// Scheduling the compression of the rotated log
+	// files, which are older than the CompressAfter
+	if options.Compress && options.CompressAfter > 0 {
+		interval := options.CompressAfter
+		if interval > compressCheckInterval {
+			interval = compressCheckInterval
 		}
-	}()
+		l.schedule(&task{
+			name: "compression",
+			next: every(interval),
+			run:  l.compressAgedBackups,
+			due:  now.Add(interval),
+		})
+	}
 
-	// Validating the retention period parameter.
-	// If the value of RetentionPeriod is 0 then the logs files will
-	// be retained for ever.
-	if l.RotationOption.RetentionPeriod > 0 {
-		l.retentionTicker = time.NewTicker(time.Duration(l.RotationOption.RetentionPeriod) * 24 * time.Hour)
-		// Calling the cleanUpOldLogs for cleaning up existing old files.
-		go cleanUpOldLogs(filename, options.Compress, options.RetentionPeriod)
-		// Running daemon go-routine for execution of cleanUpLogs, which
-		// will be triggered by the retentionTicker
-		go func() {
-			for {
-				select {
-				case _ = <-l.retentionTicker.C:
-					cleanUpOldLogs(filename, options.Compress, options.RetentionPeriod)
+	// Scheduling the flushes of the write buffer and the compression
+	// stream of the active log file, so it can be tailed
+	if options.StreamCompression || options.BufferSize > 0 {
+		l.schedule(&task{
+			name: "flush",
+			next: every(options.FlushInterval),
+			run: func() {
+				l.mutex.Lock()
+				err := l.flush()
+				l.mutex.Unlock()
+				if err != nil {
+					l.reportError(err)
 				}
-			}
-		}()
+			},
+			due: now.Add(options.FlushInterval),
+		})
+	}
+
+	// Scheduling the summaries of the collapsed lines, whose
+	// window has elapsed without any different line
+	if options.DedupWindow > 0 {
+		l.schedule(&task{
+			name: "dedup",
+			next: every(options.DedupWindow),
+			run:  l.writeRepeated,
+			due:  now.Add(options.DedupWindow),
+		})
+	}
+
+	// Scheduling the monitoring of the free space
+	// of the filesystem of the log files
+	if options.MinFreeDiskPercent > 0 {
+		l.schedule(&task{
+			name: "disk",
+			next: every(diskCheckInterval),
+			run:  l.checkDiskSpace,
+			due:  now.Add(diskCheckInterval),
+		})
+	}
+
+	// Running the scheduler, the single daemon go-routine, which runs
+	// the scheduled tasks and the callback worker, which calls the
+	// callback.Execute with the rotated filename / compressed filename
+	// received from the postRotation thread through the callback queue.
+	l.daemons.Add(1)
+	go l.runScheduler()
+
+	// Calling the cleanUpOldLogs for cleaning up existing old files.
+	// The retention is also enforced after every rotation.
+	if l.RotationOption.retention() > 0 || l.RotationOption.MaxTotalSize > 0 {
+		go l.cleanUpOldLogs()
+	}
+
+	// Removing the temporary compressed files and compressing the
+	// uncompressed rotated log files, which are left behind, ex- by
+	// a crash in the middle of a rotation or a compression
+	if options.Compress || options.bundling() {
+		removeTempFiles(filename, options)
+		if backups, err := backupFiles(filename, options); err == nil {
+			l.goTracked(func() { l.compressBackups(backups) })
+		}
+	}
+
+	// Bundling the rotated log files, which are left behind by a previous
+	// run, ex- the daily bundles of the past days, without waiting for a
+	// full compression pool
+	if options.bundling() {
+		l.goTracked(func() { l.submitTracked(l.bundleBackups) })
 	}
 
 	return l, nil
 }
 
+// normalize applies the defaults of the zero valued options
+func (o *Options) normalize() {
+	o.clock, o.freeDiskPercent = currentTime, diskFreePercent
+	if o.Size == 0 && o.SizeBytes == 0 {
+		o.Size = defaultMaxSize
+	}
+	if o.Period == 0 {
+		o.Period = defaultMaxPeriod
+	}
+	if o.ManualRotation {
+		o.DisableSizeRotation = true
+		o.DisablePeriodRotation = true
+	}
+	if o.DailyFile {
+		o.DisableSizeRotation = true
+		if o.Schedule == "" && o.RotationBoundary == NoBoundary {
+			o.RotationBoundary = Daily
+		}
+	}
+	if o.BackupTimeFormat == "" {
+		o.BackupTimeFormat = backupTimeFormat
+		if o.DailyFile {
+			o.BackupTimeFormat = dailyFileTimeFormat
+		}
+	}
+	if o.CallbackQueueSize == 0 {
+		o.CallbackQueueSize = defaultCallbackQueueSize
+	}
+	if o.CallbackBackoff == 0 {
+		o.CallbackBackoff = defaultCallbackBackoff
+	}
+	if o.FlushInterval == 0 {
+		o.FlushInterval = defaultFlushInterval
+	}
+	if o.RateLimitBurst == 0 {
+		o.RateLimitBurst = int(math.Ceil(o.RateLimit))
+	}
+	if o.FallbackWriter == nil {
+		o.FallbackWriter = os.Stderr
+	}
+}
+
+// normalize replaces the nil callbacks with the ones without any operation
+func (c *Callback) normalize() {
+	if c.Execute == nil {
+		c.Execute = func(s string) {}
+	}
+	if c.Deliver == nil {
+		c.Deliver = func(s string) error { return nil }
+	}
+	if c.ExecuteContext == nil {
+		c.ExecuteContext = func(ctx context.Context, s string) {}
+	}
+	if c.Rotated == nil {
+		c.Rotated = func(e RotationEvent) {}
+	}
+	if c.BeforeRotate == nil {
+		c.BeforeRotate = func(s string) error { return nil }
+	}
+	if c.LowDiskSpace == nil {
+		c.LowDiskSpace = func(f float64) {}
+	}
+	if c.Checksum == nil {
+		c.Checksum = func(s string, d string) {}
+	}
+	if c.Compressed == nil {
+		c.Compressed = func(s CompressionStats) {}
+	}
+	if c.Archive == nil {
+		c.Archive = func(s string) error { return nil }
+	}
+	if c.Pruned == nil {
+		c.Pruned = func(p []PrunedBackup) {}
+	}
+	if c.OnError == nil {
+		c.OnError = func(err error) {}
+	}
+	if c.Recovered == nil {
+		c.Recovered = func(s string) {}
+	}
+	if c.Hooks == nil {
+		c.Hooks = NopHooks{}
+	}
+}
+
+// Write implements io.Writer
+// It writes the data to the log file. The data of a single Write is never
+// interleaved with the other writes, and it is never split across the
+// rotations, unless it is larger than the maximum file size and the
+// SplitOversizeWrites is enabled. If BufferPartialLines is enabled, then the
+// data after the last newline is held till its newline arrives, so a record
+// written in multiple writes stays contiguous.
 func (l *Logger) Write(p []byte) (n int, err error) {
-	l.mutex.Lock()
-	defer l.mutex.Unlock()
+	// The partial lines are held, the lines are sampled and the
+	// repeated lines are collapsed before the rate limit
+	if l.partial != nil || l.sampler != nil || l.dedup != nil {
+		return l.writeFiltered(p, l.partial)
+	}
+	return l.write(p)
+}
 
-	writeRequestLength := int64(len(p))
-	maxFileSize := l.max()
+// WriteString implements io.StringWriter
+// It writes the string like the Write, copying the string into a pooled
+// buffer instead of allocating a byte slice for every write.
+func (l *Logger) WriteString(s string) (int, error) {
+	buffer := getWriteBuffer(len(s))
+	defer putWriteBuffer(buffer)
+	*buffer = append((*buffer)[:0], s...)
+	return l.Write(*buffer)
+}
+
+// ReadFrom implements io.ReaderFrom
+// It writes the data read from r till the EOF, so io.Copy can stream a
+// large payload into the log file. The data is written in the chunks ending
+// at a newline, if any, so the size based rotations do not split the lines.
+func (l *Logger) ReadFrom(r io.Reader) (int64, error) {
+	buffer := copyBuffers.Get().(*[]byte)
+	defer copyBuffers.Put(buffer)
+
+	var written int64
+	pending := 0
+	for {
+		read, readErr := r.Read((*buffer)[pending:])
+		pending += read
+
+		// Writing the complete lines, a chunk without any newline
+		// is written in full, once the buffer is full or at the EOF
+		chunk := pending
+		if readErr == nil {
+			if index := bytes.LastIndexByte((*buffer)[:pending], '\n'); index >= 0 {
+				chunk = index + 1
+			} else if pending < len(*buffer) {
+				chunk = 0
+			}
+		}
+		if chunk > 0 {
+			n, err := l.Write((*buffer)[:chunk])
+			written += int64(n)
+			if err != nil {
+				return written, err
+			}
+			pending = copy(*buffer, (*buffer)[chunk:pending])
+		}
 
-	// If the requested write length exceeds the maximum file size then return err
-	if writeRequestLength > maxFileSize {
-		return 0,
-			fmt.Errorf(
-				"write request size %d exceed max file size %d", writeRequestLength, maxFileSize,
-			)
+		if readErr == io.EOF {
+			return written, nil
+		}
+		if readErr != nil {
+			return written, readErr
+		}
 	}
+}
 
-	// If the file pointer in the Logger object is nil then open a new/existing log file
-	if l.file == nil {
-		if err := l.openExistingOrNewFile(); err != nil {
-			return 0, err
+// WriteBatch writes the records, ex- the log entries collected by an adapter,
+// holding the lock of the logger once. The records are concatenated and
+// written to the log file in a single write, so the batch is neither
+// interleaved with the other writes nor split across the rotations. A batch
+// larger than the maximum file size is written in the multiple writes of the
+// complete records. It returns the number of the written bytes of all the
+// records and the first error, if any, while the records after a failed write
+// are still written to the log file or the FallbackWriter. The rate limit,
+// if any, admits or drops every record separately.
+func (l *Logger) WriteBatch(records [][]byte) (n int, err error) {
+	// The synchronous callbacks are called after unlocking the logger
+	defer l.runPendingRotations()
+
+	// The partial lines of the records are held, the lines are sampled and the
+	// repeated lines are collapsed, and the held and the filtered out lines are
+	// accounted as written. The records are filtered and written holding the
+	// filterMutex, like the Write.
+	if l.partial != nil || l.sampler != nil || l.dedup != nil {
+		l.filterMutex.Lock()
+		defer l.filterMutex.Unlock()
+		buffer := getWriteBuffer(0)
+		defer putWriteBuffer(buffer)
+		size := 0
+		for _, record := range records {
+			size += len(record)
 		}
+		records = l.filterRecords(records, buffer)
+		defer func() {
+			if err == nil {
+				n = size
+			}
+		}()
 	}
 
-	// If writing the requested data to the file will make the file size
-	// exceed the max allowed filesize, then rotate the current file.
-	if l.size+writeRequestLength > l.max() {
-		if err := l.rotate(); err != nil {
-			return 0, err
+	// The records are admitted by the rate limit before locking the logger
+	if l.limiter != nil {
+		admitted := make([][]byte, 0, len(records))
+		for _, record := range records {
+			if l.admit(record) {
+				admitted = append(admitted, record)
+			} else {
+				n += len(record)
+			}
 		}
+		records = admitted
 	}
 
-	// Write the requested data to the file
-	n, err = l.file.Write(p)
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// The closed logger does not reopen the log file
+	if l.lifecycle == lifecycleClosed {
+		return 0, ErrClosed
+	}
 
-	// Increase the file size by request content length
-	l.size += int64(n)
+	buffer := getWriteBuffer(0)
+	defer putWriteBuffer(buffer)
+	maxFileSize := l.max()
+	sizeRotation := !l.RotationOption.DisableSizeRotation
+	for i := 0; i < len(records); {
+		// Concatenating the records, which fit in a log file
+		*buffer = (*buffer)[:0]
+		for ; i < len(records); i++ {
+			if len(*buffer) > 0 && sizeRotation && int64(len(*buffer)+len(records[i])) > maxFileSize {
+				break
+			}
+			*buffer = append(*buffer, records[i]...)
+		}
 
+		written, writeErr := l.writeLocked(*buffer)
+		n += written
+		if writeErr != nil && err == nil {
+			err = writeErr
+		}
+	}
 	return n, err
 }
 
 // Close implements io.Closer
-// It closes current log file if it's open
+// It closes current log file if it's open. The writes and the rotations
+// of a closed logger fail with the ErrClosed, and a repeated Close is a
+// no-op, which returns nil.
 func (l *Logger) Close() error {
-	l.mutex.Lock()
-	defer l.mutex.Unlock()
-	return l.close()
+	first, err := l.markClosed()
+	if !first {
+		return nil
+	}
+
+	// Interrupting the long-running callbacks, ex- the uploads
+	l.cancel()
+
+	l.saveState()
+	return err
 }
 
 // Rotate, rotates the current file,
 // the file will be compressed if the
 // compression option is turned on.
-func (l *Logger) Rotate() error {
+// An optional tag, ex- Rotate("deploy-v1.2"), is embedded
+// into the backup filename after the timestamp.
+func (l *Logger) Rotate(tag ...string) error {
+	if err := l.callback.BeforeRotate(l.Filename); err != nil {
+		return fmt.Errorf("rotation vetoed-%v", err)
+	}
+
+	// The synchronous callbacks are called after unlocking the logger
+	defer l.runPendingRotations()
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.lifecycle == lifecycleClosed {
+		return ErrClosed
+	}
+	return l.rotateTagged(strings.Join(tag, "-"), RotationManual)
+}
+
+// OnRotate registers the callback, which will be called with the rotated/compressed
+// file name, when the log file is being rotated, along with the Callback.Execute
+// and the previously registered callbacks, ex- an uploader, a metrics emitter
+// and a notification hook. The callbacks are called in the order of the registration.
+func (l *Logger) OnRotate(callback func(string)) {
+	l.callbackMutex.Lock()
+	defer l.callbackMutex.Unlock()
+	l.rotateCallbacks = append(l.rotateCallbacks, callback)
+}
+
+// Reopen, closes the current file and reopens the file with the same name
+// without renaming it. It is used to coexist with the external log rotation
+// tools (ex- logrotate) which move the log file out from under the logger.
+func (l *Logger) Reopen() error {
+	// The synchronous callbacks are called after unlocking the logger
+	defer l.runPendingRotations()
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
-	return l.rotate()
+	if l.lifecycle == lifecycleClosed {
+		return ErrClosed
+	}
+	if err := l.close(); err != nil {
+		return err
+	}
+	return l.openExistingOrNewFile()
+}
+
+// Sync, writes the buffered logs, flushes the compression stream, if any, and
+// commits the content of the current log file to the disk, so the applications
+// can guarantee the durability of the logs at the checkpoints. It implements
+// the zapcore.WriteSyncer along with the Write.
+func (l *Logger) Sync() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.sync()
+}
+
+// Flush, is an alias of the Sync for the writers expecting a Flush method.
+// It writes the buffered logs, if any, to the log file along with the sync.
+func (l *Logger) Flush() error {
+	return l.Sync()
+}
+
+// RotateWithResult, rotates the current file and returns the rotated filename.
+// Unlike Rotate, the compression and the bundling are done synchronously, so
+// the returned file is the final (compressed or bundled, if enabled) rotated
+// file. The callback is still triggered with the same filename. An empty
+// filename is returned if there was no log file to rotate.
+func (l *Logger) RotateWithResult() (string, error) {
+	if err := l.callback.BeforeRotate(l.Filename); err != nil {
+		return "", fmt.Errorf("rotation vetoed-%v", err)
+	}
+
+	start := l.RotationOption.now()
+	l.mutex.Lock()
+	if l.lifecycle == lifecycleClosed {
+		l.mutex.Unlock()
+		return "", ErrClosed
+	}
+	backupFileName, err := l.rotateWithResult()
+	l.mutex.Unlock()
+
+	if backupFileName == "" {
+		return "", err
+	}
+	l.resetPeriod()
+
+	// Compressing or bundling outside the lock, so the writes are not blocked
+	return l.finishRotation(l.rotationEvent(backupFileName, RotationManual, start)), err
+}
+
+// HandleSignals installs a signal handler which rotates the log file on
+// receiving any of the requested signals. If no signal is provided, then
+// the log file is rotated on SIGHUP, which matches the classic logrotate
+// "kill -HUP" workflow.
+func (l *Logger) HandleSignals(sig ...os.Signal) {
+	l.handleSignals(sig, func() error { return l.Rotate() })
+}
+
+// HandleReopenSignals installs a signal handler which reopens the log file on
+// receiving any of the requested signals. If no signal is provided, then the
+// log file is reopened on SIGHUP, so an external tool (ex- logrotate) can move
+// the log file and send a "kill -HUP" to make the logger write to a new file.
+func (l *Logger) HandleReopenSignals(sig ...os.Signal) {
+	l.handleSignals(sig, l.Reopen)
+}
+
+// handleSignals runs the handle on receiving any of the signals, SIGHUP by
+// default, till the logger is closed. The failures are reported to the
+// Callback.OnError, as they can not be returned to the sender of the signal.
+func (l *Logger) handleSignals(sig []os.Signal, handle func() error) {
+	if len(sig) == 0 {
+		sig = []os.Signal{syscall.SIGHUP}
+	}
+
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, sig...)
+
+	// Running daemon go-routine for signal based
+	// rotation or reopening of log files
+	go func() {
+		defer signal.Stop(signalCh)
+		for {
+			select {
+			case <-signalCh:
+				if err := handle(); err != nil {
+					l.reportError(err)
+				}
+			case <-l.ctx.Done():
+				return
+			}
+		}
+	}()
 }
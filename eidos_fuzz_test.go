@@ -0,0 +1,119 @@
+//go:build go1.18
+// +build go1.18
+
+package eidos
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func FuzzBackupCodec(f *testing.F) {
+	f.Add(uint32(1602757800), uint16(0), "deploy", false)
+	f.Add(uint32(0), uint16(999), "", true)
+	f.Add(uint32(4294967295), uint16(1), "v1.2-1", true)
+	f.Add(uint32(86400), uint16(500), "-", false)
+
+	options := &Options{BackupTimeFormat: backupTimeFormat}
+	codec := newBackupCodec("app.log", options)
+	f.Fuzz(func(t *testing.T, seconds uint32, millis uint16, tag string, compress bool) {
+		rotationTime := time.Unix(int64(seconds), int64(millis%1000)*int64(time.Millisecond)).UTC()
+		tag = sanitizeTag(tag)
+		name := codec.format(rotationTime, tag)
+		suffix := ".log"
+		if compress {
+			name += Gzip.extension()
+			suffix += Gzip.extension()
+		}
+
+		// The generated names round trip through the parsing
+		parsed, err := codec.parse(name)
+		if err != nil {
+			t.Fatalf("failed to parse the name %q-%v", name, err)
+		}
+		if !parsed.time.Equal(rotationTime) || parsed.tag != tag || parsed.suffix != suffix {
+			t.Fatalf("invalid parts %+v of the name %q", parsed, name)
+		}
+		if codec.compressed(name) != compress || codec.uncompressedName(name) != codec.format(rotationTime, tag) {
+			t.Fatalf("invalid compression of the name %q", name)
+		}
+
+		// An arbitrary name never panics the parsing
+		_, _ = codec.parse(tag)
+	})
+}
+
+func FuzzLZ4Writer(f *testing.F) {
+	f.Add([]byte(""))
+	f.Add([]byte("eidos\n"))
+	f.Add([]byte(strings.Repeat("eidos rotates the log files\n", 100)))
+	f.Add([]byte("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaabcdabcdabcdabcdabcd"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var compressed bytes.Buffer
+		writer := newLZ4Writer(&compressed)
+		_, _ = writer.Write(data)
+		if err := writer.Close(); err != nil {
+			t.Fatalf("failed to close the lz4 writer-%v", err)
+		}
+
+		// The frames round trip through the decoder of the format description
+		decoded, err := lz4DecodeFrame(compressed.Bytes())
+		if err != nil || !bytes.Equal(decoded, data) {
+			t.Fatalf("failed to decode the lz4 frame of %q-%v", data, err)
+		}
+	})
+}
+
+func FuzzSnappyWriter(f *testing.F) {
+	f.Add([]byte(""))
+	f.Add([]byte("eidos\n"))
+	f.Add([]byte(strings.Repeat("eidos rotates the log files\n", 100)))
+	f.Add([]byte("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaabcdabcdabcdabcdabcd"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var compressed bytes.Buffer
+		writer := newSnappyWriter(&compressed)
+		_, _ = writer.Write(data)
+		if err := writer.Close(); err != nil {
+			t.Fatalf("failed to close the snappy writer-%v", err)
+		}
+
+		// The streams round trip through the decoder of the format description
+		decoded, err := snappyDecodeStream(compressed.Bytes())
+		if err != nil || !bytes.Equal(decoded, data) {
+			t.Fatalf("failed to decode the snappy stream of %q-%v", data, err)
+		}
+	})
+}
+
+func FuzzXZWriter(f *testing.F) {
+	xz, err := exec.LookPath("xz")
+	if err != nil {
+		f.Skip("xz is not installed")
+	}
+	f.Add([]byte(""))
+	f.Add([]byte("eidos\n"))
+	f.Add([]byte(strings.Repeat("eidos rotates the log files\n", 100)))
+	f.Add([]byte("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaabcdabcdabcdabcdabcd"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var compressed bytes.Buffer
+		writer := newXZWriter(&compressed)
+		_, _ = writer.Write(data)
+		if err := writer.Close(); err != nil {
+			t.Fatalf("failed to close the xz writer-%v", err)
+		}
+
+		// The streams round trip through the xz tool
+		cmd := exec.Command(xz, "--decompress", "--stdout")
+		cmd.Stdin = &compressed
+		decompressed, err := cmd.Output()
+		if err != nil || !bytes.Equal(decompressed, data) {
+			t.Fatalf("failed to decompress the xz stream of %q-%v", data, err)
+		}
+	})
+}
@@ -1,13 +1,32 @@
 package eidos
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
 	"log"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -24,6 +43,16 @@ func clean(dir string) error {
 	return os.RemoveAll(dir)
 }
 
+// scheduled reports whether the task is scheduled by the logger
+func scheduled(l *Logger, name string) bool {
+	for _, t := range l.tasks {
+		if t.name == name {
+			return true
+		}
+	}
+	return false
+}
+
 func randStringBytes(n int) string {
 	var letterBytes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 	b := make([]byte, n)
@@ -33,13 +62,31 @@ func randStringBytes(n int) string {
 	return string(b)
 }
 
+// freezeTime freezes the clock of the loggers created after it, till the
+// returned restore is called. The returned advance moves the clock forward.
+func freezeTime() (advance func(time.Duration), restore func()) {
+	var clockMutex sync.Mutex
+	now := time.Now()
+	currentTime = func() time.Time {
+		clockMutex.Lock()
+		defer clockMutex.Unlock()
+		return now
+	}
+	advance = func(d time.Duration) {
+		clockMutex.Lock()
+		defer clockMutex.Unlock()
+		now = now.Add(d)
+	}
+	return advance, func() { currentTime = time.Now }
+}
+
 func TestNewEmpty(t *testing.T) {
 	// test for default value initialization
 	logger, err := New("", &Options{}, &Callback{})
 
 	defer func() {
 		// Closing the logger to clean up the log directory
-		_ = logger.close()
+		_ = logger.Close()
 		// Cleaning up the log directory
 		_ = clean(filepath.Dir(logger.Filename))
 	}()
@@ -87,7 +134,7 @@ func TestNew(t *testing.T) {
 		Period:           24 * 7 * time.Hour,
 		RetentionPeriod:  30,
 		Compress:         true,
-		CompressionLevel: 100, // It should be initialized to 1
+		CompressionLevel: 5,
 		LocalTime:        true,
 	}, &Callback{
 		Execute: func(s string) {
@@ -97,7 +144,7 @@ func TestNew(t *testing.T) {
 
 	defer func() {
 		// Closing the logger to clean up the log directory
-		_ = logger.close()
+		_ = logger.Close()
 		// Cleaning up the log directory
 		_ = clean(filepath.Dir(logger.Filename))
 	}()
@@ -111,11 +158,23 @@ func TestNew(t *testing.T) {
 
 	equals(
 		logger.RotationOption.CompressionLevel,
-		0,
+		5,
 		t,
 		"Failed to validate the *Logger.RotationOption.CompressionLevel value",
 	)
 
+	// An invalid compression level should be rejected
+	_, err = New("var/log/myapp.sample.log", &Options{
+		Compress:         true,
+		CompressionLevel: 100,
+	}, &Callback{})
+	equals(
+		err != nil,
+		true,
+		t,
+		"Error. An invalid compression level should be rejected",
+	)
+
 	// Checking the creation of the default log directory path
 	_, err = os.Stat(filepath.Dir(logger.Filename))
 	equals(
@@ -135,6 +194,287 @@ func TestLogger_Close(t *testing.T) {
 	equals(logger.Close(), nil, t, "Failed to close the Logger")
 }
 
+func TestNew_FileInUse(t *testing.T) {
+	logger, _ := New("var/log/in-use.log", &Options{}, &Callback{})
+	defer func() {
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// The same log file is rejected, even when named by the absolute path
+	abs, _ := filepath.Abs(logger.Filename)
+	_, err := New(abs, &Options{}, &Callback{})
+	equals(
+		errors.Is(err, ErrFileInUse),
+		true,
+		t,
+		"Error. The log file of an open logger should be rejected",
+	)
+
+	// The log file can be reused after the logger is closed
+	equals(logger.Close(), nil, t, "Failed to close the Logger")
+	reopened, err := New(abs, &Options{}, &Callback{})
+	equals(err, nil, t, "Error. The log file of a closed logger should be accepted")
+	_ = reopened.Close()
+}
+
+func TestLogger_Write_FallbackWriter(t *testing.T) {
+	var fallback bytes.Buffer
+	var errCh = make(chan error, 1)
+	var recoveredCh = make(chan string, 1)
+	logger, _ := New("", &Options{
+		FallbackWriter: &fallback,
+		ManualRotation: true,
+	}, &Callback{
+		OnError: func(err error) {
+			errCh <- err
+		},
+		Recovered: func(s string) {
+			recoveredCh <- s
+		},
+	})
+	defer func() {
+		// Closing the log file and cleaning up the log directory
+		_ = logger.Close()
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	_, _ = logger.Write([]byte(randStringBytes(1024)))
+
+	// Closing the log file under the logger makes the writes fail
+	_ = logger.file.Close()
+	data := randStringBytes(1024)
+	_, err := logger.Write([]byte(data))
+	equals(err != nil, true, t, "Error. The write to the closed log file should fail")
+	equals(
+		fallback.String(),
+		data,
+		t,
+		"Error. The failed write should be written to the fallback writer",
+	)
+	equals(
+		errors.Is(<-errCh, ErrWriteFallback),
+		true,
+		t,
+		"Error. The switch to the fallback writer should be reported",
+	)
+
+	// The log file is reopened on the next write
+	logger.file = nil
+	_, err = logger.Write([]byte(data))
+	equals(err, nil, t, "Error. The write to the reopened log file should not fail")
+	equals(
+		<-recoveredCh,
+		logger.Filename,
+		t,
+		"Error. The switch back to the log file should be reported",
+	)
+	equals(
+		fallback.Len(),
+		len(data),
+		t,
+		"Error. The successful write should not be written to the fallback writer",
+	)
+}
+
+// blockingWriter blocks the writes till it is released
+type blockingWriter struct {
+	release chan struct{}
+}
+
+func (w blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return len(p), nil
+}
+
+func TestLogger_Write_Timeout(t *testing.T) {
+	var fallback bytes.Buffer
+	var errCh = make(chan error, 1)
+	var recoveredCh = make(chan string, 1)
+	logger, _ := New("", &Options{
+		FallbackWriter: &fallback,
+		WriteTimeout:   50 * time.Millisecond,
+	}, &Callback{
+		OnError: func(err error) {
+			errCh <- err
+		},
+		Recovered: func(s string) {
+			recoveredCh <- s
+		},
+	})
+	defer func() {
+		// Closing the log file and cleaning up the log directory
+		_ = logger.Close()
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	_, _ = logger.Write([]byte(randStringBytes(1024)))
+
+	// Hanging the writes to the log file
+	writer := blockingWriter{release: make(chan struct{})}
+	logger.mutex.Lock()
+	logger.deadline.close()
+	logger.deadline = newDeadlineWriter(writer, logger.RotationOption.WriteTimeout)
+	logger.mutex.Unlock()
+
+	start := time.Now()
+	data := randStringBytes(1024)
+	_, err := logger.Write([]byte(data))
+	equals(
+		errors.Is(err, ErrWriteTimeout) && time.Since(start) >= 50*time.Millisecond,
+		true,
+		t,
+		"Error. The hanging write should time out",
+	)
+	equals(
+		fallback.String(),
+		data,
+		t,
+		"Error. The timed out write should be written to the fallback writer",
+	)
+	equals(
+		errors.Is(<-errCh, ErrWriteFallback),
+		true,
+		t,
+		"Error. The switch to the fallback writer should be reported",
+	)
+
+	// The writes fail without waiting, till the timed out write returns
+	start = time.Now()
+	_, err = logger.Write([]byte(data))
+	equals(
+		errors.Is(err, ErrWriteTimeout) && time.Since(start) < 50*time.Millisecond,
+		true,
+		t,
+		"Error. The write behind the hanging write should fail without waiting",
+	)
+
+	// The writes go back to the log file, once the timed out write returns
+	close(writer.release)
+	time.Sleep(10 * time.Millisecond)
+	_, err = logger.Write([]byte(data))
+	equals(err, nil, t, "Error. The write after the hanging write should not fail")
+	equals(
+		<-recoveredCh,
+		logger.Filename,
+		t,
+		"Error. The switch back to the log file should be reported",
+	)
+}
+
+func TestLogger_Write_LogDirRemoved(t *testing.T) {
+	var errCh = make(chan error, 1)
+	logger, _ := New("", &Options{}, &Callback{
+		OnError: func(err error) {
+			errCh <- err
+		},
+	})
+	defer func() {
+		// Closing the log file and cleaning up the log directory
+		_ = logger.Close()
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	_, _ = logger.Write([]byte(randStringBytes(1024)))
+	_ = clean(filepath.Dir(logger.Filename))
+
+	// The directory is checked at most once in the fileCheckInterval
+	time.Sleep(fileCheckInterval)
+	data := randStringBytes(1024)
+	_, err := logger.Write([]byte(data))
+	equals(err, nil, t, "Error. The write after removing the log directory should not fail")
+	equals(
+		errors.Is(<-errCh, ErrLogDirRemoved),
+		true,
+		t,
+		"Error. The removal of the log directory should be reported",
+	)
+
+	content, _ := ioutil.ReadFile(logger.Filename)
+	equals(
+		string(content),
+		data,
+		t,
+		"Error. The log file should be reopened in the recreated directory",
+	)
+}
+
+func TestLogger_Rotate_Sync(t *testing.T) {
+	for _, disabled := range []bool{false, true} {
+		logger, _ := New("", &Options{
+			BackupDir:           "archive",
+			DisableRotationSync: disabled,
+			ManualRotation:      true,
+		}, &Callback{})
+
+		data := randStringBytes(1024)
+		_, _ = logger.Write([]byte(data))
+		backup, err := logger.RotateWithResult()
+		equals(err, nil, t, "Error. Failed to rotate the log file")
+
+		// The backup in the other directory should hold the whole old file
+		content, _ := ioutil.ReadFile(backup)
+		equals(
+			string(content),
+			data,
+			t,
+			"Error. The backup should contain the content of the rotated file",
+		)
+		equals(
+			filepath.Dir(backup) != filepath.Dir(logger.Filename),
+			true,
+			t,
+			"Error. The backup should be placed under the backup directory",
+		)
+
+		_ = logger.Close()
+		_ = clean(filepath.Dir(logger.Filename))
+	}
+}
+
+func TestLogger_Write_After_Close(t *testing.T) {
+	logger, _ := New("", &Options{}, &Callback{})
+	defer func() {
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	_, _ = logger.Write([]byte(randStringBytes(1024)))
+	equals(logger.Close(), nil, t, "Failed to close the Logger")
+	_ = os.Remove(logger.Filename)
+
+	// The closed logger should not reopen the log file
+	_, err := logger.Write([]byte(randStringBytes(1024)))
+	equals(
+		err,
+		ErrClosed,
+		t,
+		"Error. The write to the closed logger should fail",
+	)
+	_, err = os.Stat(logger.Filename)
+	equals(
+		os.IsNotExist(err),
+		true,
+		t,
+		"Error. The closed logger should not reopen the log file",
+	)
+	equals(
+		logger.Rotate(),
+		ErrClosed,
+		t,
+		"Error. The rotation of the closed logger should fail",
+	)
+
+	// Closing the closed logger should be a no-op
+	equals(logger.Close(), nil, t, "Error. The repeated close should not fail")
+	equals(
+		logger.Shutdown(context.Background()),
+		nil,
+		t,
+		"Error. The shutdown of the closed logger should not fail",
+	)
+}
+
 // Test for max length exceeding write request
 func TestLogger_Write_Max(t *testing.T) {
 	logger, _ := New("", &Options{
@@ -143,7 +483,7 @@ func TestLogger_Write_Max(t *testing.T) {
 
 	defer func() {
 		// Closing the logger to clean up the log directory
-		_ = logger.close()
+		_ = logger.Close()
 		// Cleaning up the log directory
 		_ = clean(filepath.Dir(logger.Filename))
 	}()
@@ -171,7 +511,7 @@ func TestLogger_Write_New_Existing(t *testing.T) {
 
 	defer func() {
 		// Closing the logger to clean up the log directory
-		_ = logger.close()
+		_ = logger.Close()
 		// Cleaning up the log directory
 		_ = clean(filepath.Dir(logger.Filename))
 	}()
@@ -199,7 +539,7 @@ func TestLogger_Write_New_Existing(t *testing.T) {
 		"Error. The file size does not match to the expected file size",
 	)
 	// Closing the logger to reinitialize the object
-	_ = logger.close()
+	_ = logger.Close()
 
 	// Repeating the same steps for validating opening of the same log file
 	logger, _ = New("", &Options{
@@ -241,7 +581,7 @@ func TestLogger_Rotate_Auto_Size(t *testing.T) {
 
 	defer func() {
 		// Closing the logger to clean up the log directory
-		_ = logger.close()
+		_ = logger.Close()
 		// Cleaning up the log directory
 		_ = clean(filepath.Dir(logger.Filename))
 	}()
@@ -292,7 +632,7 @@ func TestLogger_Rotate_Manual(t *testing.T) {
 
 	defer func() {
 		// Closing the logger to clean up the log directory
-		_ = logger.close()
+		_ = logger.Close()
 		// Cleaning up the log directory
 		_ = clean(filepath.Dir(logger.Filename))
 	}()
@@ -344,7 +684,7 @@ func TestLogger_Rotate_Auto_Size_Compress(t *testing.T) {
 
 	defer func() {
 		// Closing the logger to clean up the log directory
-		_ = logger.close()
+		_ = logger.Close()
 		// Cleaning up the log directory
 		_ = clean(filepath.Dir(logger.Filename))
 	}()
@@ -393,7 +733,7 @@ func TestLogger_Rotate_Manual_Compress(t *testing.T) {
 
 	defer func() {
 		// Closing the logger to clean up the log directory
-		_ = logger.close()
+		_ = logger.Close()
 		// Cleaning up the log directory
 		_ = clean(filepath.Dir(logger.Filename))
 	}()
@@ -487,7 +827,7 @@ func TestLogger_Retention_Compressed(t *testing.T) {
 
 	defer func() {
 		// Closing the logger to clean up the log directory
-		_ = logger.close()
+		_ = logger.Close()
 		// Cleaning up the log directory
 		_ = clean(filepath.Dir(logger.Filename))
 	}()
@@ -599,7 +939,7 @@ func TestLogger_Retention_UnCompressed(t *testing.T) {
 
 	defer func() {
 		// Closing the logger to clean up the log directory
-		_ = logger.close()
+		_ = logger.Close()
 		// Cleaning up the log directory
 		_ = clean(filepath.Dir(logger.Filename))
 	}()
@@ -652,43 +992,6338 @@ func TestLogger_Retention_UnCompressed(t *testing.T) {
 	)
 }
 
-func TestLogger_Rotate_Auto_Period_Compress(t *testing.T) {
+func TestLogger_Retention_Duration(t *testing.T) {
 
-	var rotateCh = make(chan string, 10)
+	dir := filepath.Join(os.TempDir(), "eidos_logs")
+	_ = os.MkdirAll(dir, 0755)
 
-	go func() {
-		for file := range rotateCh {
-			_, err := os.Stat(file)
-			equals(
-				os.IsNotExist(err),
-				false,
-				t,
-				"Error. The rotated compressed log file should be present",
-			)
-		}
+	// Creating the fake day old and two days old log files
+	var files []string
+	for _, age := range []time.Duration{24 * time.Hour, 48 * time.Hour} {
+		file := filepath.Join(
+			dir,
+			fmt.Sprintf("%s-eidos-%s.log", filepath.Base(os.Args[0]), time.Now().UTC().Add(-age).Format(backupTimeFormat)),
+		)
+		_ = ioutil.WriteFile(file, nil, 0644)
+		files = append(files, file)
+	}
+
+	logger, _ := New("", &Options{
+		RetentionPeriod:   1,
+		RetentionDuration: 36 * time.Hour,
+		ManualRotation:    true,
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
 	}()
+
+	// The RetentionDuration should take precedence over the RetentionPeriod
+	logger.cleanUpOldLogs()
+	_, err := os.Stat(files[0])
+	equals(
+		err,
+		nil,
+		t,
+		"Error. Day old log file should be retained",
+	)
+	_, err = os.Stat(files[1])
+	equals(
+		os.IsNotExist(err),
+		true,
+		t,
+		"Error. Two days old log file should be removed",
+	)
+
+	_, err = New("", &Options{RetentionDuration: -time.Hour}, &Callback{})
+	equals(
+		err != nil,
+		true,
+		t,
+		"Error. A negative retention duration should be rejected",
+	)
+}
+
+func TestLogger_Retention_CleanupInterval(t *testing.T) {
+
 	logger, _ := New("", &Options{
-		Compress:         true,
-		CompressionLevel: 9,
-		Period:           time.Second * 2,
-	}, &Callback{
-		Execute: func(s string) {
-			rotateCh <- s
-		},
-	})
+		RetentionPeriod: 30,
+		CleanupInterval: 50 * time.Millisecond,
+		ManualRotation:  true,
+	}, &Callback{})
 
 	defer func() {
 		// Closing the logger to clean up the log directory
-		_ = logger.close()
+		_ = logger.Close()
 		// Cleaning up the log directory
 		_ = clean(filepath.Dir(logger.Filename))
 	}()
 
-	log.SetOutput(logger)
-	log.Println(randStringBytes(1024))
-	time.Sleep(time.Second * 3)
-	logger.rotationTicker.Stop()
-	close(rotateCh)
-	time.Sleep(time.Second * 1)
+	// Creating the fake month old log file after the startup cleanup
+	monthOldFile := filepath.Join(
+		filepath.Dir(logger.Filename),
+		fmt.Sprintf("%s-eidos-%s.log", filepath.Base(os.Args[0]), time.Now().UTC().Add(-31*24*time.Hour).Format(backupTimeFormat)),
+	)
+	_ = ioutil.WriteFile(monthOldFile, nil, 0644)
+
+	// The scan should not wait for the retention period
+	time.Sleep(300 * time.Millisecond)
+	_, err := os.Stat(monthOldFile)
+	equals(
+		os.IsNotExist(err),
+		true,
+		t,
+		"Error. Month old log file should be removed by the cleanup scan",
+	)
+
+	equals(
+		(&Options{RetentionDuration: time.Minute}).cleanupInterval(),
+		time.Minute,
+		t,
+		"Error. The cleanup interval should not exceed the retention",
+	)
+	equals(
+		(&Options{RetentionPeriod: 30}).cleanupInterval(),
+		defaultCleanupInterval,
+		t,
+		"Error. The default cleanup interval should be used",
+	)
+
+	_, err = New("", &Options{CleanupInterval: -time.Hour}, &Callback{})
+	equals(
+		err != nil,
+		true,
+		t,
+		"Error. A negative cleanup interval should be rejected",
+	)
+}
+
+func TestLogger_Retention_Location(t *testing.T) {
+
+	dir := filepath.Join(os.TempDir(), "eidos_logs")
+	_ = os.MkdirAll(dir, 0755)
+	location := time.FixedZone("UTC+14", 14*60*60)
+
+	// Creating the fake log files named in a timezone far ahead of UTC
+	var files []string
+	for _, age := range []time.Duration{30 * time.Hour, 40 * time.Hour} {
+		file := filepath.Join(
+			dir,
+			fmt.Sprintf("%s-eidos-%s.log", filepath.Base(os.Args[0]), time.Now().In(location).Add(-age).Format(backupTimeFormat)),
+		)
+		_ = ioutil.WriteFile(file, nil, 0644)
+		files = append(files, file)
+	}
+
+	logger, _ := New("", &Options{
+		RetentionDuration: 36 * time.Hour,
+		Location:          location,
+		ManualRotation:    true,
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// The age of the log files should be calculated in the Location
+	logger.cleanUpOldLogs()
+	_, err := os.Stat(files[0])
+	equals(
+		err,
+		nil,
+		t,
+		"Error. 30 hours old log file should be retained",
+	)
+	_, err = os.Stat(files[1])
+	equals(
+		os.IsNotExist(err),
+		true,
+		t,
+		"Error. 40 hours old log file should be removed",
+	)
+}
+
+func TestLogger_Retention_All_Suffixes(t *testing.T) {
+
+	dir := filepath.Join(os.TempDir(), "eidos_logs")
+	_ = os.MkdirAll(dir, 0755)
+
+	// Creating the fake month old log files compressed in the various formats
+	monthOld := time.Now().UTC().Add(-31 * 24 * time.Hour)
+	var files []string
+	for i, suffix := range []string{".log", ".log.gz", ".log.lz4", ".log.xz"} {
+		file := filepath.Join(
+			dir,
+			fmt.Sprintf("%s-eidos-%s%s", filepath.Base(os.Args[0]), monthOld.Add(time.Duration(i)*time.Second).Format(backupTimeFormat), suffix),
+		)
+		_ = ioutil.WriteFile(file, nil, 0644)
+		files = append(files, file)
+	}
+
+	// Creating a fresh log file compressed in a format other than the configured one
+	freshFile := filepath.Join(
+		dir,
+		fmt.Sprintf("%s-eidos-%s.log.lz4", filepath.Base(os.Args[0]), time.Now().UTC().Format(backupTimeFormat)),
+	)
+	_ = ioutil.WriteFile(freshFile, nil, 0644)
+
+	logger, _ := New("", &Options{
+		RetentionPeriod: 10,
+		ManualRotation:  true,
+	}, &Callback{})
 
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// The compressed log files should be pruned even if the compression is disabled
+	logger.cleanUpOldLogs()
+	for _, file := range files {
+		_, err := os.Stat(file)
+		equals(
+			os.IsNotExist(err),
+			true,
+			t,
+			fmt.Sprintf("Error. Month old log file %s should be removed", filepath.Base(file)),
+		)
+	}
+
+	// The log file compressed in another format should not be compressed again
+	logger.RotationOption.Compress = true
+	logger.compressAgedBackups()
+	_, err := os.Stat(freshFile)
+	equals(
+		err,
+		nil,
+		t,
+		"Error. The log file compressed in another format should not be compressed again",
+	)
+}
+
+func TestLogger_Retention_Unrelated_Files(t *testing.T) {
+
+	dir := filepath.Join(os.TempDir(), "eidos_logs")
+	_ = os.MkdirAll(dir, 0755)
+
+	// Creating the month old files of the other applications sharing the
+	// prefix of the log file, and a month old rotated log file
+	monthOld := time.Now().UTC().Add(-31 * 24 * time.Hour)
+	unrelatedFiles := []string{
+		filepath.Join(dir, fmt.Sprintf("%s-eidos-copy.log", filepath.Base(os.Args[0]))),
+		filepath.Join(dir, fmt.Sprintf("%s-eidos-worker-%s.log", filepath.Base(os.Args[0]), monthOld.Format(backupTimeFormat))),
+		filepath.Join(dir, fmt.Sprintf("%s-eidosd-%s.log", filepath.Base(os.Args[0]), monthOld.Format(backupTimeFormat))),
+	}
+	for _, file := range unrelatedFiles {
+		_ = ioutil.WriteFile(file, nil, 0644)
+		_ = os.Chtimes(file, monthOld, monthOld)
+	}
+	monthOldFile := filepath.Join(
+		dir,
+		fmt.Sprintf("%s-eidos-%s.log", filepath.Base(os.Args[0]), monthOld.Format(backupTimeFormat)),
+	)
+	_ = ioutil.WriteFile(monthOldFile, nil, 0644)
+
+	logger, _ := New("", &Options{
+		RetentionPeriod: 10,
+		ManualRotation:  true,
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// Only the files with a parsable timestamp following the prefix are backups
+	logger.cleanUpOldLogs()
+	for _, file := range unrelatedFiles {
+		_, err := os.Stat(file)
+		equals(
+			err,
+			nil,
+			t,
+			"Error. The unrelated file should be retained",
+		)
+	}
+	_, err := os.Stat(monthOldFile)
+	equals(
+		os.IsNotExist(err),
+		true,
+		t,
+		"Error. Month old log file should be removed",
+	)
+}
+
+func TestLogger_RetentionPlan(t *testing.T) {
+
+	dir := filepath.Join(os.TempDir(), "eidos_logs")
+	_ = os.MkdirAll(dir, 0755)
+
+	// Creating a fake month old log file and two fresh log files of 1 mb
+	var files []string
+	for _, age := range []time.Duration{31 * 24 * time.Hour, 2 * time.Hour, time.Hour} {
+		file := filepath.Join(
+			dir,
+			fmt.Sprintf("%s-eidos-%s.log", filepath.Base(os.Args[0]), time.Now().UTC().Add(-age).Format(backupTimeFormat)),
+		)
+		_ = ioutil.WriteFile(file, make([]byte, megabyte), 0644)
+		files = append(files, file)
+	}
+
+	logger, _ := New("", &Options{
+		ManualRotation: true,
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// The retention policies are set after New, so the startup
+	// clean up does not remove the log files
+	logger.RotationOption.RetentionPeriod = 10
+	logger.RotationOption.MaxTotalSize = 1
+
+	// The month old log file exceeds the retention period and the older
+	// fresh log file exceeds the MaxTotalSize
+	plan, err := logger.RetentionPlan()
+	equals(
+		err == nil && len(plan) == 2 &&
+			plan[0].File == files[0] && plan[0].Reason == RetentionAge &&
+			plan[1].File == files[1] && plan[1].Reason == RetentionSize && plan[1].Size == int64(megabyte),
+		true,
+		t,
+		"Error. The retention plan should hold the log files with the reasons",
+	)
+
+	// The log files should not be removed by the retention plan
+	for _, file := range files {
+		_, err := os.Stat(file)
+		equals(
+			err,
+			nil,
+			t,
+			"Error. The retention plan should not remove the log files",
+		)
+	}
+}
+
+func TestLogger_Prune(t *testing.T) {
+
+	dir := filepath.Join(os.TempDir(), "eidos_logs")
+	_ = os.MkdirAll(dir, 0755)
+
+	// Creating a fake month old log file and a fresh log file
+	var files []string
+	for _, age := range []time.Duration{31 * 24 * time.Hour, time.Hour} {
+		file := filepath.Join(
+			dir,
+			fmt.Sprintf("%s-eidos-%s.log", filepath.Base(os.Args[0]), time.Now().UTC().Add(-age).Format(backupTimeFormat)),
+		)
+		_ = ioutil.WriteFile(file, nil, 0644)
+		files = append(files, file)
+	}
+
+	logger, _ := New("", &Options{
+		ManualRotation: true,
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// The retention policy is set after New, so the startup
+	// clean up does not remove the log files
+	logger.RotationOption.RetentionPeriod = 10
+
+	// The cancelled context should stop the removal
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	pruned, err := logger.Prune(ctx)
+	equals(
+		err == context.Canceled && len(pruned) == 0,
+		true,
+		t,
+		"Error. The cancelled context should stop the removal",
+	)
+
+	pruned, err = logger.Prune(context.Background())
+	equals(
+		err == nil && len(pruned) == 1 && pruned[0].File == files[0] && pruned[0].Reason == RetentionAge,
+		true,
+		t,
+		"Error. The month old log file should be returned as removed",
+	)
+	_, err = os.Stat(files[0])
+	equals(
+		os.IsNotExist(err),
+		true,
+		t,
+		"Error. Month old log file should be removed",
+	)
+	_, err = os.Stat(files[1])
+	equals(
+		err,
+		nil,
+		t,
+		"Error. Fresh log file should be retained",
+	)
+}
+
+func TestLogger_Retention_Archive(t *testing.T) {
+
+	dir := filepath.Join(os.TempDir(), "eidos_logs")
+	_ = os.MkdirAll(dir, 0755)
+
+	// Creating the fake month old log files
+	var files []string
+	for _, age := range []time.Duration{32 * 24 * time.Hour, 31 * 24 * time.Hour} {
+		file := filepath.Join(
+			dir,
+			fmt.Sprintf("%s-eidos-%s.log", filepath.Base(os.Args[0]), time.Now().UTC().Add(-age).Format(backupTimeFormat)),
+		)
+		_ = ioutil.WriteFile(file, []byte("eidos\n"), 0644)
+		files = append(files, file)
+	}
+
+	logger, _ := New("", &Options{
+		ArchiveDir:     "archive",
+		ManualRotation: true,
+	}, &Callback{
+		Archive: func(s string) error {
+			// Failing the archival of the oldest log file
+			if s == files[0] {
+				return fmt.Errorf("cold storage is unavailable")
+			}
+			return nil
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	logger.RotationOption.RetentionPeriod = 10
+	logger.cleanUpOldLogs()
+
+	// The log file should be retained, if the archival fails
+	_, err := os.Stat(files[0])
+	equals(
+		err,
+		nil,
+		t,
+		"Error. The log file should be retained, if the archival fails",
+	)
+
+	// The log file should be moved into the archive dir
+	_, err = os.Stat(files[1])
+	content, _ := ioutil.ReadFile(filepath.Join(dir, "archive", filepath.Base(files[1])))
+	equals(
+		os.IsNotExist(err) && string(content) == "eidos\n",
+		true,
+		t,
+		"Error. The log file should be moved into the archive dir",
+	)
+}
+
+func TestLogger_Retention_Archive_Partitions(t *testing.T) {
+
+	dir := filepath.Join(os.TempDir(), "eidos_logs")
+
+	// Creating the fake month old log files with their checksum files under the
+	// date partitions, the first of them is already archived by a previous run
+	var files []string
+	var partitions []string
+	for _, age := range []time.Duration{32 * 24 * time.Hour, 31 * 24 * time.Hour} {
+		rotation := time.Now().UTC().Add(-age)
+		partition := filepath.Join(rotation.Format("2006"), rotation.Format("01"), rotation.Format("02"))
+		file := filepath.Join(
+			dir,
+			partition,
+			fmt.Sprintf("%s-eidos-%s.log", filepath.Base(os.Args[0]), rotation.Format(backupTimeFormat)),
+		)
+		_ = os.MkdirAll(filepath.Dir(file), 0755)
+		_ = ioutil.WriteFile(file, []byte("eidos\n"), 0644)
+		_ = ioutil.WriteFile(file+checksumExtension, []byte("checksum\n"), 0644)
+		files = append(files, file)
+		partitions = append(partitions, partition)
+	}
+	archived := filepath.Join(dir, "archive", partitions[0], filepath.Base(files[0]))
+	_ = os.MkdirAll(filepath.Dir(archived), 0755)
+	_ = ioutil.WriteFile(archived, []byte("archived\n"), 0644)
+
+	logger, _ := New("", &Options{
+		ArchiveDir:             "archive",
+		DatePartitionedBackups: true,
+		ManualRotation:         true,
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	logger.RotationOption.RetentionPeriod = 10
+	logger.cleanUpOldLogs()
+
+	// The log files and their checksum files should be moved under the date partitions of
+	// the archive dir, without overwriting the archived log file
+	codec := newBackupCodec(logger.Filename, logger.RotationOption)
+	base := filepath.Base(files[0])
+	for i, archivedFile := range []string{
+		filepath.Join(dir, "archive", partitions[0], codec.stem(base)+"-1"+codec.suffix(base)),
+		filepath.Join(dir, "archive", partitions[1], filepath.Base(files[1])),
+	} {
+		content, _ := ioutil.ReadFile(archivedFile)
+		checksum, _ := ioutil.ReadFile(archivedFile + checksumExtension)
+		_, err := os.Stat(filepath.Join(dir, partitions[i]))
+		equals(
+			string(content) == "eidos\n" && string(checksum) == "checksum\n" && os.IsNotExist(err),
+			true,
+			t,
+			"Error. The log file should be moved into the date partition of the archive dir",
+		)
+	}
+	content, _ := ioutil.ReadFile(archived)
+	equals(
+		string(content),
+		"archived\n",
+		t,
+		"Error. The archived log file should not be overwritten",
+	)
+}
+
+func TestLogger_Retention_Pruned(t *testing.T) {
+
+	dir := filepath.Join(os.TempDir(), "eidos_logs")
+	_ = os.MkdirAll(dir, 0755)
+
+	// Creating a fake month old log file
+	monthOldFile := filepath.Join(
+		dir,
+		fmt.Sprintf("%s-eidos-%s.log", filepath.Base(os.Args[0]), time.Now().UTC().Add(-31*24*time.Hour).Format(backupTimeFormat)),
+	)
+	_ = ioutil.WriteFile(monthOldFile, []byte("eidos\n"), 0644)
+
+	var prunedBackups []PrunedBackup
+	logger, _ := New("", &Options{
+		ManualRotation: true,
+	}, &Callback{
+		Pruned: func(p []PrunedBackup) {
+			prunedBackups = append(prunedBackups, p...)
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	logger.RotationOption.RetentionPeriod = 10
+	logger.cleanUpOldLogs()
+
+	// The removed log file should be passed to the callback with its size and age
+	equals(
+		len(prunedBackups) == 1 && prunedBackups[0].File == monthOldFile && prunedBackups[0].Size == 6 &&
+			prunedBackups[0].Age >= 31*24*time.Hour && prunedBackups[0].Reason == RetentionAge,
+		true,
+		t,
+		"Error. The removed log file should be passed to the callback",
+	)
+}
+
+func TestLogger_Rotate_Retention(t *testing.T) {
+
+	var prunedCh = make(chan []PrunedBackup, 10)
+	logger, _ := New("", &Options{
+		RetentionPeriod: 10,
+		ManualRotation:  true,
+	}, &Callback{
+		Pruned: func(p []PrunedBackup) {
+			prunedCh <- p
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// Waiting for the startup clean up, then creating
+	// a fake month old log file, which only the clean
+	// up after the rotation can remove
+	time.Sleep(100 * time.Millisecond)
+	monthOldFile := filepath.Join(
+		filepath.Dir(logger.Filename),
+		fmt.Sprintf("%s-eidos-%s.log", filepath.Base(os.Args[0]), time.Now().UTC().Add(-31*24*time.Hour).Format(backupTimeFormat)),
+	)
+	_ = ioutil.WriteFile(monthOldFile, nil, 0644)
+
+	_, _ = logger.Write([]byte("eidos\n"))
+	_ = logger.Rotate()
+
+	select {
+	case p := <-prunedCh:
+		equals(
+			len(p) == 1 && p[0].File == monthOldFile,
+			true,
+			t,
+			"Error. Month old log file should be removed after the rotation",
+		)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Error. The retention was not enforced after the rotation")
+	}
+}
+
+func TestLogger_Retention_Filter(t *testing.T) {
+
+	dir := filepath.Join(os.TempDir(), "eidos_logs")
+	_ = os.MkdirAll(dir, 0755)
+
+	// Creating the fake month old log files, one of them tagged "incident"
+	monthOld := time.Now().UTC().Add(-31 * 24 * time.Hour)
+	incidentFile := filepath.Join(
+		dir,
+		fmt.Sprintf("%s-eidos-%s-incident.log", filepath.Base(os.Args[0]), monthOld.Format(backupTimeFormat)),
+	)
+	_ = ioutil.WriteFile(incidentFile, nil, 0644)
+	monthOldFile := filepath.Join(
+		dir,
+		fmt.Sprintf("%s-eidos-%s.log", filepath.Base(os.Args[0]), monthOld.Add(time.Second).Format(backupTimeFormat)),
+	)
+	_ = ioutil.WriteFile(monthOldFile, nil, 0644)
+
+	logger, _ := New("", &Options{
+		ManualRotation: true,
+		RetentionFilter: func(b BackupInfo) bool {
+			return b.Tag != "incident"
+		},
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	logger.RotationOption.RetentionPeriod = 10
+	logger.cleanUpOldLogs()
+
+	// The log file tagged "incident" should be protected from the retention
+	_, err := os.Stat(incidentFile)
+	equals(
+		err,
+		nil,
+		t,
+		"Error. The protected log file should be retained",
+	)
+	_, err = os.Stat(monthOldFile)
+	equals(
+		os.IsNotExist(err),
+		true,
+		t,
+		"Error. Month old log file should be removed",
+	)
+}
+
+func TestLogger_Pin(t *testing.T) {
+
+	dir := filepath.Join(os.TempDir(), "eidos_logs")
+	_ = os.MkdirAll(dir, 0755)
+
+	// Creating the fake month old log files
+	monthOld := time.Now().UTC().Add(-31 * 24 * time.Hour)
+	pinnedFile := filepath.Join(
+		dir,
+		fmt.Sprintf("%s-eidos-%s.log", filepath.Base(os.Args[0]), monthOld.Format(backupTimeFormat)),
+	)
+	_ = ioutil.WriteFile(pinnedFile, nil, 0644)
+	monthOldFile := filepath.Join(
+		dir,
+		fmt.Sprintf("%s-eidos-%s.log", filepath.Base(os.Args[0]), monthOld.Add(time.Second).Format(backupTimeFormat)),
+	)
+	_ = ioutil.WriteFile(monthOldFile, nil, 0644)
+
+	logger, _ := New("", &Options{
+		ManualRotation: true,
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	equals(
+		logger.Pin(filepath.Join(dir, "missing.log")) != nil,
+		true,
+		t,
+		"Error. Pinning a missing log file should fail",
+	)
+	equals(
+		logger.Pin(filepath.Base(pinnedFile)),
+		nil,
+		t,
+		"Error. Failed to pin the log file",
+	)
+
+	logger.RotationOption.RetentionPeriod = 10
+	logger.cleanUpOldLogs()
+
+	// The pinned log file should be retained
+	_, err := os.Stat(pinnedFile)
+	equals(
+		err,
+		nil,
+		t,
+		"Error. The pinned log file should be retained",
+	)
+	_, err = os.Stat(monthOldFile)
+	equals(
+		os.IsNotExist(err),
+		true,
+		t,
+		"Error. Month old log file should be removed",
+	)
+
+	// The released log file should be removed
+	logger.Unpin(pinnedFile)
+	logger.cleanUpOldLogs()
+	_, err = os.Stat(pinnedFile)
+	equals(
+		os.IsNotExist(err),
+		true,
+		t,
+		"Error. The released log file should be removed",
+	)
+}
+
+func TestLogger_Rotate_Auto_Period_Compress(t *testing.T) {
+
+	var rotateCh = make(chan string, 10)
+
+	go func() {
+		for file := range rotateCh {
+			_, err := os.Stat(file)
+			equals(
+				os.IsNotExist(err),
+				false,
+				t,
+				"Error. The rotated compressed log file should be present",
+			)
+		}
+	}()
+	logger, _ := New("", &Options{
+		Compress:         true,
+		CompressionLevel: 9,
+		Period:           time.Second * 2,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	log.SetOutput(logger)
+	log.Println(randStringBytes(1024))
+	time.Sleep(time.Second * 3)
+
+	// Waiting for the callbacks, before closing the channel
+	log.SetOutput(os.Stderr)
+	_ = logger.Shutdown(context.Background())
+	close(rotateCh)
+	time.Sleep(time.Second * 1)
+
+}
+
+func TestLogger_Concurrent_Write_Shared(t *testing.T) {
+
+	var footer FileStats
+	logger, _ := New("", &Options{
+		ManualRotation: true,
+		FileFooter: func(stats FileStats) []byte {
+			footer = stats
+			return nil
+		},
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// The concurrent writes share the lock of the logger
+	const writers, lines = 8, 500
+	line := []byte(randStringBytes(63) + "\n")
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < lines; i++ {
+				_, _ = logger.Write(line)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Every line should be written in full without interleaving
+	content, _ := ioutil.ReadFile(logger.Filename)
+	equals(
+		bytes.Count(content, line),
+		writers*lines,
+		t,
+		"Error. The concurrent writes should not be interleaved",
+	)
+	logger.mutex.Lock()
+	size := logger.size
+	logger.mutex.Unlock()
+	equals(
+		size,
+		int64(len(content)),
+		t,
+		"Error. The size of the concurrent writes should be accounted",
+	)
+
+	// The statistics of the concurrent writes should be accounted
+	equals(logger.Rotate(), nil, t, "Error. Failed to rotate the log file manually")
+	equals(
+		footer.Lines == writers*lines && footer.Bytes == int64(len(content)),
+		true,
+		t,
+		"Error. The statistics of the concurrent writes should be accounted",
+	)
+}
+
+func TestLogger_Concurrent_Write_Rotation_Lines(t *testing.T) {
+
+	var rotated []string
+	var rotatedMutex sync.Mutex
+	logger, _ := New("", &Options{
+		SizeBytes:            4096,
+		SynchronousCallbacks: true,
+	}, &Callback{
+		Execute: func(s string) {
+			rotatedMutex.Lock()
+			rotated = append(rotated, s)
+			rotatedMutex.Unlock()
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// The concurrent writes are neither interleaved nor split across the rotations
+	const writers, lines = 8, 100
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			line := []byte(strings.Repeat(strconv.Itoa(w), 99) + "\n")
+			for i := 0; i < lines; i++ {
+				_, _ = logger.Write(line)
+			}
+		}(w)
+	}
+	wg.Wait()
+	_ = logger.Close()
+
+	written := 0
+	for _, file := range append(rotated, logger.Filename) {
+		content, _ := ioutil.ReadFile(file)
+		for _, line := range strings.SplitAfter(string(content), "\n") {
+			if line == "" {
+				continue
+			}
+			equals(
+				len(line) == 100 && strings.Count(line, line[:1]) == 99,
+				true,
+				t,
+				"Error. Every line should be written contiguously in a single log file",
+			)
+			written++
+		}
+	}
+	equals(written, writers*lines, t, "Error. Every line should be written")
+}
+
+func TestLogger_Write_BufferPartialLines(t *testing.T) {
+
+	var rotateCh = make(chan string, 1)
+	logger, _ := New("", &Options{
+		SizeBytes:          10,
+		BufferPartialLines: true,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// The partial line is held till its newline arrives
+	n, err := logger.Write([]byte("abc"))
+	equals(
+		n == 3 && err == nil,
+		true,
+		t,
+		"Error. The held partial line should be accounted as written",
+	)
+	_, err = os.Stat(logger.Filename)
+	equals(os.IsNotExist(err), true, t, "Error. The partial line should not be written")
+	_, _ = logger.Write([]byte("def\nghi"))
+	content, _ := ioutil.ReadFile(logger.Filename)
+	equals(string(content), "abcdef\n", t, "Error. The completed line should be written")
+
+	// The record written in multiple writes is not split across the rotation
+	_, _ = logger.Write([]byte("jkl\n"))
+	content, _ = ioutil.ReadFile(<-rotateCh)
+	equals(string(content), "abcdef\n", t, "Error. The log file should be rotated before the record")
+	content, _ = ioutil.ReadFile(logger.Filename)
+	equals(string(content), "ghijkl\n", t, "Error. The record should be written contiguously")
+
+	// The held partial line is written, when the logger is closed
+	_, _ = logger.Write([]byte("mn"))
+	_ = logger.Close()
+	content, _ = ioutil.ReadFile(logger.Filename)
+	equals(string(content), "ghijkl\nmn", t, "Error. The held partial line should be written on the close")
+}
+
+func TestLogger_PartialWriter_Concurrent(t *testing.T) {
+
+	logger, _ := New("", &Options{
+		BufferPartialLines: true,
+		Sampling:           []SamplingRule{{Prefix: "DEBUG", Every: 2}},
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// The goroutines write the records in multiple writes through their own
+	// writers, along with the complete lines written to the logger
+	const goroutines, records = 8, 200
+	line := func(level string, g, i int) string {
+		return fmt.Sprintf("%s %d %d %s\n", level, g, i, strings.Repeat(string(rune('a'+g)), 40))
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			writer := logger.PartialWriter()
+			defer func() {
+				_ = writer.Close()
+			}()
+			for i := 0; i < records; i++ {
+				record := line("INFO", g, i)
+				split := (i*7 + g) % len(record)
+				_, _ = writer.Write([]byte(record[:split]))
+				_, _ = writer.Write([]byte(record[split:]))
+				_, _ = logger.Write([]byte(line("DEBUG", g, i)))
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	content, _ := ioutil.ReadFile(logger.Filename)
+	next := map[string]int{}
+	for _, written := range strings.SplitAfter(string(content), "\n") {
+		if written == "" {
+			continue
+		}
+		var level string
+		var g, i int
+		_, _ = fmt.Sscanf(written, "%s %d %d", &level, &g, &i)
+		equals(
+			written,
+			line(level, g, i),
+			t,
+			"Error. The lines of the concurrent writers should be intact",
+		)
+		key := fmt.Sprintf("%s %d", level, g)
+		equals(
+			i >= next[key],
+			true,
+			t,
+			"Error. The lines of a writer should be written in order",
+		)
+		next[key] = i + 1
+	}
+	equals(
+		strings.Count(string(content), "INFO"),
+		goroutines*records,
+		t,
+		"Error. All the records of the writers should be written",
+	)
+	equals(
+		strings.Count(string(content), "DEBUG"),
+		goroutines*records/2,
+		t,
+		"Error. The debug lines should be sampled",
+	)
+}
+
+func TestLogger_WriteString(t *testing.T) {
+	logger, _ := New("", &Options{}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	n, err := io.WriteString(logger, "eidos\n")
+	equals(
+		n == 6 && err == nil,
+		true,
+		t,
+		"Error. Failed to write the string",
+	)
+	_, _ = fmt.Fprintf(logger, "%s\n", "eidos")
+	content, _ := ioutil.ReadFile(logger.Filename)
+	equals(
+		string(content),
+		"eidos\neidos\n",
+		t,
+		"Error. The strings should be written to the log file",
+	)
+}
+
+func TestLogger_ReadFrom(t *testing.T) {
+
+	var rotated = make(chan string, 16)
+	logger, _ := New("", &Options{
+		SizeBytes: 64 * 1024,
+	}, &Callback{
+		Execute: func(s string) {
+			rotated <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// Streaming a payload larger than the maximum file size
+	var payload bytes.Buffer
+	for payload.Len() < 200*1024 {
+		payload.WriteString(randStringBytes(99) + "\n")
+	}
+	expected := int64(payload.Len())
+	n, err := io.Copy(logger, io.LimitReader(&payload, expected))
+	equals(
+		n == expected && err == nil,
+		true,
+		t,
+		"Error. Failed to stream the payload into the log file",
+	)
+
+	// The payload should be rotated without splitting the lines
+	for i := 0; i < 3; i++ {
+		select {
+		case file := <-rotated:
+			content, _ := ioutil.ReadFile(file)
+			equals(
+				len(content) <= 64*1024 && len(content)%100 == 0,
+				true,
+				t,
+				"Error. The rotated log file should hold the complete lines",
+			)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Error. The payload should be rotated three times")
+		}
+	}
+}
+
+func TestLogger_WriteBatch(t *testing.T) {
+
+	var rotateCh = make(chan string, 4)
+	logger, _ := New("", &Options{
+		SizeBytes: 2048,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	record := []byte(randStringBytes(999) + "\n")
+	_, _ = logger.Write(record[:500])
+
+	// The batch, which does not fit in the log file, is not split across the rotation
+	n, err := logger.WriteBatch([][]byte{record, record})
+	equals(
+		n == 2000 && err == nil,
+		true,
+		t,
+		"Error. Failed to write the batch",
+	)
+	fileInfo, _ := os.Stat(<-rotateCh)
+	equals(
+		fileInfo.Size(),
+		int64(500),
+		t,
+		"Error. The log file should be rotated before the batch",
+	)
+	fileInfo, _ = os.Stat(logger.Filename)
+	equals(
+		fileInfo.Size(),
+		int64(2000),
+		t,
+		"Error. The batch should be written to the new log file",
+	)
+
+	// The batch larger than the maximum file size is written in the complete records
+	n, err = logger.WriteBatch([][]byte{record, record, record})
+	equals(
+		n == 3000 && err == nil,
+		true,
+		t,
+		"Error. Failed to write the batch larger than the maximum file size",
+	)
+	for i := 0; i < 2; i++ {
+		fileInfo, _ = os.Stat(<-rotateCh)
+		equals(
+			fileInfo.Size(),
+			int64(2000),
+			t,
+			"Error. The rotated log file should hold the complete records",
+		)
+	}
+	fileInfo, _ = os.Stat(logger.Filename)
+	equals(
+		fileInfo.Size(),
+		int64(1000),
+		t,
+		"Error. The log file should hold the last record of the batch",
+	)
+
+	// The batch of a closed logger is rejected
+	_ = logger.Close()
+	_, err = logger.WriteBatch([][]byte{record})
+	equals(
+		errors.Is(err, ErrClosed),
+		true,
+		t,
+		"Error. The batch of a closed logger should be rejected",
+	)
+}
+
+func TestLogger_Write_Dedup(t *testing.T) {
+
+	advance, restore := freezeTime()
+	defer restore()
+
+	logger, _ := New("", &Options{
+		DedupWindow: time.Minute,
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// The repeated lines are collapsed into a summary before the next different line
+	for i := 0; i < 3; i++ {
+		n, err := logger.Write([]byte("a\n"))
+		equals(
+			n == 2 && err == nil,
+			true,
+			t,
+			"Error. The collapsed line should be accounted as written",
+		)
+	}
+	_, _ = logger.Write([]byte("b\nb\nb\nc\n"))
+	n, err := logger.WriteBatch([][]byte{[]byte("c\n"), []byte("c\n"), []byte("d\n")})
+	equals(
+		n == 6 && err == nil,
+		true,
+		t,
+		"Error. Failed to write the batch",
+	)
+	content, _ := ioutil.ReadFile(logger.Filename)
+	equals(
+		string(content),
+		"a\nlast message repeated 2 times\nb\nlast message repeated 2 times\n"+
+			"c\nlast message repeated 2 times\nd\n",
+		t,
+		"Error. The repeated lines should be collapsed",
+	)
+
+	// The summary is written once the window has elapsed, and the repeated line is written again
+	_, _ = logger.Write([]byte("d\n"))
+	advance(time.Minute)
+	logger.writeRepeated()
+	_, _ = logger.Write([]byte("d\n"))
+	_, _ = logger.Write([]byte("d\n"))
+
+	// The pending summary is written, when the logger is closed
+	_ = logger.Close()
+	content, _ = ioutil.ReadFile(logger.Filename)
+	equals(
+		strings.Count(string(content), "d\nlast message repeated 1 times\n"),
+		2,
+		t,
+		"Error. The summary should be written after the window and on the close",
+	)
+}
+
+func TestLogger_Write_Dedup_Concurrent(t *testing.T) {
+
+	logger, _ := New("", &Options{
+		DedupWindow: time.Minute,
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// The goroutines write the runs of the identical lines
+	const goroutines, writes = 8, 500
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < writes; i++ {
+				_, _ = logger.Write([]byte(fmt.Sprintf("line %d\n", (i/(g+2))%3)))
+			}
+		}(g)
+	}
+	wg.Wait()
+	_ = logger.Close()
+
+	// Replaying the log file, a summary repeats the line right before it,
+	// and it is written right before the next different line
+	content, _ := ioutil.ReadFile(logger.Filename)
+	lines := strings.SplitAfter(strings.TrimSuffix(string(content), "\n"), "\n")
+	counts := map[string]int{}
+	for i, line := range lines {
+		var repeated int
+		if _, err := fmt.Sscanf(line, "last message repeated %d times", &repeated); err != nil {
+			counts[strings.TrimSuffix(line, "\n")]++
+			continue
+		}
+		equals(
+			i > 0 && !strings.HasPrefix(lines[i-1], "last") &&
+				(i == len(lines)-1 || lines[i+1] != lines[i-1]),
+			true,
+			t,
+			"Error. The summary should follow the collapsed line and precede the next different line",
+		)
+		counts[strings.TrimSuffix(lines[i-1], "\n")] += repeated
+	}
+	equals(
+		counts["line 0"]+counts["line 1"]+counts["line 2"],
+		goroutines*writes,
+		t,
+		"Error. All the written lines should be written or summarized",
+	)
+	expected := map[string]int{}
+	for g := 0; g < goroutines; g++ {
+		for i := 0; i < writes; i++ {
+			expected[fmt.Sprintf("line %d", (i/(g+2))%3)]++
+		}
+	}
+	equals(
+		counts,
+		expected,
+		t,
+		"Error. The summaries should count the repetitions of the collapsed lines",
+	)
+}
+
+func TestLogger_Write_Sampling(t *testing.T) {
+
+	logger, _ := New("", &Options{
+		Sampling: []SamplingRule{
+			{Prefix: "DEBUG", Every: 3},
+			{Pattern: `^TRACE\b`, Probability: 0.5},
+		},
+		DedupWindow: time.Minute,
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// The first of every 3 matching lines is kept, and the lines not matching any rule are kept
+	for i := 0; i < 7; i++ {
+		n, err := logger.Write([]byte(fmt.Sprintf("DEBUG %d\nINFO %d\n", i, i)))
+		equals(
+			n > 0 && err == nil,
+			true,
+			t,
+			"Error. The sampled out line should be accounted as written",
+		)
+	}
+	n, err := logger.WriteBatch([][]byte{[]byte("DEBUG 7\n"), []byte("DEBUG 8\n"), []byte("DEBUG 9\n")})
+	equals(
+		n == 24 && err == nil,
+		true,
+		t,
+		"Error. Failed to write the batch",
+	)
+	content, _ := ioutil.ReadFile(logger.Filename)
+	equals(
+		string(content),
+		"DEBUG 0\nINFO 0\nINFO 1\nINFO 2\nDEBUG 3\nINFO 3\nINFO 4\nINFO 5\nDEBUG 6\nINFO 6\nDEBUG 9\n",
+		t,
+		"Error. The matching lines should be sampled",
+	)
+
+	// The matching lines are kept with the probability
+	for i := 0; i < 1000; i++ {
+		_, _ = logger.Write([]byte(fmt.Sprintf("TRACE %d\n", i)))
+	}
+	content, _ = ioutil.ReadFile(logger.Filename)
+	kept := strings.Count(string(content), "TRACE")
+	equals(
+		kept > 350 && kept < 650,
+		true,
+		t,
+		"Error. The matching lines should be kept with the probability",
+	)
+}
+
+func TestLogger_Write_RateLimit(t *testing.T) {
+
+	advance, restore := freezeTime()
+	defer restore()
+
+	var errCh = make(chan error, 4)
+	logger, _ := New("", &Options{
+		RateLimit:      2,
+		RateLimitBurst: 3,
+	}, &Callback{
+		OnError: func(err error) {
+			errCh <- err
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// The writes above the burst are dropped, but they still succeed
+	line := []byte(randStringBytes(9) + "\n")
+	for i := 0; i < 5; i++ {
+		n, err := logger.Write(line)
+		equals(
+			n == len(line) && err == nil,
+			true,
+			t,
+			"Error. The dropped write should succeed",
+		)
+	}
+	equals(
+		logger.DroppedRecords(),
+		uint64(2),
+		t,
+		"Error. The writes above the burst should be dropped",
+	)
+	equals(
+		errors.Is(<-errCh, ErrRateLimited),
+		true,
+		t,
+		"Error. The start of the dropped writes should be reported",
+	)
+	equals(
+		len(errCh),
+		0,
+		t,
+		"Error. The dropped writes should be reported once",
+	)
+
+	// The bucket is refilled at the rate limit, and every record of a batch is admitted separately
+	advance(time.Second)
+	n, err := logger.WriteBatch([][]byte{line, line, line})
+	equals(
+		n == 3*len(line) && err == nil,
+		true,
+		t,
+		"Error. Failed to write the batch",
+	)
+	equals(
+		logger.DroppedRecords(),
+		uint64(3),
+		t,
+		"Error. The record of the batch above the rate limit should be dropped",
+	)
+	fileInfo, _ := os.Stat(logger.Filename)
+	equals(
+		fileInfo.Size(),
+		int64(5*len(line)),
+		t,
+		"Error. Only the admitted writes should be written to the log file",
+	)
+}
+
+func TestLogger_Write_RateLimit_Bytes(t *testing.T) {
+
+	advance, restore := freezeTime()
+	defer restore()
+
+	logger, _ := New("", &Options{
+		RateLimit:     100,
+		RateLimitUnit: RateLimitBytes,
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// The write larger than the burst is admitted with a full bucket, leaving the bucket in debt
+	_, _ = logger.Write([]byte(randStringBytes(149) + "\n"))
+	advance(time.Second)
+	_, _ = logger.Write([]byte(randStringBytes(99) + "\n"))
+	equals(
+		logger.DroppedRecords(),
+		uint64(1),
+		t,
+		"Error. The write should be dropped till the debt is refilled",
+	)
+	advance(time.Second)
+	_, _ = logger.Write([]byte(randStringBytes(99) + "\n"))
+	fileInfo, _ := os.Stat(logger.Filename)
+	equals(
+		fileInfo.Size(),
+		int64(250),
+		t,
+		"Error. The write within the refilled bucket should be written",
+	)
+}
+
+func TestLogger_Write_RateLimit_Block(t *testing.T) {
+
+	logger, _ := New("", &Options{
+		RateLimit:       20,
+		RateLimitBurst:  1,
+		RateLimitPolicy: RateLimitBlock,
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// The writes above the burst wait for the bucket to be refilled
+	start := time.Now()
+	line := []byte(randStringBytes(9) + "\n")
+	for i := 0; i < 3; i++ {
+		_, _ = logger.Write(line)
+	}
+	equals(
+		time.Since(start) >= 90*time.Millisecond,
+		true,
+		t,
+		"Error. The writes above the burst should be blocked",
+	)
+	fileInfo, _ := os.Stat(logger.Filename)
+	equals(
+		fileInfo.Size() == int64(3*len(line)) && logger.DroppedRecords() == 0,
+		true,
+		t,
+		"Error. The blocked writes should not be dropped",
+	)
+}
+
+func TestLogger_Write_Allocs(t *testing.T) {
+	logger, _ := New("", &Options{
+		BufferSize: 4096,
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// The writes, which do not rotate the log file, should not allocate
+	line := []byte(randStringBytes(127) + "\n")
+	_, _ = logger.Write(line)
+	equals(
+		testing.AllocsPerRun(100, func() { _, _ = logger.Write(line) }),
+		float64(0),
+		t,
+		"Error. The writes should not allocate",
+	)
+	equals(
+		testing.AllocsPerRun(100, func() { _, _ = logger.WriteString("eidos\n") }),
+		float64(0),
+		t,
+		"Error. The string writes should not allocate",
+	)
+}
+
+func TestLogger_Concurrent_Write_Rotate(t *testing.T) {
+
+	logger, _ := New("", &Options{
+		SizeBytes:        16 * 1024,
+		Period:           50 * time.Millisecond,
+		Compress:         true,
+		CompressionLevel: 1,
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// The writers race with the size based, the period based and the
+	// manual rotations, and with the compression of the rotated files
+	const writers, lines = 8, 500
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < lines; i++ {
+				_, _ = logger.Write([]byte(fmt.Sprintf("writer-%d line-%d %s\n", w, i, randStringBytes(64))))
+			}
+		}(w)
+	}
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				_ = logger.Rotate()
+				time.Sleep(5 * time.Millisecond)
+			}
+		}
+	}()
+	wg.Wait()
+	close(done)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	equals(
+		logger.Shutdown(ctx),
+		nil,
+		t,
+		"Error. Failed to shutdown the logger",
+	)
+
+	// Every line should be present exactly once and intact
+	files, _ := filepath.Glob(logger.Filename + "*")
+	backups, _ := filepath.Glob(strings.TrimSuffix(logger.Filename, ".log") + "-*")
+	var count int
+	for _, f := range append(files, backups...) {
+		content, _ := ioutil.ReadFile(f)
+		if strings.HasSuffix(f, ".gz") {
+			reader, err := gzip.NewReader(bytes.NewReader(content))
+			if err != nil {
+				continue
+			}
+			content, _ = ioutil.ReadAll(reader)
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(string(content), "\n"), "\n") {
+			if strings.HasPrefix(line, "writer-") && len(strings.Fields(line)) == 3 {
+				count++
+			}
+		}
+	}
+	equals(
+		count,
+		writers*lines,
+		t,
+		"Error. The concurrent writes should not be lost or torn by the rotations",
+	)
+}
+
+func TestLogger_Retention_MaxTotalSize(t *testing.T) {
+
+	dir := filepath.Join(os.TempDir(), "eidos_logs_total_size")
+	_ = os.MkdirAll(dir, 0755)
+	defer func() {
+		// Cleaning up the log directory
+		_ = clean(dir)
+	}()
+
+	body := []byte(randStringBytes(1024 * 1024))
+	var backups []string
+	for index := 3; index > 0; index-- {
+		backup := filepath.Join(
+			dir,
+			fmt.Sprintf("sample-%s.log", time.Now().Add(-time.Duration(index)*time.Hour).Format(backupTimeFormat)),
+		)
+		_ = ioutil.WriteFile(backup, body, 0644)
+		backups = append(backups, backup)
+	}
+
+	logger, _ := New(filepath.Join(dir, "sample.log"), &Options{
+		ManualRotation: true,
+	}, &Callback{})
+	defer logger.Close()
+
+	// The MaxTotalSize is set after New, so the startup clean up does not race
+	logger.RotationOption.MaxTotalSize = 2
+	logger.cleanUpOldLogs()
+
+	// Validating the existence of the fake files
+	_, err := os.Stat(backups[0])
+	equals(
+		os.IsNotExist(err),
+		true,
+		t,
+		"Error. Oldest backup file should not be present in the log folder",
+	)
+	for _, backup := range backups[1:] {
+		_, err = os.Stat(backup)
+		equals(
+			os.IsNotExist(err),
+			false,
+			t,
+			"Error. Recent backup files should be present in the log folder",
+		)
+	}
+}
+
+func TestLogger_Retention_MaxTotalSize_CleanupInterval(t *testing.T) {
+
+	logger, _ := New("", &Options{
+		MaxTotalSize:    1,
+		CleanupInterval: 50 * time.Millisecond,
+		ManualRotation:  true,
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	equals(
+		scheduled(logger, "retention"),
+		true,
+		t,
+		"Error. The cleanup scan should be scheduled for the MaxTotalSize",
+	)
+
+	// Creating the backup files exceeding the MaxTotalSize after the startup cleanup
+	body := []byte(randStringBytes(1024 * 1024))
+	var backups []string
+	for index := 2; index > 0; index-- {
+		backup := filepath.Join(
+			filepath.Dir(logger.Filename),
+			fmt.Sprintf("%s-eidos-%s.log", filepath.Base(os.Args[0]), time.Now().UTC().Add(-time.Duration(index)*time.Hour).Format(backupTimeFormat)),
+		)
+		_ = ioutil.WriteFile(backup, body, 0644)
+		backups = append(backups, backup)
+	}
+
+	// The scan should remove the oldest backup file without a rotation
+	time.Sleep(300 * time.Millisecond)
+	_, err := os.Stat(backups[0])
+	equals(
+		os.IsNotExist(err),
+		true,
+		t,
+		"Error. Oldest backup file should be removed by the cleanup scan",
+	)
+	_, err = os.Stat(backups[1])
+	equals(
+		os.IsNotExist(err),
+		false,
+		t,
+		"Error. Recent backup file should be present in the log folder",
+	)
+}
+
+func TestParseCronExpression(t *testing.T) {
+	now := time.Date(2020, time.October, 15, 10, 30, 45, 0, time.UTC)
+
+	for expression, expected := range map[string]time.Time{
+		"0 0 * * *":      time.Date(2020, time.October, 16, 0, 0, 0, 0, time.UTC),
+		"*/15 * * * *":   time.Date(2020, time.October, 15, 10, 45, 0, 0, time.UTC),
+		"0 9-17 * * 1-5": time.Date(2020, time.October, 15, 11, 0, 0, 0, time.UTC),
+		"0 0 1 * *":      time.Date(2020, time.November, 1, 0, 0, 0, 0, time.UTC),
+		"30 2 * * 7":     time.Date(2020, time.October, 18, 2, 30, 0, 0, time.UTC),
+		"0 0 13 * 5":     time.Date(2020, time.October, 16, 0, 0, 0, 0, time.UTC),
+	} {
+		cron, err := parseCronExpression(expression, time.UTC)
+		equals(
+			err,
+			nil,
+			t,
+			fmt.Sprintf("Error. Failed to parse the cron expression %q", expression),
+		)
+		equals(
+			cron.next(now),
+			expected,
+			t,
+			fmt.Sprintf("Error. Invalid next rotation instant for the cron expression %q", expression),
+		)
+	}
+
+	for _, expression := range []string{"", "* * * *", "60 * * * *", "* * * * 8", "*/0 * * * *", "a * * * *"} {
+		_, err := parseCronExpression(expression, time.UTC)
+		equals(
+			err != nil,
+			true,
+			t,
+			fmt.Sprintf("Error. The cron expression %q should be invalid", expression),
+		)
+	}
+
+	_, err := New("", &Options{Schedule: "0 0 30 2 *"}, &Callback{})
+	equals(
+		err != nil,
+		true,
+		t,
+		"Error. A cron expression which never matches should be rejected",
+	)
+}
+
+func TestRotationBoundary(t *testing.T) {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("timezone database is not available")
+	}
+
+	for _, c := range []struct {
+		boundary RotationBoundary
+		now      time.Time
+		expected time.Time
+	}{
+		{Hourly, time.Date(2020, time.October, 15, 10, 30, 0, 0, location), time.Date(2020, time.October, 15, 11, 0, 0, 0, location)},
+		{Daily, time.Date(2020, time.October, 15, 10, 30, 0, 0, location), time.Date(2020, time.October, 16, 0, 0, 0, 0, location)},
+		{Weekly, time.Date(2020, time.October, 15, 10, 30, 0, 0, location), time.Date(2020, time.October, 19, 0, 0, 0, 0, location)},
+		{Weekly, time.Date(2020, time.October, 19, 0, 0, 0, 0, location), time.Date(2020, time.October, 26, 0, 0, 0, 0, location)},
+		{Monthly, time.Date(2020, time.December, 15, 10, 30, 0, 0, location), time.Date(2021, time.January, 1, 0, 0, 0, 0, location)},
+		// Daylight saving transition, the day is 25 hours long
+		{Daily, time.Date(2020, time.November, 1, 0, 30, 0, 0, location), time.Date(2020, time.November, 2, 0, 0, 0, 0, location)},
+	} {
+		equals(
+			(&boundarySchedule{boundary: c.boundary, location: location}).next(c.now),
+			c.expected,
+			t,
+			fmt.Sprintf("Error. Invalid next rotation instant for the %v boundary", c.boundary),
+		)
+	}
+
+	_, err = New("", &Options{RotationBoundary: Monthly + 1}, &Callback{})
+	equals(
+		err != nil,
+		true,
+		t,
+		"Error. An invalid rotation boundary should be rejected",
+	)
+}
+
+func TestLogger_Disable_Rotation(t *testing.T) {
+	logger, _ := New("", &Options{
+		Size:                  1,
+		DisableSizeRotation:   true,
+		DisablePeriodRotation: true,
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	equals(
+		scheduled(logger, "rotation"),
+		false,
+		t,
+		"Error. The period based rotation should not be scheduled",
+	)
+
+	// Writing more than the max file size in a single request
+	body := []byte(randStringBytes(2*1024*1024 + 1))
+	n, err := logger.Write(body)
+	equals(
+		err,
+		nil,
+		t,
+		"Error. The write request should not be limited by the max file size",
+	)
+
+	// Checking the size of the log file, it should not be rotated
+	fileInfo, _ := os.Stat(logger.Filename)
+	equals(
+		fileInfo.Size(),
+		int64(n),
+		t,
+		"Error. The log file should not be rotated",
+	)
+}
+
+func TestLogger_Rotate_Period_Reset(t *testing.T) {
+
+	var rotateCh = make(chan string, 10)
+	logger, _ := New("", &Options{
+		Period: time.Second,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// Rotating the log file manually just before the period elapses
+	time.Sleep(700 * time.Millisecond)
+	_, _ = logger.Write([]byte(randStringBytes(1024)))
+	equals(
+		logger.Rotate(),
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+	<-rotateCh
+
+	// The period should restart after the manual rotation
+	_, _ = logger.Write([]byte(randStringBytes(1024)))
+	time.Sleep(600 * time.Millisecond)
+	equals(
+		len(rotateCh),
+		0,
+		t,
+		"Error. The log file should not be rotated before a full period",
+	)
+
+	select {
+	case <-rotateCh:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Error. The log file should be rotated after the restarted period")
+	}
+}
+
+func TestLogger_Rotate_Auto_Period_MinSize(t *testing.T) {
+
+	var rotateCh = make(chan string, 10)
+	logger, _ := New("", &Options{
+		Period:  time.Second,
+		MinSize: 1,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// Waiting for the period to elapse without writing any log
+	time.Sleep(time.Second + 500*time.Millisecond)
+	equals(
+		len(rotateCh),
+		0,
+		t,
+		"Error. The empty log file should not be rotated",
+	)
+
+	log.SetOutput(logger)
+	log.Println(randStringBytes(1024))
+
+	// Checking for the existence of the rotated log file
+	select {
+	case file := <-rotateCh:
+		_, err := os.Stat(file)
+		equals(
+			os.IsNotExist(err),
+			false,
+			t,
+			"Error. The rotated log file should be created",
+		)
+	case <-time.After(2 * time.Second):
+		t.Logf("Error- The log file should be rotated")
+		t.FailNow()
+	}
+	_ = logger.Close()
+}
+
+func TestLogger_Rotate_Skip_Idle(t *testing.T) {
+
+	var rotateCh = make(chan string, 10)
+	logger, _ := New("", &Options{
+		DisablePeriodRotation: true,
+		SkipIdleRotation:      true,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	log.SetOutput(logger)
+	log.Println(randStringBytes(1024))
+
+	// The first timed rotation should rotate the written log file
+	logger.timedRotate()
+	_, err := os.Stat(<-rotateCh)
+	equals(
+		os.IsNotExist(err),
+		false,
+		t,
+		"Error. The rotated log file should be created",
+	)
+
+	// Nothing has been written since the previous rotation
+	logger.timedRotate()
+	time.Sleep(100 * time.Millisecond)
+	equals(
+		len(rotateCh),
+		0,
+		t,
+		"Error. The idle log file should not be rotated",
+	)
+}
+
+func TestLogger_RotateWithResult(t *testing.T) {
+
+	var rotateCh = make(chan string, 1)
+	logger, _ := New("", &Options{
+		Compress:         true,
+		CompressionLevel: 9,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	log.SetOutput(logger)
+	log.Println(randStringBytes(1024))
+
+	rotatedFile, err := logger.RotateWithResult()
+	equals(
+		err,
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+	equals(
+		filepath.Ext(rotatedFile),
+		".gz",
+		t,
+		"Error. The rotated log file should be compressed",
+	)
+
+	// The rotated file should be present before the callback is triggered
+	_, err = os.Stat(rotatedFile)
+	equals(
+		os.IsNotExist(err),
+		false,
+		t,
+		"Error. The rotated compressed log file should be created",
+	)
+	equals(
+		<-rotateCh,
+		rotatedFile,
+		t,
+		"Error. The callback should be triggered with the rotated log file",
+	)
+}
+
+func TestLogger_RotateWithResult_BundleBackups(t *testing.T) {
+
+	var rotateCh = make(chan string, 1)
+	logger, _ := New("", &Options{
+		Compress:      true,
+		BundleBackups: 2,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Shutdown(context.Background())
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// The first rotated log file is waiting for the bundle
+	_, _ = logger.Write([]byte("eidos\n"))
+	rotatedFile, err := logger.RotateWithResult()
+	equals(
+		err,
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+	equals(
+		strings.HasSuffix(rotatedFile, ".log"),
+		true,
+		t,
+		"Error. The rotated log file should not be bundled alone",
+	)
+
+	// The second rotated log file completes the bundle
+	_, _ = logger.Write([]byte("eidos\n"))
+	rotatedFile, err = logger.RotateWithResult()
+	equals(
+		err,
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+	equals(
+		strings.HasSuffix(rotatedFile, ".tar.gz"),
+		true,
+		t,
+		"Error. The returned file should be the bundle of the rotated log files",
+	)
+	_, err = os.Stat(rotatedFile)
+	equals(
+		err,
+		nil,
+		t,
+		"Error. The bundle should be created before returning",
+	)
+	equals(
+		<-rotateCh,
+		rotatedFile,
+		t,
+		"Error. The callback should be triggered with the bundle",
+	)
+}
+
+func TestLogger_Reopen(t *testing.T) {
+	logger, _ := New("", &Options{}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	log.SetOutput(logger)
+	log.Println(randStringBytes(1024))
+
+	// Moving the log file like an external log rotation tool
+	movedFile := logger.Filename + ".1"
+	_ = os.Rename(logger.Filename, movedFile)
+
+	equals(
+		logger.Reopen(),
+		nil,
+		t,
+		"Error. Failed to reopen the log file",
+	)
+	log.Println(randStringBytes(1024))
+
+	fileInfo, err := os.Stat(logger.Filename)
+	equals(
+		err,
+		nil,
+		t,
+		"Error. The log file should be created",
+	)
+	equals(
+		fileInfo.Size(),
+		int64(1045),
+		t,
+		"Error. The log file should only contain the logs written after reopen",
+	)
+	movedFileInfo, _ := os.Stat(movedFile)
+	equals(
+		movedFileInfo.Size(),
+		int64(1045),
+		t,
+		"Error. The moved log file should not be modified",
+	)
+}
+
+func TestLogger_Write_Detect_External_Rotation(t *testing.T) {
+	logger, _ := New("", &Options{
+		DetectExternalRotation: true,
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	log.SetOutput(logger)
+	log.Println(randStringBytes(1024))
+
+	// Removing the log file like an external tool
+	_ = os.Remove(logger.Filename)
+
+	// Waiting for the file check interval to elapse
+	time.Sleep(fileCheckInterval)
+	log.Println(randStringBytes(1024))
+
+	fileInfo, err := os.Stat(logger.Filename)
+	equals(
+		err,
+		nil,
+		t,
+		"Error. The log file should be recreated",
+	)
+	equals(
+		fileInfo.Size(),
+		int64(1045),
+		t,
+		"Error. The recreated log file should contain the logs written after the removal",
+	)
+}
+
+func TestLogger_Rotate_Marker(t *testing.T) {
+
+	var rotateCh = make(chan string, 1)
+	marker := filepath.Join(os.TempDir(), "eidos_logs", "eidos.rotate")
+	logger, _ := New("", &Options{
+		RotationMarker: marker,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	log.SetOutput(logger)
+	log.Println(randStringBytes(1024))
+
+	// Touching the marker file
+	_ = ioutil.WriteFile(marker, nil, 0644)
+
+	select {
+	case file := <-rotateCh:
+		_, err := os.Stat(file)
+		equals(
+			os.IsNotExist(err),
+			false,
+			t,
+			"Error. The rotated log file should be created",
+		)
+	case <-time.After(3 * markerCheckInterval):
+		t.Logf("Error- The log file should be rotated on touching the marker file")
+		t.FailNow()
+	}
+}
+
+func TestLogger_AdminHandler(t *testing.T) {
+
+	var rotateCh = make(chan string, 1)
+	logger, _ := New("", &Options{}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	log.SetOutput(logger)
+	log.Println(randStringBytes(1024))
+
+	handler := logger.AdminHandler()
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/status", nil))
+	var status adminStatus
+	_ = json.NewDecoder(recorder.Body).Decode(&status)
+	equals(
+		status.Size,
+		int64(1045),
+		t,
+		"Error. The status should contain the size of the log file",
+	)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/rotate", nil))
+	equals(
+		recorder.Code,
+		http.StatusMethodNotAllowed,
+		t,
+		"Error. The rotation should only be triggered by a POST request",
+	)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/rotate", nil))
+	equals(
+		recorder.Code,
+		http.StatusNoContent,
+		t,
+		"Error. Failed to rotate the log file through the admin handler",
+	)
+	_, err := os.Stat(<-rotateCh)
+	equals(
+		os.IsNotExist(err),
+		false,
+		t,
+		"Error. The rotated log file should be created",
+	)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/flush", nil))
+	equals(
+		recorder.Code,
+		http.StatusNoContent,
+		t,
+		"Error. Failed to flush the log file through the admin handler",
+	)
+}
+
+func TestParseSize(t *testing.T) {
+	for size, expected := range map[string]int64{
+		"100":    100,
+		"512KB":  512 * 1024,
+		"512kb":  512 * 1024,
+		"1.5GiB": 1536 * 1024 * 1024,
+		"100 MB": 100 * 1024 * 1024,
+		"2T":     2 * 1024 * 1024 * 1024 * 1024,
+	} {
+		bytes, err := ParseSize(size)
+		equals(
+			err,
+			nil,
+			t,
+			fmt.Sprintf("Error. Failed to parse the size %q", size),
+		)
+		equals(
+			bytes,
+			expected,
+			t,
+			fmt.Sprintf("Error. Invalid number of bytes for the size %q", size),
+		)
+	}
+
+	for _, size := range []string{"", "MB", "12XB", "1.2.3KB", "-1KB", "9223372036854775808", "8388608TB"} {
+		_, err := ParseSize(size)
+		equals(
+			err != nil,
+			true,
+			t,
+			fmt.Sprintf("Error. The size %q should be invalid", size),
+		)
+	}
+}
+
+func TestBackupCodec(t *testing.T) {
+	options := &Options{BackupTimeFormat: backupTimeFormat, Compress: true, BundleBackups: 2}
+	codec := newBackupCodec("/var/log/app.log", options)
+	rotationTime := time.Date(2020, 10, 15, 10, 30, 0, 0, time.UTC)
+
+	equals(
+		codec.format(rotationTime, "deploy"),
+		"app-2020-10-15T10-30-00.000-deploy.log",
+		t,
+		"Error. Invalid name of the rotated log file",
+	)
+
+	for name, expected := range map[string]parsedBackup{
+		"app-2020-10-15T10-30-00.000.log":           {time: rotationTime, suffix: ".log"},
+		"app-2020-10-15T10-30-00.000.log.gz":        {time: rotationTime, suffix: ".log.gz"},
+		"app-2020-10-15T10-30-00.000.log.sz":        {time: rotationTime, suffix: ".log.sz"},
+		"app-2020-10-15T10-30-00.000.tar.gz":        {time: rotationTime, suffix: ".tar.gz"},
+		"app-2020-10-15T10-30-00.000-1.log.gz":      {time: rotationTime, tag: "1", suffix: ".log.gz"},
+		"app-2020-10-15T10-30-00.000-deploy-1.log":  {time: rotationTime, tag: "deploy-1", suffix: ".log"},
+		"app-2020-10-15T10-30-00.000-v1.2.log.lz4":  {time: rotationTime, tag: "v1.2", suffix: ".log.lz4"},
+		"app-2020-10-15T10-30-00.000-deploy.log.xz": {time: rotationTime, tag: "deploy", suffix: ".log.xz"},
+	} {
+		parsed, err := codec.parse(name)
+		equals(
+			err,
+			nil,
+			t,
+			fmt.Sprintf("Error. Failed to parse the name %q", name),
+		)
+		equals(
+			parsed,
+			expected,
+			t,
+			fmt.Sprintf("Error. Invalid parts of the name %q", name),
+		)
+	}
+
+	for _, name := range []string{
+		"app.log", "app-.log", "app-worker.log", "app-2020-10-15T10-30-00.000.txt",
+		"application-2020-10-15T10-30-00.000.log", "app-2020-10-15T10-30-00.000.log.gz.tmp",
+		"app-2020-10-15T10-30-00.000.log.sha256", "app_2020-10-15T10-30-00.000.log",
+	} {
+		_, err := codec.parse(name)
+		equals(
+			err != nil,
+			true,
+			t,
+			fmt.Sprintf("Error. The name %q should not be a rotated log file", name),
+		)
+	}
+
+	for name, expected := range map[string]string{
+		"app-2020-10-15T10-30-00.000.log":    "app-2020-10-15T10-30-00.000.log",
+		"app-2020-10-15T10-30-00.000.log.gz": "app-2020-10-15T10-30-00.000.log",
+		"app-2020-10-15T10-30-00.000.tar.gz": "app-2020-10-15T10-30-00.000.tar.gz",
+	} {
+		equals(
+			codec.uncompressedName(name),
+			expected,
+			t,
+			fmt.Sprintf("Error. Invalid uncompressed name of %q", name),
+		)
+		equals(
+			codec.compressed(name),
+			name != expected || strings.HasSuffix(name, ".tar.gz"),
+			t,
+			fmt.Sprintf("Error. Invalid compression of %q", name),
+		)
+	}
+	equals(
+		codec.stem("app-2020-10-15T10-30-00.000.tar.gz"),
+		"app-2020-10-15T10-30-00.000",
+		t,
+		"Error. Invalid stem of the bundle",
+	)
+
+	// The sequence named backups are compressed with an extension after the sequence
+	sequenceCodec := newBackupCodec("app.log", &Options{BackupNaming: SequenceNaming})
+	equals(sequenceCodec.compressed("app.log.2.gz"), true, t, "Error. The backup should be compressed")
+	equals(sequenceCodec.compressed("app.log.2"), false, t, "Error. The backup should not be compressed")
+}
+
+func TestLogger_Rotate_Auto_SizeBytes(t *testing.T) {
+
+	var rotateCh = make(chan string, 1)
+	logger, _ := New("", &Options{
+		SizeBytes: 2048,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	log.SetOutput(logger)
+
+	body := randStringBytes(1024)
+	log.Println(body)
+	log.Println(body)
+
+	// Checking the size of the rotated log file
+	fileInfo, err := os.Stat(<-rotateCh)
+	equals(
+		err,
+		nil,
+		t,
+		"Error. The rotated log file should be created",
+	)
+	equals(
+		fileInfo.Size(),
+		int64(1045),
+		t,
+		"Error. The rotated log file should contain a single log",
+	)
+}
+
+func TestLogger_Rotate_Auto_Size_External_Append(t *testing.T) {
+
+	var rotateCh = make(chan string, 1)
+	logger, _ := New("", &Options{
+		SizeBytes: 4096,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	log.SetOutput(logger)
+
+	body := randStringBytes(1024)
+	log.Println(body)
+
+	// Appending to the log file like another process
+	file, _ := os.OpenFile(logger.Filename, os.O_APPEND|os.O_WRONLY, 0644)
+	_, _ = file.Write([]byte(randStringBytes(2048)))
+	_ = file.Close()
+
+	// Waiting for the size check interval to elapse
+	time.Sleep(sizeCheckInterval)
+	log.Println(body)
+
+	// Checking the size of the rotated log file
+	fileInfo, err := os.Stat(<-rotateCh)
+	equals(
+		err,
+		nil,
+		t,
+		"Error. The rotated log file should be created",
+	)
+	equals(
+		fileInfo.Size(),
+		int64(3093),
+		t,
+		"Error. The rotated log file should contain the log and the external append",
+	)
+}
+
+func TestLogger_Write_Split_Oversize(t *testing.T) {
+
+	var rotateCh = make(chan string, 10)
+	logger, _ := New("", &Options{
+		SizeBytes:           2048,
+		SplitOversizeWrites: true,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// Writing three lines of 1500 bytes in a single request
+	line := randStringBytes(1499) + "\n"
+	n, err := logger.Write([]byte(line + line + line))
+	equals(
+		err,
+		nil,
+		t,
+		"Error. The oversize write request should be split",
+	)
+	equals(
+		n,
+		3*len(line),
+		t,
+		"Error. The complete write request should be written",
+	)
+
+	// Every line should be written to a separate log file
+	for index := 0; index < 2; index++ {
+		fileInfo, _ := os.Stat(<-rotateCh)
+		equals(
+			fileInfo.Size(),
+			int64(len(line)),
+			t,
+			"Error. The rotated log file should contain a single line",
+		)
+	}
+	fileInfo, _ := os.Stat(logger.Filename)
+	equals(
+		fileInfo.Size(),
+		int64(len(line)),
+		t,
+		"Error. The log file should contain a single line",
+	)
+}
+
+func TestLogger_Write_Allow_Oversize(t *testing.T) {
+
+	var rotateCh = make(chan string, 1)
+	logger, _ := New("", &Options{
+		SizeBytes:           2048,
+		AllowOversizeWrites: true,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	body := []byte(randStringBytes(4096))
+	n, err := logger.Write(body)
+	equals(
+		err,
+		nil,
+		t,
+		"Error. The oversize write request should be allowed",
+	)
+
+	// The oversize write should be rotated immediately
+	fileInfo, _ := os.Stat(<-rotateCh)
+	equals(
+		fileInfo.Size(),
+		int64(n),
+		t,
+		"Error. The rotated log file should contain the complete write request",
+	)
+	fileInfo, _ = os.Stat(logger.Filename)
+	equals(
+		fileInfo.Size(),
+		int64(0),
+		t,
+		"Error. The log file should be empty",
+	)
+}
+
+func TestNewWithContext(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	logger, _ := NewWithContext(ctx, "", &Options{
+		Period: 200 * time.Millisecond,
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// Cancelling the context should stop the period based rotation
+	cancel()
+	_, _ = logger.Write([]byte(randStringBytes(1024)))
+	time.Sleep(600 * time.Millisecond)
+
+	files, _ := backupFiles(logger.Filename, logger.RotationOption)
+	equals(
+		len(files),
+		0,
+		t,
+		"Error. The log file should not be rotated after the context is cancelled",
+	)
+}
+
+func TestNewWithContext_Cancel_New(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	logger, _ := NewWithContext(ctx, "", &Options{}, &Callback{})
+
+	defer func() {
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// Cancelling the context should close the logger and release its log file
+	cancel()
+	logger.daemons.Wait()
+	_, err := logger.Write([]byte(randStringBytes(1024)))
+	equals(
+		errors.Is(err, ErrClosed),
+		true,
+		t,
+		"Error. The writes should fail after the context is cancelled",
+	)
+
+	reopened, err := New(logger.Filename, &Options{}, &Callback{})
+	equals(
+		err,
+		nil,
+		t,
+		"Error. The log file of the cancelled logger should be opened by a new logger",
+	)
+	_ = reopened.Close()
+}
+
+func TestNew_Validation_Errors(t *testing.T) {
+	for _, test := range []struct {
+		options *Options
+		err     error
+	}{
+		{&Options{Size: -1}, ErrInvalidSize},
+		{&Options{SizeBytes: -1}, ErrInvalidSize},
+		{&Options{MinSize: -1}, ErrInvalidSize},
+		{&Options{Period: -time.Hour}, ErrInvalidPeriod},
+		{&Options{RotationJitter: -time.Hour}, ErrInvalidPeriod},
+		{&Options{CompressionLevel: 100}, ErrInvalidCompressionLevel},
+		{&Options{RetentionPeriod: -1}, ErrInvalidRetention},
+		{&Options{MaxTotalSize: -1}, ErrInvalidRetention},
+		{&Options{CallbackRetries: -1}, ErrInvalidOption},
+		{&Options{BufferSize: -1}, ErrInvalidOption},
+		{&Options{FlushInterval: -1}, ErrInvalidOption},
+		{&Options{RateLimit: -1}, ErrInvalidOption},
+		{&Options{DedupWindow: -1}, ErrInvalidOption},
+		{&Options{WriteTimeout: -1}, ErrInvalidOption},
+		{&Options{Sampling: []SamplingRule{{Prefix: "DEBUG"}}}, ErrInvalidOption},
+		{&Options{Sampling: []SamplingRule{{Every: 2, Probability: 0.5}}}, ErrInvalidOption},
+		{&Options{Sampling: []SamplingRule{{Probability: 2}}}, ErrInvalidOption},
+		{&Options{Sampling: []SamplingRule{{Pattern: "(", Every: 2}}}, ErrInvalidOption},
+		{&Options{RateLimit: 1, RateLimitBurst: -1}, ErrInvalidOption},
+		{&Options{RateLimitUnit: RateLimitBytes + 1}, ErrInvalidOption},
+		{&Options{RateLimitPolicy: RateLimitBlock + 1}, ErrInvalidOption},
+		{&Options{Schedule: "* *"}, ErrInvalidOption},
+		{&Options{DailyFile: true, ManualRotation: true}, ErrIncompatibleOptions},
+	} {
+		_, err := New("", test.options, &Callback{})
+		equals(
+			errors.Is(err, test.err),
+			true,
+			t,
+			fmt.Sprintf("Error. The options %+v should be rejected with %v", *test.options, test.err),
+		)
+	}
+}
+
+func TestNew_ManualRotation(t *testing.T) {
+	logger, err := New("", &Options{
+		ManualRotation:   true,
+		RotationBoundary: Hourly,
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	equals(
+		err,
+		nil,
+		t,
+		"Failed to initialize the *Logger object",
+	)
+	equals(
+		logger.RotationOption.DisableSizeRotation && logger.RotationOption.DisablePeriodRotation,
+		true,
+		t,
+		"Error. The size and the period based rotations should be disabled",
+	)
+	equals(
+		scheduled(logger, "rotation"),
+		false,
+		t,
+		"Error. The period based rotation should not be scheduled",
+	)
+}
+
+func TestLogger_Rotate_Tagged(t *testing.T) {
+
+	var rotateCh = make(chan string, 1)
+	logger, _ := New("", &Options{
+		RetentionPeriod: 10,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	log.SetOutput(logger)
+	log.Println(randStringBytes(1024))
+
+	equals(
+		logger.Rotate("deploy v1.2"),
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+
+	rotatedFile := <-rotateCh
+	equals(
+		strings.HasSuffix(rotatedFile, "-deploy-v1.2.log"),
+		true,
+		t,
+		"Error. The tag should be embedded into the backup filename",
+	)
+
+	// The retention should parse the timestamp of the tagged backup file
+	logger.cleanUpOldLogs()
+	_, err := os.Stat(rotatedFile)
+	equals(
+		os.IsNotExist(err),
+		false,
+		t,
+		"Error. The tagged backup file should be retained",
+	)
+}
+
+func TestLogger_Rotate_Persisted_Deadline(t *testing.T) {
+
+	var rotateCh = make(chan string, 1)
+	filename := filepath.Join(os.TempDir(), "eidos_logs", "persisted.log")
+	_ = os.MkdirAll(filepath.Dir(filename), 0755)
+
+	// Writing a log file and a deadline which has passed while the process was not running
+	_ = ioutil.WriteFile(filename, []byte(randStringBytes(1024)), 0644)
+	_ = writeState(filename, state{NextRotation: time.Now().Add(-time.Hour)})
+
+	logger, _ := New(filename, &Options{
+		Period:                  time.Hour,
+		PersistRotationDeadline: true,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// The missed deadline should trigger an immediate rotation
+	select {
+	case file := <-rotateCh:
+		_, err := os.Stat(file)
+		equals(
+			os.IsNotExist(err),
+			false,
+			t,
+			"Error. The rotated log file should be created",
+		)
+	case <-time.After(2 * time.Second):
+		t.Logf("Error- The log file should be rotated on a missed deadline")
+		t.FailNow()
+	}
+
+	// Waiting for the next deadline to be persisted
+	time.Sleep(100 * time.Millisecond)
+	equals(
+		readState(filename).NextRotation.After(time.Now().Add(59*time.Minute)),
+		true,
+		t,
+		"Error. The next deadline should be persisted",
+	)
+}
+
+func TestLogger_MaintainState(t *testing.T) {
+
+	var rotateCh = make(chan string, 1)
+	logger, _ := New("", &Options{
+		MaintainState: true,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	log.SetOutput(logger)
+	log.Println(randStringBytes(1024))
+
+	equals(
+		logger.Rotate(),
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+	rotatedFile := <-rotateCh
+
+	log.Println(randStringBytes(1024))
+	equals(
+		logger.Close(),
+		nil,
+		t,
+		"Failed to close the Logger",
+	)
+
+	s := readState(logger.Filename)
+	equals(
+		s.Backups,
+		[]string{rotatedFile},
+		t,
+		"Error. The state should contain the rotated log file",
+	)
+	equals(
+		s.Size,
+		int64(1045),
+		t,
+		"Error. The state should contain the size of the log file",
+	)
+	equals(
+		s.LastRotation.IsZero(),
+		false,
+		t,
+		"Error. The state should contain the last rotation time",
+	)
+}
+
+func TestLogger_MinFreeDiskPercent(t *testing.T) {
+
+	var lowDiskSpaceCh = make(chan float64, 1)
+	logger, _ := New("", &Options{
+		MinFreeDiskPercent: 10,
+	}, &Callback{
+		LowDiskSpace: func(f float64) {
+			lowDiskSpaceCh <- f
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// Creating a fake old backup file
+	backup := filepath.Join(
+		filepath.Dir(logger.Filename),
+		fmt.Sprintf(
+			"%s-eidos-%s.log",
+			filepath.Base(os.Args[0]),
+			time.Now().Add(-time.Hour).Format(backupTimeFormat),
+		),
+	)
+	_ = ioutil.WriteFile(backup, []byte(randStringBytes(1024)), 0644)
+
+	// Faking a filesystem whose free space is recovered by removing the backup
+	logger.RotationOption.freeDiskPercent = func(string) (float64, error) {
+		if _, err := os.Stat(backup); err == nil {
+			return 5, nil
+		}
+		return 50, nil
+	}
+	logger.checkDiskSpace()
+	_, err := os.Stat(backup)
+	equals(
+		os.IsNotExist(err),
+		true,
+		t,
+		"Error. The old backup file should be removed to recover the free space",
+	)
+	equals(
+		len(lowDiskSpaceCh),
+		0,
+		t,
+		"Error. The low disk space callback should not be triggered",
+	)
+
+	// Faking a filesystem whose free space can not be recovered
+	logger.RotationOption.freeDiskPercent = func(string) (float64, error) {
+		return 5, nil
+	}
+	logger.checkDiskSpace()
+	equals(
+		<-lowDiskSpaceCh,
+		float64(5),
+		t,
+		"Error. The low disk space callback should be triggered",
+	)
+}
+
+func TestLogger_MinFreeDiskPercent_RetentionFilter(t *testing.T) {
+
+	var prunedCh = make(chan []PrunedBackup, 1)
+	logger, _ := New("", &Options{
+		MinFreeDiskPercent: 10,
+		RetentionFilter: func(info BackupInfo) bool {
+			return !strings.HasSuffix(info.File, "-protected.log")
+		},
+	}, &Callback{
+		Pruned: func(p []PrunedBackup) {
+			prunedCh <- p
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// Creating a fake protected backup file older than a fake backup file
+	backupFileName := func(age time.Duration, tag string) string {
+		return filepath.Join(
+			filepath.Dir(logger.Filename),
+			fmt.Sprintf(
+				"%s-eidos-%s%s.log",
+				filepath.Base(os.Args[0]),
+				time.Now().Add(-age).Format(backupTimeFormat),
+				tag,
+			),
+		)
+	}
+	protected, backup := backupFileName(2*time.Hour, "-protected"), backupFileName(time.Hour, "")
+	_ = ioutil.WriteFile(protected, []byte(randStringBytes(1024)), 0644)
+	_ = ioutil.WriteFile(backup, []byte(randStringBytes(1024)), 0644)
+
+	// Faking a filesystem whose free space is recovered by removing the backup
+	logger.RotationOption.freeDiskPercent = func(string) (float64, error) {
+		if _, err := os.Stat(backup); err == nil {
+			return 5, nil
+		}
+		return 50, nil
+	}
+	logger.checkDiskSpace()
+	_, err := os.Stat(protected)
+	equals(
+		err,
+		nil,
+		t,
+		"Error. The backup file protected by the RetentionFilter should be retained",
+	)
+	pruned := <-prunedCh
+	equals(
+		len(pruned) == 1 && pruned[0].File == backup && pruned[0].Reason == RetentionDiskSpace,
+		true,
+		t,
+		"Error. The backup file removed to recover the free space should be passed to the Pruned callback",
+	)
+}
+
+func TestNew_Multiple_Loggers(t *testing.T) {
+
+	// The loggers created with the same options should not interfere
+	options := &Options{ManualRotation: true}
+	var rotateCh = make(chan string, 2)
+	callback := &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	}
+	dir := filepath.Join(os.TempDir(), "eidos_logs")
+	first, _ := New(filepath.Join(dir, "first.log"), options, callback)
+	second, _ := New(filepath.Join(dir, "second.log"), options, callback)
+
+	defer func() {
+		// Closing the loggers to clean up the log directory
+		_ = first.Close()
+		_ = second.Close()
+		// Cleaning up the log directory
+		_ = clean(dir)
+	}()
+
+	equals(
+		options.Period == 0 && options.CallbackQueueSize == 0 && first.RotationOption != second.RotationOption,
+		true,
+		t,
+		"Error. The defaults should not be applied to the shared options",
+	)
+
+	// The clock of a logger should not affect the other logger
+	first.RotationOption.clock = func() time.Time { return time.Now().Add(-24 * time.Hour) }
+	_, _ = first.Write([]byte(randStringBytes(1024)))
+	_, _ = second.Write([]byte(randStringBytes(1024)))
+	equals(
+		first.Rotate() == nil && second.Rotate() == nil,
+		true,
+		t,
+		"Error. Failed to rotate the log files manually",
+	)
+
+	today := time.Now().UTC().Format(dailyFileTimeFormat)
+	for i := 0; i < 2; i++ {
+		file := <-rotateCh
+		switch {
+		case strings.HasPrefix(filepath.Base(file), "first-"):
+			equals(
+				strings.Contains(file, today),
+				false,
+				t,
+				"Error. The backup file should be named with the clock of its logger",
+			)
+		case strings.HasPrefix(filepath.Base(file), "second-"):
+			equals(
+				strings.Contains(file, today),
+				true,
+				t,
+				"Error. The backup file should be named with the clock of its logger",
+			)
+		default:
+			t.Fatalf("Error. Unexpected rotated file %s", file)
+		}
+	}
+}
+
+func TestLogger_Rotate_Collision(t *testing.T) {
+
+	var rotateCh = make(chan string, 3)
+	logger, _ := New("", &Options{ManualRotation: true}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// Freezing the time, so all the rotations have the same timestamp
+	now := time.Now()
+	logger.RotationOption.clock = func() time.Time {
+		return now
+	}
+
+	log.SetOutput(logger)
+	for index := 0; index < 3; index++ {
+		log.Println(randStringBytes(1024))
+		equals(
+			logger.Rotate(),
+			nil,
+			t,
+			"Error. Failed to rotate the log file manually",
+		)
+	}
+
+	// Every rotation should produce a separate backup file
+	rotatedFiles := map[string]bool{}
+	for index := 0; index < 3; index++ {
+		file := <-rotateCh
+		fileInfo, err := os.Stat(file)
+		equals(
+			err,
+			nil,
+			t,
+			"Error. The rotated log file should be created",
+		)
+		equals(
+			fileInfo.Size(),
+			int64(1045),
+			t,
+			"Error. The rotated log file should not be overwritten",
+		)
+		rotatedFiles[file] = true
+	}
+	equals(
+		len(rotatedFiles),
+		3,
+		t,
+		"Error. The rotated log files should have unique names",
+	)
+}
+
+func TestLogger_Rotate_Collision_Order(t *testing.T) {
+
+	logger, _ := New("", &Options{
+		ManualRotation:   true,
+		BackupTimeFormat: "2006-01-02",
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// Freezing the time, so all the rotations have the same timestamp
+	now := time.Now()
+	logger.RotationOption.clock = func() time.Time {
+		return now
+	}
+
+	// Writing logs of increasing size, so the backups can be identified
+	for index := 1; index <= 12; index++ {
+		_, _ = logger.Write([]byte(randStringBytes(index * 10)))
+		equals(
+			logger.Rotate(),
+			nil,
+			t,
+			"Error. Failed to rotate the log file manually",
+		)
+	}
+
+	// The backups should be listed in the order of the rotations, although
+	// app-T-1.log and app-T-10.log sort before app-T.log and app-T-2.log as text
+	backups, err := logger.Backups()
+	equals(
+		err,
+		nil,
+		t,
+		"Error. Failed to list the backups",
+	)
+	equals(
+		len(backups),
+		12,
+		t,
+		"Error. Every rotation should produce a backup",
+	)
+	for index, backup := range backups {
+		equals(
+			backup.Size,
+			int64((index+1)*10),
+			t,
+			fmt.Sprintf("Error. The backup %s should be listed in the order of the rotations", backup.File),
+		)
+	}
+}
+
+func TestLogger_Rotate_Collision_No_Extension(t *testing.T) {
+
+	logDir, _ := ioutil.TempDir("", "eidos_collision")
+	logger, _ := New(filepath.Join(logDir, "app"), &Options{ManualRotation: true}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(logDir)
+	}()
+
+	// Freezing the time, so all the rotations have the same timestamp
+	now := time.Now()
+	logger.RotationOption.clock = func() time.Time {
+		return now
+	}
+
+	for index := 1; index <= 4; index++ {
+		_, _ = logger.Write([]byte(randStringBytes(index * 10)))
+		equals(
+			logger.Rotate(),
+			nil,
+			t,
+			"Error. Failed to rotate the log file manually",
+		)
+	}
+
+	// The sequence suffix should follow the timestamp, so the backups are parsed
+	backups, err := logger.Backups()
+	equals(
+		err,
+		nil,
+		t,
+		"Error. Failed to list the backups",
+	)
+	equals(
+		len(backups),
+		4,
+		t,
+		"Error. Every backup of the log file without an extension should be listed",
+	)
+	timeStamp := now.UTC().Format(backupTimeFormat)
+	for index, backup := range backups {
+		name := "app-" + timeStamp
+		if index > 0 {
+			name += fmt.Sprintf("-%d", index)
+		}
+		equals(
+			filepath.Base(backup.File),
+			name,
+			t,
+			"Error. The sequence suffix should be appended after the timestamp",
+		)
+	}
+}
+
+func TestLogger_Rotate_SequenceNaming(t *testing.T) {
+
+	var rotateCh = make(chan string, 1)
+	logger, _ := New("", &Options{
+		BackupNaming: SequenceNaming,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// Writing logs of increasing size, so the backups can be identified
+	for index := 1; index <= 3; index++ {
+		_, _ = logger.Write([]byte(randStringBytes(index * 100)))
+		equals(
+			logger.Rotate(),
+			nil,
+			t,
+			"Error. Failed to rotate the log file manually",
+		)
+		equals(
+			<-rotateCh,
+			logger.Filename+".1",
+			t,
+			"Error. The newest backup should be named with the sequence number 1",
+		)
+	}
+
+	// The oldest backup should have the highest sequence number
+	for index := 1; index <= 3; index++ {
+		fileInfo, err := os.Stat(fmt.Sprintf("%s.%d", logger.Filename, index))
+		equals(
+			err,
+			nil,
+			t,
+			"Error. The sequence named backup should be present",
+		)
+		equals(
+			fileInfo.Size(),
+			int64((4-index)*100),
+			t,
+			"Error. The sequence named backups should be shifted on every rotation",
+		)
+	}
+}
+
+func TestLogger_Rotate_BackupTimeFormat(t *testing.T) {
+
+	var rotateCh = make(chan string, 1)
+	logger, _ := New("", &Options{
+		BackupTimeFormat: "2006-01-02",
+		RetentionPeriod:  10,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	log.SetOutput(logger)
+	log.Println(randStringBytes(1024))
+	equals(
+		logger.Rotate("deploy"),
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+
+	rotatedFile := <-rotateCh
+	equals(
+		filepath.Base(rotatedFile),
+		fmt.Sprintf("%s-eidos-%s-deploy.log", filepath.Base(os.Args[0]), time.Now().UTC().Format("2006-01-02")),
+		t,
+		"Error. The backup filename should use the requested time format",
+	)
+
+	// The retention should parse the timestamp of the backup file
+	logger.cleanUpOldLogs()
+	_, err := os.Stat(rotatedFile)
+	equals(
+		os.IsNotExist(err),
+		false,
+		t,
+		"Error. The backup file should be retained",
+	)
+
+	for _, format := range []string{"2006/01/02", "eidos"} {
+		_, err = New("", &Options{BackupTimeFormat: format}, &Callback{})
+		equals(
+			err != nil,
+			true,
+			t,
+			fmt.Sprintf("Error. The backup time format %q should be invalid", format),
+		)
+	}
+}
+
+func TestLogger_Rotate_BackupTimeFormat_Order(t *testing.T) {
+
+	logger, _ := New("", &Options{
+		ManualRotation:   true,
+		BackupTimeFormat: "02-01-2006",
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// Rotating on the last day of a month and the first days of the next one,
+	// so the day first timestamps do not sort as text, ex- 01-11 < 31-10
+	now := time.Date(2020, 10, 31, 12, 0, 0, 0, time.UTC)
+	logger.RotationOption.clock = func() time.Time {
+		return now
+	}
+	for index := 1; index <= 3; index++ {
+		_, _ = logger.Write([]byte(randStringBytes(index * 10)))
+		equals(
+			logger.Rotate(),
+			nil,
+			t,
+			"Error. Failed to rotate the log file manually",
+		)
+		now = now.AddDate(0, 0, 1)
+	}
+
+	backups, err := logger.Backups()
+	equals(
+		err,
+		nil,
+		t,
+		"Error. Failed to list the backups",
+	)
+	equals(
+		len(backups),
+		3,
+		t,
+		"Error. Every rotation should produce a backup",
+	)
+	for index, backup := range backups {
+		equals(
+			backup.Size,
+			int64((index+1)*10),
+			t,
+			fmt.Sprintf("Error. The backup %s should be ordered by the parsed timestamp", backup.File),
+		)
+	}
+}
+
+func TestLogger_Rotate_DatePartitioned(t *testing.T) {
+
+	var rotateCh = make(chan string, 1)
+	logger, _ := New("", &Options{
+		DatePartitionedBackups: true,
+		RetentionPeriod:        10,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	log.SetOutput(logger)
+	log.Println(randStringBytes(1024))
+	equals(
+		logger.Rotate(),
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+
+	now := time.Now().UTC()
+	rotatedFile := <-rotateCh
+	equals(
+		filepath.Dir(rotatedFile),
+		filepath.Join(filepath.Dir(logger.Filename), now.Format("2006"), now.Format("01"), now.Format("02")),
+		t,
+		"Error. The rotated log file should be placed under the date partition",
+	)
+
+	// Creating a fake month old backup file under its date partition
+	monthOld := now.Add(-31 * 24 * time.Hour)
+	monthOldDir := filepath.Join(filepath.Dir(logger.Filename), monthOld.Format("2006"), monthOld.Format("01"), monthOld.Format("02"))
+	_ = os.MkdirAll(monthOldDir, 0755)
+	monthOldFile := filepath.Join(
+		monthOldDir,
+		fmt.Sprintf("%s-eidos-%s.log", filepath.Base(os.Args[0]), monthOld.Format(backupTimeFormat)),
+	)
+	_ = ioutil.WriteFile(monthOldFile, []byte(randStringBytes(1024)), 0644)
+
+	backups, _ := backupFiles(logger.Filename, logger.RotationOption)
+	equals(
+		len(backups),
+		2,
+		t,
+		"Error. The backup listing should traverse the date partitions",
+	)
+
+	logger.cleanUpOldLogs()
+	_, err := os.Stat(monthOldDir)
+	equals(
+		os.IsNotExist(err),
+		true,
+		t,
+		"Error. The month old backup file and its date partition should be removed",
+	)
+	_, err = os.Stat(rotatedFile)
+	equals(
+		os.IsNotExist(err),
+		false,
+		t,
+		"Error. The recent backup file should be retained",
+	)
+}
+
+func TestLogger_Rotate_DailyFile(t *testing.T) {
+
+	var rotateCh = make(chan string, 1)
+	var clockMutex sync.Mutex
+	now := time.Now().UTC()
+	currentTime = func() time.Time {
+		clockMutex.Lock()
+		defer clockMutex.Unlock()
+		return now
+	}
+	defer func() { currentTime = time.Now }()
+
+	logger, _ := New("", &Options{
+		DailyFile: true,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	log.SetOutput(logger)
+	log.Println(randStringBytes(1024))
+
+	// The logs should be written directly to the file of the current day
+	todayFile := filepath.Join(
+		filepath.Dir(logger.Filename),
+		fmt.Sprintf("%s-eidos-%s.log", filepath.Base(os.Args[0]), now.Format(dailyFileTimeFormat)),
+	)
+	fileInfo, err := os.Stat(todayFile)
+	equals(
+		err == nil && fileInfo.Size() > 0,
+		true,
+		t,
+		"Error. The logs should be written to the daily log file",
+	)
+
+	// A rotation within the same day should reopen the same file
+	equals(
+		logger.Rotate(),
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+	log.Println(randStringBytes(1024))
+	newFileInfo, _ := os.Stat(todayFile)
+	equals(
+		newFileInfo.Size() > fileInfo.Size(),
+		true,
+		t,
+		"Error. A rotation within the same day should append to the same file",
+	)
+
+	// The rotation on the next day should open the next day's file
+	clockMutex.Lock()
+	now = now.Add(24 * time.Hour)
+	clockMutex.Unlock()
+	equals(
+		logger.Rotate(),
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+	equals(
+		<-rotateCh,
+		todayFile,
+		t,
+		"Error. The previous day's file should be the rotated log file without renaming",
+	)
+	logger.mutex.Lock()
+	dailyFileName := logger.dailyFileName
+	logger.mutex.Unlock()
+	_, err = os.Stat(dailyFileName)
+	equals(
+		err == nil && dailyFileName != todayFile,
+		true,
+		t,
+		"Error. The next day's file should be opened",
+	)
+
+	// The active daily file should not be listed as a backup
+	backups, _ := backupFiles(logger.Filename, logger.RotationOption)
+	equals(
+		len(backups),
+		1,
+		t,
+		"Error. Only the previous day's file should be a backup",
+	)
+}
+
+func TestLogger_FileHeader(t *testing.T) {
+
+	var rotateCh = make(chan string, 1)
+	header := "host: eidos-test\n"
+	logger, _ := New("", &Options{
+		FileHeader: func() []byte {
+			return []byte(header)
+		},
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	log.SetOutput(logger)
+	log.Println(randStringBytes(1024))
+	equals(
+		logger.Rotate(),
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+
+	// Both the rotated and the new log file should start with the header
+	content, _ := ioutil.ReadFile(<-rotateCh)
+	equals(
+		strings.HasPrefix(string(content), header) && strings.Count(string(content), header) == 1,
+		true,
+		t,
+		"Error. The rotated log file should start with the header",
+	)
+	content, _ = ioutil.ReadFile(logger.Filename)
+	equals(
+		string(content),
+		header,
+		t,
+		"Error. The new log file should start with the header",
+	)
+	equals(
+		logger.size,
+		int64(len(header)),
+		t,
+		"Error. The header should be accounted in the size of the log file",
+	)
+
+	// The header should not be written again to the reopened log file
+	equals(
+		logger.Reopen(),
+		nil,
+		t,
+		"Error. Failed to reopen the log file",
+	)
+	content, _ = ioutil.ReadFile(logger.Filename)
+	equals(
+		string(content),
+		header,
+		t,
+		"Error. The header should not be written to a non-empty log file",
+	)
+}
+
+func TestLogger_FileFooter(t *testing.T) {
+
+	var rotateCh = make(chan string, 1)
+	logger, _ := New("", &Options{
+		FileHeader: func() []byte {
+			return []byte("header\n")
+		},
+		FileFooter: func(stats FileStats) []byte {
+			return []byte(fmt.Sprintf("lines=%d bytes=%d\n", stats.Lines, stats.Bytes))
+		},
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	_, _ = logger.Write([]byte("first line\nsecond line\n"))
+	_, _ = logger.Write([]byte("third line\n"))
+	equals(
+		logger.Rotate(),
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+
+	// The rotated log file should end with the summary of the writes
+	content, _ := ioutil.ReadFile(<-rotateCh)
+	equals(
+		string(content),
+		"header\nfirst line\nsecond line\nthird line\nlines=3 bytes=34\n",
+		t,
+		"Error. The rotated log file should end with the footer",
+	)
+
+	// The statistics should be reset for the new log file
+	equals(
+		logger.stats,
+		FileStats{},
+		t,
+		"Error. The statistics should be reset for the new log file",
+	)
+}
+
+func TestLogger_Rotate_CompressionFormat(t *testing.T) {
+	for format, magic := range map[CompressionFormat][]byte{
+		LZ4:    {0x04, 0x22, 0x4D, 0x18},
+		Snappy: snappyStreamIdentifier,
+		XZ:     {0xFD, '7', 'z', 'X', 'Z', 0x00},
+		Zip:    {'P', 'K', 0x03, 0x04},
+	} {
+		var rotateCh = make(chan string, 1)
+		logger, _ := New("", &Options{
+			Compress:          true,
+			CompressionFormat: format,
+		}, &Callback{
+			Execute: func(s string) {
+				rotateCh <- s
+			},
+		})
+
+		log.SetOutput(logger)
+		log.Println(randStringBytes(1024))
+		equals(
+			logger.Rotate(),
+			nil,
+			t,
+			"Error. Failed to rotate the log file manually",
+		)
+
+		// The rotated log file should be compressed in the requested format
+		rotatedFile := <-rotateCh
+		content, _ := ioutil.ReadFile(rotatedFile)
+		equals(
+			strings.HasSuffix(rotatedFile, format.extension()) && bytes.HasPrefix(content, magic),
+			true,
+			t,
+			fmt.Sprintf("Error. The rotated log file should be compressed in the %v format", format),
+		)
+
+		// The zip archive should hold the rotated log file
+		if format == Zip {
+			archive, err := zip.OpenReader(rotatedFile)
+			equals(
+				err == nil && len(archive.File) == 1 && archive.File[0].Name == strings.TrimSuffix(filepath.Base(rotatedFile), ".zip"),
+				true,
+				t,
+				"Error. The zip archive should hold the rotated log file",
+			)
+			_ = archive.Close()
+		}
+
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}
+}
+
+// lz4DecodeBlock decodes the block as per the lz4 block format description
+func lz4DecodeBlock(block []byte) ([]byte, error) {
+	var decoded []byte
+	i := 0
+	readLength := func(length int) (int, error) {
+		if length < 15 {
+			return length, nil
+		}
+		for {
+			if i >= len(block) {
+				return 0, io.ErrUnexpectedEOF
+			}
+			b := block[i]
+			i++
+			length += int(b)
+			if b != 255 {
+				return length, nil
+			}
+		}
+	}
+	for i < len(block) {
+		token := block[i]
+		i++
+		literalLength, err := readLength(int(token >> 4))
+		if err != nil {
+			return nil, err
+		}
+		if i+literalLength > len(block) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		decoded = append(decoded, block[i:i+literalLength]...)
+		i += literalLength
+		// The last sequence holds only the literals
+		if i == len(block) {
+			return decoded, nil
+		}
+		if i+2 > len(block) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		offset := int(block[i]) | int(block[i+1])<<8
+		i += 2
+		matchLength, err := readLength(int(token & 0x0f))
+		if err != nil {
+			return nil, err
+		}
+		if offset == 0 || offset > len(decoded) {
+			return nil, fmt.Errorf("invalid lz4 match offset %d", offset)
+		}
+		for matchLength += 4; matchLength > 0; matchLength-- {
+			decoded = append(decoded, decoded[len(decoded)-offset])
+		}
+	}
+	return nil, fmt.Errorf("lz4 block does not end with the literals")
+}
+
+// lz4DecodeFrame decodes the frame as per the lz4 frame format description,
+// only the descriptors of the frames written by the lz4Writer are supported
+func lz4DecodeFrame(frame []byte) ([]byte, error) {
+	if len(frame) < 7 || binary.LittleEndian.Uint32(frame) != lz4Magic {
+		return nil, fmt.Errorf("invalid lz4 magic number")
+	}
+	if frame[4] != 0x60 || frame[5] != 0x70 || frame[6] != byte(xxh32(frame[4:6])>>8) {
+		return nil, fmt.Errorf("invalid lz4 frame descriptor")
+	}
+	var decoded []byte
+	for i := 7; ; {
+		if i+4 > len(frame) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		size := binary.LittleEndian.Uint32(frame[i:])
+		i += 4
+		if size == 0 {
+			if i != len(frame) {
+				return nil, fmt.Errorf("trailing data after the lz4 end mark")
+			}
+			return decoded, nil
+		}
+		length := int(size &^ (1 << 31))
+		if length > lz4BlockSize || i+length > len(frame) {
+			return nil, fmt.Errorf("invalid lz4 block size %d", length)
+		}
+		block := frame[i : i+length]
+		i += length
+		if size&(1<<31) != 0 {
+			decoded = append(decoded, block...)
+			continue
+		}
+		data, err := lz4DecodeBlock(block)
+		if err != nil {
+			return nil, err
+		}
+		decoded = append(decoded, data...)
+	}
+}
+
+// snappyDecodeBlock decodes the block as per the snappy format description
+func snappyDecodeBlock(block []byte) ([]byte, error) {
+	length, n := binary.Uvarint(block)
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid snappy block length")
+	}
+	var decoded []byte
+	for i := n; i < len(block); {
+		tag := block[i]
+		i++
+		var offset, copyLength int
+		switch tag & 0x03 {
+		case 0x00:
+			literalLength := int(tag>>2) + 1
+			if extra := literalLength - 60; extra > 0 {
+				if i+extra > len(block) {
+					return nil, io.ErrUnexpectedEOF
+				}
+				literalLength = 0
+				for b := 0; b < extra; b++ {
+					literalLength |= int(block[i+b]) << (8 * b)
+				}
+				literalLength++
+				i += extra
+			}
+			if literalLength <= 0 || i+literalLength > len(block) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			decoded = append(decoded, block[i:i+literalLength]...)
+			i += literalLength
+			continue
+		case 0x01:
+			if i+1 > len(block) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			copyLength, offset = int(tag>>2&0x07)+4, int(tag>>5)<<8|int(block[i])
+			i++
+		case 0x02:
+			if i+2 > len(block) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			copyLength, offset = int(tag>>2)+1, int(binary.LittleEndian.Uint16(block[i:]))
+			i += 2
+		default:
+			if i+4 > len(block) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			copyLength, offset = int(tag>>2)+1, int(binary.LittleEndian.Uint32(block[i:]))
+			i += 4
+		}
+		if offset == 0 || offset > len(decoded) {
+			return nil, fmt.Errorf("invalid snappy copy offset %d", offset)
+		}
+		for ; copyLength > 0; copyLength-- {
+			decoded = append(decoded, decoded[len(decoded)-offset])
+		}
+	}
+	if uint64(len(decoded)) != length {
+		return nil, fmt.Errorf("invalid snappy block length %d, expected %d", len(decoded), length)
+	}
+	return decoded, nil
+}
+
+// snappyDecodeStream decodes the stream as per the snappy framing format
+// description, verifying the masked crc32c of every chunk
+func snappyDecodeStream(stream []byte) ([]byte, error) {
+	if !bytes.HasPrefix(stream, snappyStreamIdentifier) {
+		return nil, fmt.Errorf("invalid snappy stream identifier")
+	}
+	var decoded []byte
+	for i := len(snappyStreamIdentifier); i < len(stream); {
+		if i+8 > len(stream) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		chunkType := stream[i]
+		length := int(stream[i+1]) | int(stream[i+2])<<8 | int(stream[i+3])<<16
+		if length < 4 || i+4+length > len(stream) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		checksum := binary.LittleEndian.Uint32(stream[i+4:])
+		chunk := stream[i+8 : i+4+length]
+		i += 4 + length
+
+		var data []byte
+		switch chunkType {
+		case 0x00:
+			var err error
+			if data, err = snappyDecodeBlock(chunk); err != nil {
+				return nil, err
+			}
+		case 0x01:
+			data = chunk
+		default:
+			return nil, fmt.Errorf("unexpected snappy chunk type %#x", chunkType)
+		}
+		if len(data) > snappyChunkSize {
+			return nil, fmt.Errorf("snappy chunk of %d bytes exceeds the chunk size", len(data))
+		}
+		crc := crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli))
+		if (crc>>15|crc<<17)+0xa282ead8 != checksum {
+			return nil, fmt.Errorf("invalid snappy chunk checksum")
+		}
+		decoded = append(decoded, data...)
+	}
+	return decoded, nil
+}
+
+// compressionSamples returns the inputs of the compression tests, which
+// cover the empty input, the incompressible data, the long matches and
+// the inputs spanning multiple blocks of every compression format
+func compressionSamples() map[string][]byte {
+	random := rand.New(rand.NewSource(1))
+	incompressible := make([]byte, 5<<20)
+	random.Read(incompressible)
+
+	// The short literals and the copies of the earlier data at random offsets
+	var mixed []byte
+	for len(mixed) < 300000 {
+		if random.Intn(3) == 0 && len(mixed) > 100 {
+			start := random.Intn(len(mixed) - 64)
+			mixed = append(mixed, mixed[start:start+random.Intn(64)]...)
+			continue
+		}
+		literal := make([]byte, random.Intn(40))
+		random.Read(literal)
+		mixed = append(mixed, literal...)
+	}
+
+	return map[string][]byte{
+		"empty":          nil,
+		"byte":           []byte("e"),
+		"line":           []byte("eidos\n"),
+		"lines":          []byte(strings.Repeat("eidos rotates the log files\n", 300000)),
+		"zeros":          make([]byte, 5<<20),
+		"incompressible": incompressible,
+		"mixed":          mixed,
+	}
+}
+
+func TestCompressBlock(t *testing.T) {
+	data := []byte(strings.Repeat("eidos rotates the log files\n", 1000) + randStringBytes(1024))
+
+	decoded, err := lz4DecodeBlock(lz4CompressBlock(nil, data))
+	equals(
+		err == nil && bytes.Equal(decoded, data),
+		true,
+		t,
+		"Error. The lz4 block should be decoded to the original data",
+	)
+
+	decoded, err = snappyDecodeBlock(snappyCompressBlock(nil, data))
+	equals(
+		err == nil && bytes.Equal(decoded, data),
+		true,
+		t,
+		"Error. The snappy block should be decoded to the original data",
+	)
+}
+
+func TestLZ4Writer(t *testing.T) {
+	// The frames are decoded by the lz4 tool, if it is installed
+	lz4, lookErr := exec.LookPath("lz4")
+	for name, data := range compressionSamples() {
+		var compressed bytes.Buffer
+		writer := newLZ4Writer(&compressed)
+		_, _ = writer.Write(data)
+		equals(
+			writer.Close(),
+			nil,
+			t,
+			"Error. Failed to close the lz4 writer",
+		)
+
+		decoded, err := lz4DecodeFrame(compressed.Bytes())
+		equals(
+			err == nil && bytes.Equal(decoded, data),
+			true,
+			t,
+			fmt.Sprintf("Error. The lz4 frame of the %s sample should be decoded to the original data", name),
+		)
+		if lookErr != nil {
+			continue
+		}
+		cmd := exec.Command(lz4, "--decompress", "--stdout")
+		cmd.Stdin = bytes.NewReader(compressed.Bytes())
+		decompressed, err := cmd.Output()
+		equals(
+			err == nil && bytes.Equal(decompressed, data),
+			true,
+			t,
+			fmt.Sprintf("Error. The lz4 frame of the %s sample should be decompressed by the lz4 tool", name),
+		)
+	}
+}
+
+func TestSnappyWriter(t *testing.T) {
+	// The streams are decoded by the snzip tool, if it is installed
+	snzip, lookErr := exec.LookPath("snzip")
+	for name, data := range compressionSamples() {
+		var compressed bytes.Buffer
+		writer := newSnappyWriter(&compressed)
+		_, _ = writer.Write(data)
+		equals(
+			writer.Close(),
+			nil,
+			t,
+			"Error. Failed to close the snappy writer",
+		)
+
+		decoded, err := snappyDecodeStream(compressed.Bytes())
+		equals(
+			err == nil && bytes.Equal(decoded, data),
+			true,
+			t,
+			fmt.Sprintf("Error. The snappy stream of the %s sample should be decoded to the original data", name),
+		)
+		if lookErr != nil {
+			continue
+		}
+		cmd := exec.Command(snzip, "-d", "-c", "-t", "framing2")
+		cmd.Stdin = bytes.NewReader(compressed.Bytes())
+		decompressed, err := cmd.Output()
+		equals(
+			err == nil && bytes.Equal(decompressed, data),
+			true,
+			t,
+			fmt.Sprintf("Error. The snappy stream of the %s sample should be decompressed by the snzip tool", name),
+		)
+	}
+}
+
+func TestXZWriter(t *testing.T) {
+	xz, err := exec.LookPath("xz")
+	if err != nil {
+		t.Skip("xz is not installed")
+	}
+
+	for name, data := range compressionSamples() {
+		var compressed bytes.Buffer
+		writer := newXZWriter(&compressed)
+		_, _ = writer.Write(data)
+		equals(
+			writer.Close(),
+			nil,
+			t,
+			"Error. Failed to close the xz writer",
+		)
+
+		// Testing the integrity and decompressing the stream using the xz tool
+		cmd := exec.Command(xz, "--test", "--format=xz")
+		cmd.Stdin = bytes.NewReader(compressed.Bytes())
+		equals(
+			cmd.Run(),
+			nil,
+			t,
+			fmt.Sprintf("Error. The xz stream of the %s sample should pass the integrity test", name),
+		)
+		cmd = exec.Command(xz, "--decompress", "--stdout")
+		cmd.Stdin = bytes.NewReader(compressed.Bytes())
+		decompressed, err := cmd.Output()
+		equals(
+			err == nil && bytes.Equal(decompressed, data),
+			true,
+			t,
+			fmt.Sprintf("Error. The xz stream of the %s sample should be decompressed to the original data", name),
+		)
+	}
+}
+
+func TestCompressionFormat_Golden(t *testing.T) {
+	// The golden files are written by the encoders and verified with the
+	// reference tools, "lz4 -d" and "xz -t", and with the decoders of the
+	// format descriptions, so a change to an encoder is caught and has to
+	// be verified again, even on the hosts without the reference tools.
+	// The reference tools, including "snzip", decode them when installed.
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "golden.log"))
+	equals(
+		err,
+		nil,
+		t,
+		"Error. Failed to read the golden log file",
+	)
+
+	for _, test := range []struct {
+		format CompressionFormat
+		decode func([]byte) ([]byte, error)
+		tool   []string
+	}{
+		{LZ4, lz4DecodeFrame, []string{"lz4", "--decompress", "--stdout"}},
+		{Snappy, snappyDecodeStream, []string{"snzip", "-d", "-c", "-t", "framing2"}},
+		{XZ, nil, []string{"xz", "--decompress", "--stdout"}},
+	} {
+		golden, err := ioutil.ReadFile(filepath.Join("testdata", "golden.log"+test.format.extension()))
+		equals(
+			err,
+			nil,
+			t,
+			fmt.Sprintf("Error. Failed to read the golden %s file", test.format),
+		)
+
+		var compressed bytes.Buffer
+		writer, _ := test.format.newWriter(&compressed, gzip.Header{}, 0, 0)
+		_, _ = writer.Write(data)
+		_ = writer.Close()
+		equals(
+			bytes.Equal(compressed.Bytes(), golden),
+			true,
+			t,
+			fmt.Sprintf("Error. The %s encoder should produce the verified golden file", test.format),
+		)
+		if test.decode != nil {
+			decoded, err := test.decode(golden)
+			equals(
+				err == nil && bytes.Equal(decoded, data),
+				true,
+				t,
+				fmt.Sprintf("Error. The golden %s file should be decoded to the golden log file", test.format),
+			)
+		}
+
+		if _, err := exec.LookPath(test.tool[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(test.tool[0], test.tool[1:]...)
+		cmd.Stdin = bytes.NewReader(golden)
+		decompressed, err := cmd.Output()
+		equals(
+			err == nil && bytes.Equal(decompressed, data),
+			true,
+			t,
+			fmt.Sprintf("Error. The golden %s file should be decompressed by the %s tool", test.format, test.tool[0]),
+		)
+	}
+}
+
+func TestXZWriter_Ratio(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "golden.log"))
+	equals(
+		err,
+		nil,
+		t,
+		"Error. Failed to read the golden log file",
+	)
+
+	var compressed, gzipped bytes.Buffer
+	writer := newXZWriter(&compressed)
+	_, _ = writer.Write(data)
+	_ = writer.Close()
+	gzipWriter, _ := gzip.NewWriterLevel(&gzipped, gzip.BestCompression)
+	_, _ = gzipWriter.Write(data)
+	_ = gzipWriter.Close()
+	equals(
+		compressed.Len() < gzipped.Len(),
+		true,
+		t,
+		fmt.Sprintf("Error. The xz output of %d bytes should be smaller than the gzip -9 output of %d bytes", compressed.Len(), gzipped.Len()),
+	)
+
+	// Comparing with the default preset of the xz tool, when installed
+	xz, err := exec.LookPath("xz")
+	if err != nil {
+		return
+	}
+	cmd := exec.Command(xz, "--compress", "--stdout", "-6")
+	cmd.Stdin = bytes.NewReader(data)
+	reference, err := cmd.Output()
+	equals(
+		err == nil && compressed.Len() <= len(reference)*105/100,
+		true,
+		t,
+		fmt.Sprintf("Error. The xz output of %d bytes should be within 5%% of the xz -6 output of %d bytes", compressed.Len(), len(reference)),
+	)
+}
+
+func TestLZ4Writer_Ratio(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "golden.log"))
+	equals(
+		err,
+		nil,
+		t,
+		"Error. Failed to read the golden log file",
+	)
+
+	var compressed, gzipped bytes.Buffer
+	writer := newLZ4Writer(&compressed)
+	_, _ = writer.Write(data)
+	_ = writer.Close()
+	gzipWriter, _ := gzip.NewWriterLevel(&gzipped, gzip.BestSpeed)
+	_, _ = gzipWriter.Write(data)
+	_ = gzipWriter.Close()
+	equals(
+		compressed.Len() < gzipped.Len()*3/2,
+		true,
+		t,
+		fmt.Sprintf("Error. The lz4 output of %d bytes should be within 50%% of the gzip -1 output of %d bytes", compressed.Len(), gzipped.Len()),
+	)
+
+	// Comparing with the default level of the lz4 tool, when installed
+	lz4, err := exec.LookPath("lz4")
+	if err != nil {
+		return
+	}
+	cmd := exec.Command(lz4, "--compress", "--stdout")
+	cmd.Stdin = bytes.NewReader(data)
+	reference, err := cmd.Output()
+	equals(
+		err == nil && compressed.Len() <= len(reference)*105/100,
+		true,
+		t,
+		fmt.Sprintf("Error. The lz4 output of %d bytes should be within 5%% of the lz4 output of %d bytes", compressed.Len(), len(reference)),
+	)
+}
+
+func TestSnappyWriter_Ratio(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "golden.log"))
+	equals(
+		err,
+		nil,
+		t,
+		"Error. Failed to read the golden log file",
+	)
+
+	var compressed, gzipped bytes.Buffer
+	writer := newSnappyWriter(&compressed)
+	_, _ = writer.Write(data)
+	_ = writer.Close()
+	gzipWriter, _ := gzip.NewWriterLevel(&gzipped, gzip.BestSpeed)
+	_, _ = gzipWriter.Write(data)
+	_ = gzipWriter.Close()
+	equals(
+		compressed.Len() < gzipped.Len()*3/2,
+		true,
+		t,
+		fmt.Sprintf("Error. The snappy output of %d bytes should be within 50%% of the gzip -1 output of %d bytes", compressed.Len(), gzipped.Len()),
+	)
+
+	// Comparing with the default level of the snzip tool, when installed
+	snzip, err := exec.LookPath("snzip")
+	if err != nil {
+		return
+	}
+	cmd := exec.Command(snzip, "-c", "-t", "framing2")
+	cmd.Stdin = bytes.NewReader(data)
+	reference, err := cmd.Output()
+	equals(
+		err == nil && compressed.Len() <= len(reference)*105/100,
+		true,
+		t,
+		fmt.Sprintf("Error. The snappy output of %d bytes should be within 5%% of the snzip output of %d bytes", compressed.Len(), len(reference)),
+	)
+}
+
+// upperCompressor is a custom Compressor which upper cases the log files
+type upperCompressor struct{}
+
+func (upperCompressor) Compress(source, destination string) error {
+	content, err := ioutil.ReadFile(source)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(destination, bytes.ToUpper(content), 0644)
+}
+
+func (upperCompressor) Ext() string {
+	return ".upper"
+}
+
+func TestLogger_Rotate_Compressor(t *testing.T) {
+
+	var rotateCh = make(chan string, 1)
+	logger, _ := New("", &Options{
+		Compress:     true,
+		Compressor:   upperCompressor{},
+		BackupNaming: SequenceNaming,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	for i := 0; i < 2; i++ {
+		_, _ = logger.Write([]byte("eidos\n"))
+		equals(
+			logger.Rotate(),
+			nil,
+			t,
+			"Error. Failed to rotate the log file manually",
+		)
+		<-rotateCh
+	}
+
+	// The rotated log files should be compressed by the custom compressor
+	// and shifted with the extension of the compressor
+	content, err := ioutil.ReadFile(logger.Filename + ".2.upper")
+	equals(
+		err == nil && string(content) == "EIDOS\n",
+		true,
+		t,
+		"Error. The rotated log file should be compressed by the custom compressor",
+	)
+	_, err = os.Stat(logger.Filename + ".1")
+	equals(
+		os.IsNotExist(err),
+		true,
+		t,
+		"Error. The uncompressed log file should be removed",
+	)
+}
+
+func TestLogger_Rotate_Checksum(t *testing.T) {
+
+	type checksum struct{ file, digest string }
+	var checksumCh = make(chan checksum, 1)
+	var rotatedCh = make(chan RotationEvent, 1)
+	var sidecarCh = make(chan bool, 1)
+	logger, _ := New("", &Options{
+		Compress: true,
+		Checksum: true,
+	}, &Callback{
+		Checksum: func(s string, d string) {
+			checksumCh <- checksum{file: s, digest: d}
+		},
+		Rotated: func(event RotationEvent) {
+			_, err := os.Stat(event.CompressedFile + checksumExtension)
+			sidecarCh <- err == nil
+			rotatedCh <- event
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	_, _ = logger.Write([]byte(randStringBytes(1024)))
+	equals(
+		logger.Rotate(),
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+	c := <-checksumCh
+
+	// The digest should match the compressed rotated log file
+	content, _ := ioutil.ReadFile(c.file)
+	sum := sha256.Sum256(content)
+	equals(
+		c.digest,
+		hex.EncodeToString(sum[:]),
+		t,
+		"Error. The digest should be the SHA-256 of the rotated log file",
+	)
+
+	// The checksum file should be in the format of the sha256sum utility
+	sidecar, err := ioutil.ReadFile(c.file + checksumExtension)
+	equals(
+		err == nil && string(sidecar) == c.digest+"  "+filepath.Base(c.file)+"\n",
+		true,
+		t,
+		"Error. The checksum file should hold the digest and the file name",
+	)
+
+	// The checksum file is written before the callbacks, and the digest is passed in the event
+	equals(
+		<-sidecarCh,
+		true,
+		t,
+		"Error. The checksum file should be written before the callbacks",
+	)
+	equals(
+		(<-rotatedCh).Checksum,
+		c.digest,
+		t,
+		"Error. The digest should be passed in the rotation event",
+	)
+
+	// The checksum file should be removed along with the rotated log file
+	backups, _ := backupFiles(logger.Filename, logger.RotationOption)
+	equals(
+		len(backups),
+		1,
+		t,
+		"Error. The checksum file should not be listed as a backup",
+	)
+	_ = removeBackup(logger.Filename, backups[0], logger.RotationOption)
+	_, err = os.Stat(c.file + checksumExtension)
+	equals(
+		os.IsNotExist(err),
+		true,
+		t,
+		"Error. The checksum file should be removed along with the backup",
+	)
+}
+
+func TestLogger_Backups(t *testing.T) {
+
+	var checksumCh = make(chan string, 1)
+	logger, _ := New("", &Options{
+		Compress: true,
+		Checksum: true,
+	}, &Callback{
+		Checksum: func(s string, d string) {
+			checksumCh <- d
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	_, _ = logger.Write([]byte(randStringBytes(1024)))
+	equals(
+		logger.Rotate(),
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+	digest := <-checksumCh
+
+	// The compressed rotated log file should be described with its checksum
+	backups, err := logger.Backups()
+	equals(
+		err == nil && len(backups) == 1 &&
+			strings.HasSuffix(backups[0].File, ".log.gz") && backups[0].Compressed &&
+			backups[0].Size > 0 && backups[0].Checksum == digest &&
+			time.Since(backups[0].RotationTime) < time.Minute,
+		true,
+		t,
+		"Error. The rotated log file should be described by the inventory",
+	)
+}
+
+func TestBackupTime(t *testing.T) {
+	logger, _ := New("", &Options{}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	codec := newBackupCodec(logger.Filename, logger.RotationOption)
+	rotation := time.Now().UTC().Add(-time.Hour).Truncate(time.Millisecond)
+	for _, test := range []struct {
+		name     string
+		expected func(os.FileInfo) time.Time
+	}{
+		{codec.format(rotation, ""), func(os.FileInfo) time.Time { return rotation }},
+		{codec.prefix + "unparsable" + codec.ext, os.FileInfo.ModTime},
+	} {
+		file := filepath.Join(filepath.Dir(logger.Filename), test.name)
+		_ = ioutil.WriteFile(file, []byte("eidos\n"), 0644)
+		fileInfo, _ := os.Stat(file)
+		equals(
+			backupTime(codec, backupFile{path: file, FileInfo: fileInfo}).Equal(test.expected(fileInfo)),
+			true,
+			t,
+			"Error. The rotation time should be parsed from the file name or fall back to the modification time",
+		)
+	}
+}
+
+func TestLogger_Rotate_BundleBackups(t *testing.T) {
+
+	var rotateCh = make(chan string, 1)
+	logger, _ := New("", &Options{
+		Compress:      true,
+		BundleBackups: 2,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Shutdown(context.Background())
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	for i := 0; i < 2; i++ {
+		_, _ = logger.Write([]byte("eidos\n"))
+		equals(
+			logger.Rotate(),
+			nil,
+			t,
+			"Error. Failed to rotate the log file manually",
+		)
+	}
+
+	// The callback should be triggered with the bundle of the rotated log files
+	bundleFile := <-rotateCh
+	equals(
+		strings.HasSuffix(bundleFile, ".tar.gz"),
+		true,
+		t,
+		"Error. The rotated log files should be bundled into a compressed tar archive",
+	)
+
+	file, _ := os.Open(bundleFile)
+	defer file.Close()
+	reader, _ := gzip.NewReader(file)
+	tarReader := tar.NewReader(reader)
+	var entries int
+	for {
+		header, err := tarReader.Next()
+		if err != nil {
+			break
+		}
+		content, _ := ioutil.ReadAll(tarReader)
+		equals(
+			strings.HasSuffix(header.Name, ".log") && string(content) == "eidos\n",
+			true,
+			t,
+			"Error. The bundle should hold the uncompressed rotated log files",
+		)
+		entries++
+	}
+	equals(
+		entries,
+		2,
+		t,
+		"Error. The bundle should hold two rotated log files",
+	)
+
+	backups, _ := backupFiles(logger.Filename, logger.RotationOption)
+	equals(
+		len(backups) == 1 && backups[0].path == bundleFile,
+		true,
+		t,
+		"Error. The bundled log files should be removed",
+	)
+}
+
+func TestNew_BundleDaily(t *testing.T) {
+	// Creating the rotated log files of the previous day
+	dir := filepath.Join(os.TempDir(), "eidos_logs")
+	_ = os.MkdirAll(dir, 0755)
+	yesterday := time.Now().UTC().AddDate(0, 0, -1)
+	for i := 0; i < 2; i++ {
+		_ = ioutil.WriteFile(filepath.Join(
+			dir,
+			fmt.Sprintf("%s-eidos-%s.log", filepath.Base(os.Args[0]), yesterday.Add(time.Duration(i)*time.Second).Format(backupTimeFormat)),
+		), []byte("eidos\n"), 0644)
+	}
+
+	var rotateCh = make(chan string, 1)
+	logger, _ := New("", &Options{
+		BundleDaily: true,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// The rotated log files of the previous day should be bundled at startup
+	bundleFile := <-rotateCh
+	equals(
+		filepath.Base(bundleFile),
+		fmt.Sprintf("%s-eidos-%s.tar", filepath.Base(os.Args[0]), yesterday.Format(backupTimeFormat)),
+		t,
+		"Error. The bundle should be named after the oldest rotated log file",
+	)
+	backups, _ := backupFiles(logger.Filename, logger.RotationOption)
+	equals(
+		len(backups),
+		1,
+		t,
+		"Error. The bundled log files should be removed",
+	)
+
+	equals(
+		func() bool {
+			_, err := New("", &Options{BundleDaily: true, BundleBackups: 2}, &Callback{})
+			return err != nil
+		}(),
+		true,
+		t,
+		"Error. The daily bundles should not be used with the bundle backups",
+	)
+}
+
+func TestLogger_Rotate_CompressDir(t *testing.T) {
+
+	var rotateCh = make(chan string, 1)
+	logger, _ := New("", &Options{
+		Compress:               true,
+		CompressDir:            "archive",
+		DatePartitionedBackups: true,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	_, _ = logger.Write([]byte(randStringBytes(1024)))
+	equals(
+		logger.Rotate(),
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+
+	// The compressed log file should be placed under the date partition of the CompressDir
+	now := time.Now().UTC()
+	rotatedFile := <-rotateCh
+	equals(
+		filepath.Dir(rotatedFile),
+		filepath.Join(filepath.Dir(logger.Filename), "archive", now.Format("2006"), now.Format("01"), now.Format("02")),
+		t,
+		"Error. The compressed log file should be placed under the compress dir",
+	)
+
+	// The compressed log file should be listed and removed as a backup
+	backups, _ := backupFiles(logger.Filename, logger.RotationOption)
+	equals(
+		len(backups) == 1 && backups[0].path == rotatedFile,
+		true,
+		t,
+		"Error. The compressed log file should be listed as a backup",
+	)
+	_ = removeBackup(logger.Filename, backups[0], logger.RotationOption)
+	_, err := os.Stat(filepath.Join(filepath.Dir(logger.Filename), "archive", now.Format("2006")))
+	equals(
+		os.IsNotExist(err),
+		true,
+		t,
+		"Error. The emptied partitions of the compress dir should be removed",
+	)
+	_, err = os.Stat(filepath.Join(filepath.Dir(logger.Filename), "archive"))
+	equals(
+		err,
+		nil,
+		t,
+		"Error. The compress dir should be retained",
+	)
+}
+
+func TestLogger_Rotate_BackupDir(t *testing.T) {
+
+	var rotateCh = make(chan string, 1)
+	logger, _ := New("", &Options{
+		BackupDir:              "backups",
+		DatePartitionedBackups: true,
+		ManualRotation:         true,
+		RetentionPeriod:        10,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	_, _ = logger.Write([]byte(randStringBytes(1024)))
+	equals(
+		logger.Rotate(),
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+
+	// The rotated log file should be placed under the date partition of the BackupDir
+	now := time.Now().UTC()
+	rotatedFile := <-rotateCh
+	equals(
+		filepath.Dir(rotatedFile),
+		filepath.Join(filepath.Dir(logger.Filename), "backups", now.Format("2006"), now.Format("01"), now.Format("02")),
+		t,
+		"Error. The rotated log file should be placed under the backup dir",
+	)
+
+	// Creating the fake month old log files in the date partition of
+	// the BackupDir and in the log directory, ex- before the BackupDir
+	monthOld := now.Add(-31 * 24 * time.Hour)
+	partition := filepath.Join(filepath.Dir(logger.Filename), "backups", monthOld.Format("2006"), monthOld.Format("01"), monthOld.Format("02"))
+	_ = os.MkdirAll(partition, 0755)
+	monthOldFiles := []string{
+		filepath.Join(partition, fmt.Sprintf("%s-eidos-%s.log", filepath.Base(os.Args[0]), monthOld.Format(backupTimeFormat))),
+		filepath.Join(filepath.Dir(logger.Filename), fmt.Sprintf("%s-eidos-%s.log", filepath.Base(os.Args[0]), monthOld.Add(time.Second).Format(backupTimeFormat))),
+	}
+	for _, file := range monthOldFiles {
+		_ = ioutil.WriteFile(file, nil, 0644)
+	}
+
+	// The retention should traverse both the BackupDir and the log directory
+	logger.cleanUpOldLogs()
+	for _, file := range monthOldFiles {
+		_, err := os.Stat(file)
+		equals(
+			os.IsNotExist(err),
+			true,
+			t,
+			"Error. Month old log file should be removed",
+		)
+	}
+	_, err := os.Stat(partition)
+	equals(
+		os.IsNotExist(err),
+		true,
+		t,
+		"Error. The emptied partition of the backup dir should be removed",
+	)
+	_, err = os.Stat(rotatedFile)
+	equals(
+		err,
+		nil,
+		t,
+		"Error. The rotated log file should be retained",
+	)
+
+	_, err = New("", &Options{BackupDir: "backups", BackupNaming: SequenceNaming}, &Callback{})
+	equals(
+		err != nil,
+		true,
+		t,
+		"Error. The backup dir should be rejected with the sequence naming",
+	)
+}
+
+func TestLogger_Recompress(t *testing.T) {
+	// Creating a gzip compressed backup of the previous day
+	dir := filepath.Join(os.TempDir(), "eidos_logs")
+	_ = os.MkdirAll(dir, 0755)
+	yesterday := time.Now().UTC().AddDate(0, 0, -1)
+	backupFileName := filepath.Join(
+		dir,
+		fmt.Sprintf("%s-eidos-%s.log", filepath.Base(os.Args[0]), yesterday.Format(backupTimeFormat)),
+	)
+	var compressed bytes.Buffer
+	gzWriter := gzip.NewWriter(&compressed)
+	_, _ = gzWriter.Write([]byte("eidos\n"))
+	_ = gzWriter.Close()
+	_ = ioutil.WriteFile(backupFileName+".gz", compressed.Bytes(), 0644)
+	_ = os.Chtimes(backupFileName+".gz", yesterday, yesterday)
+
+	logger, _ := New("", &Options{
+		Compress:          true,
+		CompressionFormat: Zip,
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	recompressed, err := logger.Recompress()
+	equals(
+		err == nil && recompressed == 1,
+		true,
+		t,
+		"Error. Failed to recompress the gzip compressed backup",
+	)
+
+	// The backup should be re-encoded, retaining its name and modification time
+	archive, err := zip.OpenReader(backupFileName + ".zip")
+	equals(
+		err,
+		nil,
+		t,
+		"Error. The backup should be re-encoded into the zip format",
+	)
+	defer archive.Close()
+	file, _ := archive.File[0].Open()
+	content, _ := ioutil.ReadAll(file)
+	equals(
+		archive.File[0].Name == filepath.Base(backupFileName) && string(content) == "eidos\n",
+		true,
+		t,
+		"Error. The re-encoded backup should hold the content of the backup",
+	)
+	fileInfo, _ := os.Stat(backupFileName + ".zip")
+	equals(
+		fileInfo.ModTime().Unix(),
+		yesterday.Unix(),
+		t,
+		"Error. The modification time of the backup should be retained",
+	)
+	_, err = os.Stat(backupFileName + ".gz")
+	equals(
+		os.IsNotExist(err),
+		true,
+		t,
+		"Error. The gzip compressed backup should be removed",
+	)
+}
+
+func TestLogger_Sync(t *testing.T) {
+	logger, _ := New("", &Options{
+		Compress:          true,
+		StreamCompression: true,
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	_, _ = logger.Write([]byte("eidos\n"))
+	equals(
+		logger.Flush(),
+		nil,
+		t,
+		"Error. Failed to sync the log file",
+	)
+
+	// The synced stream of the active log file should be readable
+	file, _ := os.Open(logger.Filename)
+	reader, err := gzip.NewReader(file)
+	var content []byte
+	if err == nil {
+		content, _ = ioutil.ReadAll(reader)
+	}
+	_ = file.Close()
+	equals(
+		string(content),
+		"eidos\n",
+		t,
+		"Error. The content of the log file should be synced",
+	)
+
+	// Syncing a closed logger should not fail
+	_ = logger.Close()
+	equals(
+		logger.Sync(),
+		nil,
+		t,
+		"Error. Failed to sync the closed logger",
+	)
+}
+
+func TestLogger_RotationJitter(t *testing.T) {
+	logger, _ := New("", &Options{
+		Period:         100 * time.Millisecond,
+		RotationJitter: time.Hour,
+		BufferSize:     4096,
+		FlushInterval:  50 * time.Millisecond,
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// The jitter delays the rotation, but not the other scheduled tasks
+	_, _ = logger.Write([]byte("eidos\n"))
+	time.Sleep(300 * time.Millisecond)
+	fileInfo, _ := os.Stat(logger.Filename)
+	equals(
+		fileInfo.Size(),
+		int64(6),
+		t,
+		"Error. The buffered logs should be written on the flush interval during the jitter",
+	)
+
+	// The scheduler is not blocked by the jitter, so the shutdown is not delayed
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	equals(
+		logger.Shutdown(ctx),
+		nil,
+		t,
+		"Error. The shutdown should not wait for the jitter of the rotation",
+	)
+}
+
+func TestLogger_Write_Buffered(t *testing.T) {
+
+	var rotateCh = make(chan string, 1)
+	logger, _ := New("", &Options{
+		SizeBytes:     2048,
+		BufferSize:    4096,
+		FlushInterval: 100 * time.Millisecond,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// The buffered logs are not written to the log file till the flush
+	_, _ = logger.Write([]byte("eidos\n"))
+	fileInfo, _ := os.Stat(logger.Filename)
+	equals(
+		fileInfo.Size(),
+		int64(0),
+		t,
+		"Error. The buffered logs should not be written to the log file",
+	)
+	equals(
+		logger.Flush(),
+		nil,
+		t,
+		"Error. Failed to flush the log file",
+	)
+	fileInfo, _ = os.Stat(logger.Filename)
+	equals(
+		fileInfo.Size(),
+		int64(6),
+		t,
+		"Error. The flushed logs should be written to the log file",
+	)
+
+	// The buffered logs are written to the log file on every flush interval
+	_, _ = logger.Write([]byte("eidos\n"))
+	time.Sleep(3 * logger.RotationOption.FlushInterval)
+	fileInfo, _ = os.Stat(logger.Filename)
+	equals(
+		fileInfo.Size(),
+		int64(12),
+		t,
+		"Error. The buffered logs should be written on the flush interval",
+	)
+
+	// The buffered logs are written to the log file before the rotation
+	_, _ = logger.Write([]byte(randStringBytes(1024)))
+	_, _ = logger.Write([]byte(randStringBytes(1024)))
+	fileInfo, err := os.Stat(<-rotateCh)
+	equals(
+		err,
+		nil,
+		t,
+		"Error. The rotated log file should be created",
+	)
+	equals(
+		fileInfo.Size(),
+		int64(1036),
+		t,
+		"Error. The rotated log file should contain the buffered logs",
+	)
+
+	// The buffered logs are written to the log file on close
+	_ = logger.Close()
+	fileInfo, _ = os.Stat(logger.Filename)
+	equals(
+		fileInfo.Size(),
+		int64(1024),
+		t,
+		"Error. The buffered logs should be written on close",
+	)
+}
+
+func TestLogger_Write_StreamCompression(t *testing.T) {
+
+	var rotateCh = make(chan string, 1)
+	logger, _ := New("", &Options{
+		Compress:          true,
+		StreamCompression: true,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	_, _ = logger.Write([]byte("eidos\n"))
+	equals(
+		logger.sync(),
+		nil,
+		t,
+		"Error. Failed to flush the log file stream",
+	)
+
+	// The flushed stream of the active log file should be readable
+	file, _ := os.Open(logger.Filename)
+	reader, err := gzip.NewReader(file)
+	var content []byte
+	if err == nil {
+		content, _ = ioutil.ReadAll(reader)
+	}
+	_ = file.Close()
+	equals(
+		string(content),
+		"eidos\n",
+		t,
+		"Error. The active log file should be tailable after a flush",
+	)
+
+	equals(
+		logger.Rotate(),
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+
+	// The rotated log file should be a complete gzip file
+	rotatedFile := <-rotateCh
+	file, _ = os.Open(rotatedFile)
+	defer file.Close()
+	reader, err = gzip.NewReader(file)
+	content = nil
+	if err == nil {
+		content, err = ioutil.ReadAll(reader)
+	}
+	equals(
+		strings.HasSuffix(rotatedFile, ".log.gz") && err == nil && string(content) == "eidos\n",
+		true,
+		t,
+		"Error. The rotated log file should be the complete gzip stream",
+	)
+
+	_, err = New("", &Options{StreamCompression: true}, &Callback{})
+	equals(
+		err != nil,
+		true,
+		t,
+		"Error. The stream compression should require the compression",
+	)
+}
+
+func TestLogger_Rotate_CompressionStats(t *testing.T) {
+
+	var statsCh = make(chan CompressionStats, 1)
+	logger, _ := New("", &Options{
+		Compress:         true,
+		CompressionLevel: gzip.BestSpeed,
+	}, &Callback{
+		Compressed: func(s CompressionStats) {
+			statsCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	_, _ = logger.Write([]byte(strings.Repeat("eidos\n", 1024)))
+	equals(
+		logger.Rotate(),
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+
+	// The statistics should describe the compression of the rotated log file
+	stats := <-statsCh
+	fileInfo, _ := os.Stat(stats.CompressedFile)
+	equals(
+		stats.CompressedFile == stats.File+".gz" && stats.OriginalSize == 6*1024 &&
+			fileInfo != nil && stats.CompressedSize == fileInfo.Size() &&
+			stats.Ratio == float64(stats.OriginalSize)/float64(stats.CompressedSize) && stats.Ratio > 1,
+		true,
+		t,
+		"Error. The compression statistics should hold the sizes and the ratio",
+	)
+}
+
+func TestLogger_Rotate_RotationEvent(t *testing.T) {
+
+	var eventCh = make(chan RotationEvent, 2)
+	logger, _ := New("", &Options{
+		SizeBytes: 1024,
+		Compress:  true,
+	}, &Callback{
+		Rotated: func(e RotationEvent) {
+			eventCh <- e
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// The write exceeding the SizeBytes should rotate the log file
+	_, _ = logger.Write([]byte(randStringBytes(1000)))
+	_, _ = logger.Write([]byte(randStringBytes(100)))
+	event := <-eventCh
+	equals(
+		event.File == logger.Filename && event.Reason == RotationSize && event.Size == 1000 &&
+			event.CompressedFile == event.BackupFile+".gz",
+		true,
+		t,
+		"Error. The rotation event should describe the size rotation",
+	)
+	_, err := os.Stat(event.CompressedFile)
+	equals(
+		err,
+		nil,
+		t,
+		"Error. The compressed log file of the rotation event should exist",
+	)
+
+	equals(
+		logger.Rotate(),
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+	event = <-eventCh
+	equals(
+		event.Reason == RotationManual && event.Size == 100 && event.Reason.String() == "manual",
+		true,
+		t,
+		"Error. The rotation event should describe the manual rotation",
+	)
+}
+
+func TestLogger_Rotate_BeforeRotate(t *testing.T) {
+
+	var veto bool
+	var logger *Logger
+	logger, _ = New("", &Options{
+		SizeBytes: 1024,
+	}, &Callback{
+		BeforeRotate: func(s string) error {
+			if veto {
+				return fmt.Errorf("critical section")
+			}
+			// Flushing the buffered logs before the manual rotation
+			if logger.size == 1000 {
+				_, _ = logger.Write([]byte("flushed\n"))
+			}
+			return nil
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// The vetoed size rotation should be deferred
+	veto = true
+	_, _ = logger.Write([]byte(randStringBytes(1000)))
+	n, err := logger.Write([]byte(randStringBytes(100)))
+	equals(
+		n == 100 && err == nil && logger.size == 1100,
+		true,
+		t,
+		"Error. The vetoed size rotation should be deferred",
+	)
+	equals(
+		logger.Rotate() != nil,
+		true,
+		t,
+		"Error. The vetoed manual rotation should return the error",
+	)
+	backups, _ := backupFiles(logger.Filename, logger.RotationOption)
+	equals(
+		len(backups),
+		0,
+		t,
+		"Error. The vetoed rotation should not rotate the log file",
+	)
+
+	// The deferred size rotation should happen on the next write
+	veto = false
+	_, _ = logger.Write([]byte(randStringBytes(1000)))
+	backups, _ = backupFiles(logger.Filename, logger.RotationOption)
+	equals(
+		len(backups) == 1 && backups[0].Size() == 1100,
+		true,
+		t,
+		"Error. The deferred size rotation should rotate the log file",
+	)
+
+	// The buffered logs written by the hook should be in the rotated log
+	// file, which holds the 1000 bytes followed by the "flushed" line
+	equals(
+		logger.Rotate(),
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+	backups, _ = backupFiles(logger.Filename, logger.RotationOption)
+	equals(
+		len(backups) == 2 && (backups[0].Size() == 1008 || backups[1].Size() == 1008),
+		true,
+		t,
+		"Error. The logs written by the hook should be in the rotated log file",
+	)
+}
+
+func TestLogger_OnRotate(t *testing.T) {
+
+	var rotateCh = make(chan string, 2)
+	logger, _ := New("", &Options{
+		ManualRotation: true,
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	logger.OnRotate(func(s string) {
+		rotateCh <- "uploader-" + s
+	})
+	logger.OnRotate(func(s string) {
+		rotateCh <- "metrics-" + s
+	})
+
+	_, _ = logger.Write([]byte(randStringBytes(1024)))
+	equals(
+		logger.Rotate(),
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+
+	// Every registered callback should be called in the order of the registration
+	uploader, metrics := <-rotateCh, <-rotateCh
+	equals(
+		strings.HasPrefix(uploader, "uploader-") && strings.HasPrefix(metrics, "metrics-") &&
+			strings.TrimPrefix(uploader, "uploader-") == strings.TrimPrefix(metrics, "metrics-"),
+		true,
+		t,
+		"Error. Every registered callback should be called with the rotated file",
+	)
+}
+
+func TestLogger_CallbackOverflow(t *testing.T) {
+	for _, overflow := range []CallbackOverflow{CallbackDropOldest, CallbackDropNewest} {
+		var startedCh = make(chan string, 1)
+		var releaseCh = make(chan struct{})
+		logger, _ := New("", &Options{
+			ManualRotation:    true,
+			CallbackQueueSize: 1,
+			CallbackOverflow:  overflow,
+		}, &Callback{
+			Execute: func(s string) {
+				select {
+				case startedCh <- s:
+				default:
+				}
+				<-releaseCh
+			},
+		})
+
+		// The first rotated file name holds up the slow callback
+		_, _ = logger.Write([]byte(randStringBytes(1024)))
+		_ = logger.Rotate()
+		<-startedCh
+
+		// The callback queue holds one rotated file name, the rest are dropped
+		for i := 0; i < 3; i++ {
+			_, _ = logger.Write([]byte(randStringBytes(1024)))
+			_ = logger.Rotate()
+		}
+		for deadline := time.Now().Add(5 * time.Second); logger.DroppedCallbacks() < 2 && time.Now().Before(deadline); {
+			time.Sleep(10 * time.Millisecond)
+		}
+		equals(
+			logger.DroppedCallbacks(),
+			uint64(2),
+			t,
+			fmt.Sprintf("Error. The %s policy should drop the overflowing rotated file names", overflow),
+		)
+
+		close(releaseCh)
+		_ = logger.Close()
+		_ = clean(filepath.Dir(logger.Filename))
+	}
+
+	_, err := New("", &Options{CallbackQueueSize: -1}, &Callback{})
+	equals(
+		err != nil,
+		true,
+		t,
+		"Error. A negative callback queue size should be rejected",
+	)
+}
+
+func TestLogger_Deliver_Retry(t *testing.T) {
+
+	var attempts = make(chan string, 3)
+	logger, _ := New("", &Options{
+		ManualRotation:   true,
+		CallbackRetries:  2,
+		CallbackBackoff:  10 * time.Millisecond,
+		PersistCallbacks: true,
+	}, &Callback{
+		Deliver: func(s string) error {
+			attempts <- s
+			if len(attempts) < 3 {
+				return fmt.Errorf("upload failed")
+			}
+			return nil
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	_, _ = logger.Write([]byte(randStringBytes(1024)))
+	equals(
+		logger.Rotate(),
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+
+	// The failed deliveries should be retried till the delivery succeeds
+	for deadline := time.Now().Add(5 * time.Second); len(attempts) < 3 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+	equals(
+		len(attempts),
+		3,
+		t,
+		"Error. The failed deliveries should be retried",
+	)
+
+	// The delivered rotated file should not be pending in the state file
+	equals(
+		len(readState(logger.Filename).PendingCallbacks),
+		0,
+		t,
+		"Error. The delivered rotated file should not be pending",
+	)
+}
+
+func TestNew_Deliver_Pending(t *testing.T) {
+
+	dir := filepath.Join(os.TempDir(), "eidos_logs")
+	_ = os.MkdirAll(dir, 0755)
+
+	// Creating a rotated log file, which was pending, when the previous process exited
+	filename := filepath.Join(dir, fmt.Sprintf("%s-eidos.log", filepath.Base(os.Args[0])))
+	pendingFile := filepath.Join(
+		dir,
+		fmt.Sprintf("%s-eidos-%s.log", filepath.Base(os.Args[0]), time.Now().UTC().Format(backupTimeFormat)),
+	)
+	_ = ioutil.WriteFile(pendingFile, nil, 0644)
+	_ = writeState(filename, state{PendingCallbacks: []string{pendingFile, filepath.Join(dir, "removed.log")}})
+
+	var deliverCh = make(chan string, 2)
+	logger, _ := New("", &Options{
+		ManualRotation:   true,
+		PersistCallbacks: true,
+	}, &Callback{
+		Deliver: func(s string) error {
+			deliverCh <- s
+			return nil
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// The pending rotated file should be delivered after the restart
+	select {
+	case s := <-deliverCh:
+		equals(
+			s,
+			pendingFile,
+			t,
+			"Error. The pending rotated file should be delivered",
+		)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Error. The pending rotated file was not delivered")
+	}
+
+	// The removed rotated file should be dropped from the pending callbacks
+	for deadline := time.Now().Add(5 * time.Second); len(readState(logger.Filename).PendingCallbacks) > 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	equals(
+		len(readState(logger.Filename).PendingCallbacks),
+		0,
+		t,
+		"Error. The pending callbacks should be cleared",
+	)
+}
+
+func TestLogger_ExecuteContext(t *testing.T) {
+
+	var cancelledCh = make(chan string, 1)
+	logger, _ := New("", &Options{
+		ManualRotation: true,
+	}, &Callback{
+		ExecuteContext: func(ctx context.Context, s string) {
+			// A long running upload, which is interrupted by the close
+			select {
+			case <-ctx.Done():
+				cancelledCh <- s
+			case <-time.After(time.Minute):
+			}
+		},
+	})
+
+	defer func() {
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	_, _ = logger.Write([]byte(randStringBytes(1024)))
+	equals(
+		logger.Rotate(),
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+	_ = logger.Close()
+
+	select {
+	case s := <-cancelledCh:
+		equals(
+			s != "",
+			true,
+			t,
+			"Error. The callback should receive the rotated file name",
+		)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Error. The context of the callback was not cancelled by the close")
+	}
+}
+
+func TestLogger_Shutdown(t *testing.T) {
+	var executed = make(chan string, 1)
+	logger, _ := New("", &Options{
+		Compress: true,
+	}, &Callback{
+		Execute: func(s string) {
+			time.Sleep(200 * time.Millisecond)
+			executed <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	_, _ = logger.Write([]byte(randStringBytes(1024)))
+	equals(
+		logger.Rotate(),
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+
+	// The shutdown should wait for the compression and the callback
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	equals(
+		logger.Shutdown(ctx),
+		nil,
+		t,
+		"Error. Failed to shutdown the logger",
+	)
+	select {
+	case rotatedFileName := <-executed:
+		equals(
+			strings.HasSuffix(rotatedFileName, ".gz"),
+			true,
+			t,
+			"Error. The rotated log file should be compressed before the shutdown",
+		)
+	default:
+		t.Fatal("Error. The shutdown should wait for the queued callbacks")
+	}
+}
+
+func TestLogger_Scheduler(t *testing.T) {
+	logger, _ := New("", &Options{
+		Period:             time.Hour,
+		RetentionPeriod:    1,
+		MinFreeDiskPercent: 1,
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// All the periodic tasks should be run by the scheduler
+	equals(
+		scheduled(logger, "rotation") && scheduled(logger, "retention") && scheduled(logger, "disk"),
+		true,
+		t,
+		"Error. The periodic tasks should be scheduled",
+	)
+
+	// The scheduler and the callback worker should exit on the shutdown
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	equals(
+		logger.Shutdown(ctx),
+		nil,
+		t,
+		"Error. The daemon go-routines should exit on the shutdown",
+	)
+}
+
+func TestLogger_Shutdown_Timeout(t *testing.T) {
+	logger, _ := New("", &Options{}, &Callback{
+		Execute: func(s string) {
+			time.Sleep(2 * time.Second)
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	_, _ = logger.Write([]byte(randStringBytes(1024)))
+	equals(
+		logger.Rotate(),
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+
+	// The shutdown should return, when the ctx is done
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	equals(
+		logger.Shutdown(ctx),
+		context.DeadlineExceeded,
+		t,
+		"Error. The shutdown should be bounded by the ctx",
+	)
+}
+
+func TestLogger_SynchronousCallbacks(t *testing.T) {
+
+	var rotated []string
+	var logger *Logger
+	logger, _ = New("", &Options{
+		SizeBytes:            1024,
+		Compress:             true,
+		SynchronousCallbacks: true,
+	}, &Callback{
+		Execute: func(s string) {
+			rotated = append(rotated, s)
+			// The callbacks can write to the logger
+			_, _ = logger.Write([]byte("rotated\n"))
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// The callback should be called before the size rotating write returns
+	_, _ = logger.Write([]byte(randStringBytes(1000)))
+	_, _ = logger.Write([]byte(randStringBytes(100)))
+	equals(
+		len(rotated) == 1 && strings.HasSuffix(rotated[0], ".log.gz"),
+		true,
+		t,
+		"Error. The callback should be called with the compressed file before the write returns",
+	)
+	_, err := os.Stat(rotated[0])
+	equals(
+		err,
+		nil,
+		t,
+		"Error. The compressed file should exist before the write returns",
+	)
+
+	// The callback should be called before the manual rotation returns
+	equals(
+		logger.Rotate(),
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+	equals(
+		len(rotated),
+		2,
+		t,
+		"Error. The callback should be called before the rotation returns",
+	)
+}
+
+func TestCommandNotifier(t *testing.T) {
+
+	// The rotated file name should be passed as the last argument
+	var file, output string
+	CommandNotifier(time.Second, func(f string, out []byte, err error) {
+		file, output = f, string(out)
+		equals(err, nil, t, "Error. Failed to run the command")
+	}, "echo", "rotated")(RotationEvent{BackupFile: "app.log.1", CompressedFile: "app.log.1.gz"})
+	equals(
+		file == "app.log.1.gz" && output == "rotated app.log.1.gz\n",
+		true,
+		t,
+		"Error. The command should be run with the rotated file name",
+	)
+
+	// The command should be killed after the timeout
+	var commandErr error
+	start := time.Now()
+	CommandNotifier(100*time.Millisecond, func(_ string, _ []byte, err error) {
+		commandErr = err
+	}, "sh", "-c", "sleep 5")(RotationEvent{BackupFile: "app.log.1"})
+	equals(
+		commandErr != nil && time.Since(start) < 5*time.Second,
+		true,
+		t,
+		"Error. The command should be killed after the timeout",
+	)
+
+	// The output written before the timeout should be returned, while
+	// a child of the killed command still holds the output pipe
+	start = time.Now()
+	CommandNotifier(200*time.Millisecond, func(_ string, out []byte, err error) {
+		commandErr, output = err, string(out)
+	}, "sh", "-c", "echo started; sleep 5 & sleep 5")(RotationEvent{BackupFile: "app.log.1"})
+	equals(
+		commandErr != nil && output == "started\n" && time.Since(start) < 5*time.Second,
+		true,
+		t,
+		"Error. The output of the timed out command should be returned",
+	)
+}
+
+func TestWebhookNotifier(t *testing.T) {
+
+	// The first request fails, and the retry should succeed
+	var requests = make(chan RotationEvent, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event RotationEvent
+		_ = json.NewDecoder(r.Body).Decode(&event)
+		if r.Header.Get("Authorization") != "Bearer token" || len(requests) == 0 {
+			requests <- event
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		requests <- event
+	}))
+	defer server.Close()
+
+	backoff := webhookBackoff
+	webhookBackoff = 10 * time.Millisecond
+	defer func() { webhookBackoff = backoff }()
+
+	WebhookNotifier(context.Background(), server.URL, map[string]string{"Authorization": "Bearer token"}, func(err error) {
+		t.Logf("Error- The retried webhook request should succeed, got %v", err)
+		t.FailNow()
+	})(
+		RotationEvent{BackupFile: "app-2020-10-15T10-30-00.000.log", Reason: RotationManual},
+	)
+	equals(
+		len(requests),
+		2,
+		t,
+		"Error. The failed webhook request should be retried",
+	)
+	event := <-requests
+	equals(
+		event.BackupFile == "app-2020-10-15T10-30-00.000.log" && event.Reason == RotationManual,
+		true,
+		t,
+		"Error. The webhook request should hold the rotation event",
+	)
+}
+
+func TestWebhookNotifier_Failure(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	backoff := webhookBackoff
+	webhookBackoff = 10 * time.Millisecond
+	defer func() { webhookBackoff = backoff }()
+
+	// The failure after the retries should be reported
+	var errs []error
+	WebhookNotifier(context.Background(), server.URL, nil, func(err error) {
+		errs = append(errs, err)
+	})(RotationEvent{BackupFile: "app-2020-10-15T10-30-00.000.log"})
+	equals(
+		len(errs),
+		1,
+		t,
+		"Error. The failed webhook notification should be reported",
+	)
+
+	// The backoff should be interrupted, when the ctx is done
+	webhookBackoff = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	errs = nil
+	WebhookNotifier(ctx, server.URL, nil, func(err error) {
+		errs = append(errs, err)
+	})(RotationEvent{BackupFile: "app-2020-10-15T10-30-00.000.log"})
+	equals(
+		time.Since(start) < time.Minute && len(errs) == 1,
+		true,
+		t,
+		"Error. The webhook backoff should be interrupted by the ctx",
+	)
+}
+
+func TestLogger_OnError(t *testing.T) {
+
+	var errCh = make(chan error, 1)
+	logger, _ := New("", &Options{
+		Compress: true,
+	}, &Callback{
+		OnError: func(err error) {
+			errCh <- err
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// The failure of the compression of a missing file should be reported
+	missingFile := filepath.Join(filepath.Dir(logger.Filename), "missing.log")
+	_ = logger.compressFile(missingFile, missingFile+".gz", logger.RotationOption.compressor())
+	select {
+	case err := <-errCh:
+		equals(
+			err != nil,
+			true,
+			t,
+			"Error. The failure of the compression should be reported",
+		)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Error. The failure of the compression was not reported")
+	}
+}
+
+// recordingHooks records the stages of the lifecycle of the log files
+type recordingHooks struct {
+	NopHooks
+	stages chan string
+}
+
+func (h recordingHooks) OnOpen(string)                   { h.stages <- "open" }
+func (h recordingHooks) OnRotate(RotationEvent)          { h.stages <- "rotate" }
+func (h recordingHooks) OnCompressStart(string)          { h.stages <- "compress start" }
+func (h recordingHooks) OnCompressDone(CompressionStats) { h.stages <- "compress done" }
+func (h recordingHooks) OnDelete(string)                 { h.stages <- "delete" }
+
+func TestLogger_Hooks(t *testing.T) {
+
+	hooks := recordingHooks{stages: make(chan string, 10)}
+	logger, _ := New("", &Options{
+		Compress: true,
+	}, &Callback{
+		Hooks: hooks,
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	_, _ = logger.Write([]byte(randStringBytes(1024)))
+	equals(
+		logger.Rotate(),
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+
+	// The stages of the rotation should be reported in order
+	var stages []string
+	for len(stages) < 5 {
+		stages = append(stages, <-hooks.stages)
+	}
+	equals(
+		stages,
+		[]string{"open", "open", "compress start", "compress done", "rotate"},
+		t,
+		"Error. The stages of the rotation should be reported in order",
+	)
+
+	// The removal by the retention should be reported
+	monthOldFile := filepath.Join(
+		filepath.Dir(logger.Filename),
+		fmt.Sprintf("%s-eidos-%s.log", filepath.Base(os.Args[0]), time.Now().UTC().Add(-31*24*time.Hour).Format(backupTimeFormat)),
+	)
+	_ = ioutil.WriteFile(monthOldFile, nil, 0644)
+	<-logger.inflight.wait()
+	logger.RotationOption.RetentionPeriod = 10
+	logger.cleanUpOldLogs()
+	equals(
+		<-hooks.stages,
+		"delete",
+		t,
+		"Error. The removal of the rotated log file should be reported",
+	)
+}
+
+func TestParallelGzipWriter(t *testing.T) {
+	for _, size := range []int{0, 1024, 3*parallelGzipBlockSize + 1024} {
+		data := []byte(randStringBytes(size))
+		var compressed bytes.Buffer
+		writer := newParallelGzipWriter(&compressed, gzip.BestSpeed, 2)
+		_, _ = writer.Write(data)
+		equals(
+			writer.Close(),
+			nil,
+			t,
+			"Error. Failed to close the parallel gzip writer",
+		)
+
+		// The multi member gzip file should be decompressed to the original data
+		reader, err := gzip.NewReader(&compressed)
+		equals(
+			err,
+			nil,
+			t,
+			"Error. Failed to read the parallel gzip file",
+		)
+		decompressed, err := ioutil.ReadAll(reader)
+		equals(
+			err == nil && bytes.Equal(decompressed, data),
+			true,
+			t,
+			"Error. The parallel gzip file should be decompressed to the original data",
+		)
+	}
+}
+
+func TestLogger_Rotate_GzipHeader(t *testing.T) {
+	for _, workers := range []int{0, 2} {
+		var rotateCh = make(chan string, 1)
+		logger, _ := New("", &Options{
+			Compress:           true,
+			CompressionWorkers: workers,
+		}, &Callback{
+			Execute: func(s string) {
+				rotateCh <- s
+			},
+		})
+
+		_, _ = logger.Write([]byte(randStringBytes(1024)))
+		equals(
+			logger.Rotate(),
+			nil,
+			t,
+			"Error. Failed to rotate the log file manually",
+		)
+		rotatedFile := <-rotateCh
+
+		// The gzip header should hold the name of the uncompressed log file
+		file, _ := os.Open(rotatedFile)
+		reader, err := gzip.NewReader(file)
+		equals(
+			err == nil && reader.Name == strings.TrimSuffix(filepath.Base(rotatedFile), ".gz") && !reader.ModTime.IsZero(),
+			true,
+			t,
+			"Error. The gzip header should hold the name and the modification time of the log file",
+		)
+		_ = file.Close()
+
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}
+}
+
+func TestSetCompressionPool(t *testing.T) {
+	equals(
+		SetCompressionPool(0, 1) != nil,
+		true,
+		t,
+		"Error. A compression pool without workers should be rejected",
+	)
+
+	// A single worker without any queue compresses the rotated log files one by one
+	equals(
+		SetCompressionPool(1, 0),
+		nil,
+		t,
+		"Error. Failed to configure the compression pool",
+	)
+	defer func() {
+		_ = SetCompressionPool(runtime.NumCPU(), defaultCompressionQueueLength)
+	}()
+
+	var rotateCh = make(chan string, 3)
+	logger, _ := New("", &Options{
+		Compress: true,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	for i := 0; i < 3; i++ {
+		_, _ = logger.Write([]byte(randStringBytes(1024)))
+		equals(
+			logger.Rotate(),
+			nil,
+			t,
+			"Error. Failed to rotate the log file manually",
+		)
+	}
+
+	// All the rotated log files should be compressed
+	for i := 0; i < 3; i++ {
+		equals(
+			strings.HasSuffix(<-rotateCh, ".gz"),
+			true,
+			t,
+			"Error. The rotated log file should be compressed by the compression pool",
+		)
+	}
+}
+
+func TestSetCompressionPool_Full(t *testing.T) {
+	// A single busy worker without any queue blocks the submissions
+	_ = SetCompressionPool(1, 0)
+	defer func() {
+		_ = SetCompressionPool(runtime.NumCPU(), defaultCompressionQueueLength)
+	}()
+	release := make(chan struct{})
+	submitCompression(func() {
+		<-release
+	})
+
+	for _, options := range []*Options{
+		{Compress: true},
+		{Compress: true, BundleBackups: 2},
+	} {
+		logger, _ := New("", options, &Callback{})
+		_, _ = logger.Write([]byte(randStringBytes(1024)))
+
+		rotated := make(chan error, 1)
+		go func() {
+			rotated <- logger.Rotate()
+		}()
+		time.Sleep(100 * time.Millisecond)
+
+		// The rotation waiting for the compression pool does not hold the logger
+		written := make(chan struct{})
+		go func() {
+			_, _ = logger.Write([]byte(randStringBytes(1024)))
+			close(written)
+		}()
+		select {
+		case <-written:
+		case <-time.After(time.Second):
+			close(release)
+			t.Fatal("Error. A write should not wait for the compression pool")
+		}
+
+		// The worker is released for the rotation and blocked again for the next logger
+		close(release)
+		equals(
+			<-rotated,
+			nil,
+			t,
+			"Error. Failed to rotate the log file manually",
+		)
+		_ = logger.Shutdown(context.Background())
+		_ = clean(filepath.Dir(logger.Filename))
+		release = make(chan struct{})
+		block := release
+		submitCompression(func() {
+			<-block
+		})
+	}
+	close(release)
+}
+
+func TestLogger_Rotate_CompressAfter(t *testing.T) {
+
+	var rotateCh = make(chan string, 1)
+	var clockMutex sync.Mutex
+	var offset time.Duration
+	currentTime = func() time.Time {
+		clockMutex.Lock()
+		defer clockMutex.Unlock()
+		return time.Now().Add(offset)
+	}
+	defer func() { currentTime = time.Now }()
+
+	logger, _ := New("", &Options{
+		Compress:      true,
+		CompressAfter: time.Hour,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	_, _ = logger.Write([]byte(randStringBytes(1024)))
+	equals(
+		logger.Rotate(),
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+
+	// The freshly rotated log file should stay uncompressed
+	rotatedFile := <-rotateCh
+	logger.compressAgedBackups()
+	_, err := os.Stat(rotatedFile)
+	equals(
+		err == nil && filepath.Ext(rotatedFile) == ".log",
+		true,
+		t,
+		"Error. The freshly rotated log file should not be compressed",
+	)
+
+	// The rotated log file should be compressed after the CompressAfter
+	clockMutex.Lock()
+	offset = 2 * time.Hour
+	clockMutex.Unlock()
+	logger.compressAgedBackups()
+	_, err = os.Stat(rotatedFile + ".gz")
+	equals(
+		err,
+		nil,
+		t,
+		"Error. The aged rotated log file should be compressed",
+	)
+	_, err = os.Stat(rotatedFile)
+	equals(
+		os.IsNotExist(err),
+		true,
+		t,
+		"Error. The uncompressed log file should be removed",
+	)
+}
+
+func TestNew_Compress_Existing_Backups(t *testing.T) {
+	// Creating an uncompressed backup file left behind by a previous run
+	dir := filepath.Join(os.TempDir(), "eidos_logs")
+	_ = os.MkdirAll(dir, 0755)
+	backupFile := filepath.Join(
+		dir,
+		fmt.Sprintf("%s-eidos-%s.log", filepath.Base(os.Args[0]), time.Now().UTC().Format(backupTimeFormat)),
+	)
+	_ = ioutil.WriteFile(backupFile, []byte(randStringBytes(1024)), 0644)
+
+	logger, _ := New("", &Options{
+		Compress: true,
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	// Waiting for the backup file to be compressed
+	var err error
+	for i := 0; i < 100; i++ {
+		if _, err = os.Stat(backupFile + ".gz"); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	equals(
+		err,
+		nil,
+		t,
+		"Error. The existing uncompressed backup file should be compressed",
+	)
+}
+
+func TestNew_Remove_Temp_Files(t *testing.T) {
+	// Creating a truncated temporary compressed file left behind by a crash
+	dir := filepath.Join(os.TempDir(), "eidos_logs")
+	_ = os.MkdirAll(dir, 0755)
+	tempFile := filepath.Join(
+		dir,
+		fmt.Sprintf("%s-eidos-%s.log.gz.tmp", filepath.Base(os.Args[0]), time.Now().UTC().Format(backupTimeFormat)),
+	)
+	_ = ioutil.WriteFile(tempFile, []byte{0x1f, 0x8b}, 0644)
+
+	var rotateCh = make(chan string, 1)
+	logger, _ := New("", &Options{
+		Compress: true,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	_, err := os.Stat(tempFile)
+	equals(
+		os.IsNotExist(err),
+		true,
+		t,
+		"Error. The temporary compressed file should be removed",
+	)
+
+	// The compression should not leave behind any temporary file
+	_, _ = logger.Write([]byte(randStringBytes(1024)))
+	equals(
+		logger.Rotate(),
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+	rotatedFile := <-rotateCh
+	_, err = os.Stat(rotatedFile + tempFileExtension)
+	equals(
+		filepath.Ext(rotatedFile) == ".gz" && os.IsNotExist(err),
+		true,
+		t,
+		"Error. The temporary compressed file should be renamed",
+	)
+}
+
+func BenchmarkLogger_Write(b *testing.B) {
+	logger, _ := New(filepath.Join(os.TempDir(), "eidos_bench", "write.log"), &Options{
+		Size: 1024,
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	line := []byte(randStringBytes(127) + "\n")
+	b.SetBytes(int64(len(line)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = logger.Write(line)
+	}
+}
+
+func BenchmarkLogger_Write_Parallel(b *testing.B) {
+	logger, _ := New(filepath.Join(os.TempDir(), "eidos_bench", "parallel.log"), &Options{
+		Size: 1024,
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	line := []byte(randStringBytes(127) + "\n")
+	b.SetBytes(int64(len(line)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, _ = logger.Write(line)
+		}
+	})
+}
+
+func BenchmarkCompressionFormat_Writer(b *testing.B) {
+	// The throughput can be compared with the benchmark of the
+	// reference tool on the golden log file, ex- lz4 -b1 testdata/golden.log
+	data, _ := ioutil.ReadFile(filepath.Join("testdata", "golden.log"))
+	for _, format := range []CompressionFormat{Gzip, LZ4, Snappy, XZ} {
+		b.Run(format.String(), func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				writer, _ := format.newWriter(ioutil.Discard, gzip.Header{}, gzip.BestSpeed, 1)
+				_, _ = writer.Write(data)
+				_ = writer.Close()
+			}
+		})
+	}
+}
+
+func BenchmarkCompressor_Compress(b *testing.B) {
+	dir := filepath.Join(os.TempDir(), "eidos_bench")
+	_ = os.MkdirAll(dir, 0755)
+	defer func() {
+		// Cleaning up the log directory
+		_ = clean(dir)
+	}()
+
+	// Writing the log file, which is compressed by every iteration
+	source := filepath.Join(dir, "compress.log")
+	var content bytes.Buffer
+	for content.Len() < 1024*1024 {
+		content.WriteString(randStringBytes(127) + "\n")
+	}
+	_ = ioutil.WriteFile(source, content.Bytes(), 0644)
+
+	for _, format := range []CompressionFormat{Gzip, LZ4, Snappy} {
+		compressor := (&Options{CompressionFormat: format, CompressionLevel: gzip.BestSpeed}).compressor()
+		b.Run(format.String(), func(b *testing.B) {
+			b.SetBytes(int64(content.Len()))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = compressor.Compress(source, source+compressor.Ext())
+			}
+		})
+	}
 }
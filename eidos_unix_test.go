@@ -0,0 +1,345 @@
+//go:build !windows
+// +build !windows
+
+package eidos
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestLogger_HandleSignals(t *testing.T) {
+
+	var rotateCh = make(chan string, 1)
+	logger, _ := New("", &Options{}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	logger.HandleSignals(syscall.SIGUSR1)
+
+	log.SetOutput(logger)
+	log.Println(randStringBytes(1024))
+
+	// Sending the signal to the current process
+	_ = syscall.Kill(os.Getpid(), syscall.SIGUSR1)
+
+	select {
+	case file := <-rotateCh:
+		_, err := os.Stat(file)
+		equals(
+			os.IsNotExist(err),
+			false,
+			t,
+			"Error. The rotated log file should be created",
+		)
+	case <-time.After(2 * time.Second):
+		t.Logf("Error- The log file should be rotated on receiving the signal")
+		t.FailNow()
+	}
+}
+
+func TestLogger_HandleReopenSignals(t *testing.T) {
+
+	var rotateCh = make(chan string, 1)
+	logger, _ := New("", &Options{}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	logger.HandleReopenSignals(syscall.SIGUSR2)
+
+	_, _ = logger.Write([]byte(randStringBytes(1024)))
+
+	// Moving the log file like logrotate and sending the signal
+	movedFile := logger.Filename + ".1"
+	_ = os.Rename(logger.Filename, movedFile)
+	_ = syscall.Kill(os.Getpid(), syscall.SIGUSR2)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := os.Stat(logger.Filename); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Logf("Error- The log file should be reopened on receiving the signal")
+			t.FailNow()
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	_, _ = logger.Write([]byte(randStringBytes(512)))
+	fileInfo, _ := os.Stat(logger.Filename)
+	equals(
+		fileInfo.Size(),
+		int64(512),
+		t,
+		"Error. The reopened log file should only contain the logs written after the signal",
+	)
+	movedFileInfo, _ := os.Stat(movedFile)
+	equals(
+		movedFileInfo.Size(),
+		int64(1024),
+		t,
+		"Error. The moved log file should not be modified",
+	)
+	select {
+	case file := <-rotateCh:
+		t.Logf("Error- The log file should not be rotated on reopening, got %s", file)
+		t.FailNow()
+	default:
+	}
+}
+
+func TestLogger_Rotate_CopyTruncate(t *testing.T) {
+
+	var rotateCh = make(chan string, 1)
+	logger, _ := New("", &Options{
+		CopyTruncate: true,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	log.SetOutput(logger)
+	log.Println(randStringBytes(1024))
+
+	before, _ := os.Stat(logger.Filename)
+	equals(
+		logger.Rotate(),
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+	after, _ := os.Stat(logger.Filename)
+
+	// The log file should be truncated in place
+	equals(
+		after.Sys().(*syscall.Stat_t).Ino,
+		before.Sys().(*syscall.Stat_t).Ino,
+		t,
+		"Error. The inode of the log file should not change",
+	)
+	equals(
+		after.Size(),
+		int64(0),
+		t,
+		"Error. The log file should be truncated",
+	)
+
+	// Checking the content of the rotated log file
+	rotatedFileInfo, err := os.Stat(<-rotateCh)
+	equals(
+		err,
+		nil,
+		t,
+		"Error. The rotated log file should be created",
+	)
+	equals(
+		rotatedFileInfo.Size(),
+		before.Size(),
+		t,
+		"Error. The rotated log file should contain the copied logs",
+	)
+}
+
+func TestLogger_Rotate_TimestampedActiveFile(t *testing.T) {
+
+	var rotateCh = make(chan string, 1)
+	logger, _ := New("", &Options{
+		TimestampedActiveFile: true,
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	log.SetOutput(logger)
+	log.Println(randStringBytes(1024))
+
+	// The log file should be a symlink to the timestamped active file
+	activeFile, err := filepath.EvalSymlinks(logger.Filename)
+	equals(
+		err == nil && activeFile != logger.Filename,
+		true,
+		t,
+		"Error. The log file should be a symlink to the timestamped active file",
+	)
+
+	equals(
+		logger.Rotate(),
+		nil,
+		t,
+		"Error. Failed to rotate the log file manually",
+	)
+
+	// The previous active file should be the rotated log file
+	equals(
+		<-rotateCh,
+		activeFile,
+		t,
+		"Error. The previous timestamped active file should be the rotated log file",
+	)
+	newActiveFile, _ := filepath.EvalSymlinks(logger.Filename)
+	equals(
+		newActiveFile != activeFile,
+		true,
+		t,
+		"Error. The symlink should point to a new timestamped active file",
+	)
+
+	// The active file should not be listed as a backup
+	backups, _ := backupFiles(logger.Filename, logger.RotationOption)
+	equals(
+		len(backups),
+		1,
+		t,
+		"Error. Only the previous timestamped active file should be a backup",
+	)
+}
+
+func TestLogger_Rotate_LatestBackupLink(t *testing.T) {
+	var rotateCh = make(chan string, 1)
+	logger, _ := New("", &Options{
+		LatestBackupLink: "eidos.log.prev",
+	}, &Callback{
+		Execute: func(s string) {
+			rotateCh <- s
+		},
+	})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	link := filepath.Join(filepath.Dir(logger.Filename), "eidos.log.prev")
+	log.SetOutput(logger)
+	for i := 0; i < 2; i++ {
+		log.Println(randStringBytes(1024))
+		equals(
+			logger.Rotate(),
+			nil,
+			t,
+			"Error. Failed to rotate the log file manually",
+		)
+
+		// The symlink should point to the most recent rotated file
+		rotatedFile := <-rotateCh
+		target, err := filepath.EvalSymlinks(link)
+		equals(
+			err == nil && target == rotatedFile,
+			true,
+			t,
+			"Error. The latest backup symlink should point to the most recent rotated file",
+		)
+	}
+
+	// The symlink should not be listed as a backup
+	backups, _ := backupFiles(logger.Filename, logger.RotationOption)
+	equals(
+		len(backups),
+		2,
+		t,
+		"Error. Only the rotated files should be listed as backups",
+	)
+}
+
+func TestSyncDir(t *testing.T) {
+	dir := filepath.Join(os.TempDir(), "eidos-sync-dir")
+	_ = os.MkdirAll(dir, 0755)
+	defer func() {
+		_ = clean(dir)
+	}()
+
+	equals(syncDir(dir), nil, t, "Error. Failed to sync the directory")
+	equals(
+		syncDir(filepath.Join(dir, "missing")) != nil,
+		true,
+		t,
+		"Error. Syncing a missing directory should fail",
+	)
+}
+
+func TestLogger_Preallocate(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the preallocation is only supported on linux")
+	}
+
+	logger, _ := New("", &Options{
+		SizeBytes:   1024 * 1024,
+		Preallocate: true,
+	}, &Callback{})
+
+	defer func() {
+		// Closing the logger to clean up the log directory
+		_ = logger.Close()
+		// Cleaning up the log directory
+		_ = clean(filepath.Dir(logger.Filename))
+	}()
+
+	_, _ = logger.Write([]byte("eidos\n"))
+
+	// The blocks of the log file are reserved without changing its size
+	fileInfo, _ := os.Stat(logger.Filename)
+	equals(
+		fileInfo.Size(),
+		int64(6),
+		t,
+		"Error. The size of the preallocated log file should not be changed",
+	)
+	equals(
+		fileInfo.Sys().(*syscall.Stat_t).Blocks*512 >= 1024*1024,
+		true,
+		t,
+		"Error. The blocks of the log file should be preallocated",
+	)
+
+	// The unused blocks are released when the log file is closed
+	_ = logger.Close()
+	fileInfo, _ = os.Stat(logger.Filename)
+	equals(
+		fileInfo.Sys().(*syscall.Stat_t).Blocks*512 < 1024*1024,
+		true,
+		t,
+		"Error. The preallocated blocks should be released on close",
+	)
+}
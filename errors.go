@@ -0,0 +1,51 @@
+package eidos
+
+import (
+	"errors"
+)
+
+// The errors returned by New for the misconfigured options. The returned
+// error wraps one of these errors, so it can be matched with errors.Is.
+var (
+	// ErrInvalidSize is returned for a negative Size, SizeBytes or MinSize
+	ErrInvalidSize = errors.New("invalid size")
+	// ErrInvalidPeriod is returned for a negative Period or RotationJitter
+	ErrInvalidPeriod = errors.New("invalid period")
+	// ErrInvalidCompressionLevel is returned for a CompressionLevel
+	// out of the range of the gzip compression levels
+	ErrInvalidCompressionLevel = errors.New("invalid compression level")
+	// ErrInvalidRetention is returned for a negative RetentionPeriod,
+	// RetentionDuration, CleanupInterval or MaxTotalSize
+	ErrInvalidRetention = errors.New("invalid retention")
+	// ErrInvalidOption is returned for any other option with an invalid value
+	ErrInvalidOption = errors.New("invalid option")
+	// ErrIncompatibleOptions is returned for the options,
+	// which can not be used together
+	ErrIncompatibleOptions = errors.New("incompatible options")
+	// ErrFileInUse is returned for a log file, which is
+	// already written by another open logger of the process
+	ErrFileInUse = errors.New("log file in use")
+)
+
+// ErrClosed is returned by the Write and the rotations of a closed logger
+var ErrClosed = errors.New("logger is closed")
+
+// ErrLogDirRemoved is reported to the OnError callback, when the directory
+// of the log file has been removed at runtime, ex- deleted by an operator
+// or unmounted. The logger recreates the directory and reopens the log file.
+var ErrLogDirRemoved = errors.New("log directory removed")
+
+// ErrWriteFallback is reported to the OnError callback, when the log file can
+// not be opened or written and the logs are redirected to the FallbackWriter.
+// The error of the log file is wrapped in the reported error.
+var ErrWriteFallback = errors.New("writing to the fallback writer")
+
+// ErrWriteTimeout is returned by the Write, when a write to the log file has
+// not returned within the Options.WriteTimeout, ex- on a dying disk or a stale
+// NFS mount, and the logs are redirected to the FallbackWriter.
+var ErrWriteTimeout = errors.New("write timed out")
+
+// ErrRateLimited is reported to the OnError callback, when the writes exceeding
+// the Options.RateLimit start being dropped. It is reported once, till a write
+// is admitted again, and the dropped lines are counted by the Logger.DroppedRecords.
+var ErrRateLimited = errors.New("rate limit exceeded")
@@ -0,0 +1,74 @@
+package eidos
+
+import (
+	"fmt"
+	"time"
+)
+
+// RotationReason represents the trigger of a rotation of the log file
+type RotationReason int
+
+const (
+	// RotationSize rotates the log file, which has reached the maximum size
+	RotationSize RotationReason = iota
+	// RotationPeriod rotates the log file on the Period or the Schedule
+	RotationPeriod
+	// RotationManual rotates the log file on the Rotate, ex- triggered
+	// by a signal, the rotation marker or the admin handler
+	RotationManual
+	// RotationOpen rotates the existing log file, which
+	// can not be opened for appending the logs
+	RotationOpen
+	// RotationBundle bundles the rotated log files into an archive
+	RotationBundle
+)
+
+// String returns the name of the rotation reason
+func (r RotationReason) String() string {
+	switch r {
+	case RotationSize:
+		return "size"
+	case RotationPeriod:
+		return "period"
+	case RotationManual:
+		return "manual"
+	case RotationOpen:
+		return "open"
+	case RotationBundle:
+		return "bundle"
+	}
+	return fmt.Sprintf("RotationReason(%d)", int(r))
+}
+
+// RotationEvent describes a rotation of the log file
+type RotationEvent struct {
+	// File is the name of the log file, which was rotated
+	File string `json:"file"`
+	// BackupFile is the name of the rotated log file, or the bundle
+	BackupFile string `json:"backup_file"`
+	// CompressedFile is the name of the compressed log file,
+	// if the rotated log file is compressed after the rotation
+	CompressedFile string `json:"compressed_file"`
+	// Reason is the trigger of the rotation
+	Reason RotationReason `json:"reason"`
+	// Size is the size of the rotated log file in bytes
+	Size int64 `json:"size"`
+	// Checksum is the hex encoded SHA-256 digest of the rotated log file,
+	// if Options.Checksum is enabled. The checksum file is written before
+	// the callbacks are called.
+	Checksum string `json:"checksum"`
+	// RotationDuration is the time taken to rename the log file and to open
+	// a new log file, and CompressionDuration is the time taken to compress
+	// the rotated log file, if any
+	RotationDuration    time.Duration `json:"rotation_duration"`
+	CompressionDuration time.Duration `json:"compression_duration"`
+}
+
+// rotatedFile returns the name of the final rotated log file, which
+// is the compressed log file, if the rotated log file is compressed
+func (e RotationEvent) rotatedFile() string {
+	if e.CompressedFile != "" {
+		return e.CompressedFile
+	}
+	return e.BackupFile
+}
@@ -0,0 +1,83 @@
+package eidos
+
+import (
+	"time"
+)
+
+// filter appends the data to out, holding the partial lines in the partial,
+// if any, dropping the lines sampled out by the Sampling and collapsing the
+// repeated lines as per the DedupWindow. The joined and the sampled buffers
+// hold the data between the stages.
+func (l *Logger) filter(out, p []byte, partial *partialLine, joined, sampled *[]byte, now time.Time) []byte {
+	if partial != nil {
+		if l.sampler == nil && l.dedup == nil {
+			return partial.complete(out, p)
+		}
+		*joined = partial.complete((*joined)[:0], p)
+		p = *joined
+	}
+	if l.sampler != nil {
+		if l.dedup == nil {
+			return l.sampler.filter(out, p)
+		}
+		*sampled = l.sampler.filter((*sampled)[:0], p)
+		p = *sampled
+	}
+	if l.dedup == nil {
+		return append(out, p...)
+	}
+	return l.dedup.filter(out, p, now)
+}
+
+// writeFiltered writes the filtered data, holding the partial lines in the
+// partial, if any. The held and the filtered out lines are accounted as
+// written. The data is filtered and written holding the filterMutex, so the
+// lines are written in the order of the filtering, ex- the summary of the
+// collapsed lines is written right before the next different line.
+func (l *Logger) writeFiltered(p []byte, partial *partialLine) (int, error) {
+	// The synchronous callbacks are called after unlocking the filterMutex
+	defer l.runPendingRotations()
+	l.filterMutex.Lock()
+	defer l.filterMutex.Unlock()
+
+	buffer, joined, sampled := getWriteBuffer(len(p)), getWriteBuffer(0), getWriteBuffer(0)
+	defer putWriteBuffer(buffer)
+	defer putWriteBuffer(joined)
+	defer putWriteBuffer(sampled)
+	*buffer = l.filter((*buffer)[:0], p, partial, joined, sampled, l.RotationOption.now())
+	if len(*buffer) == 0 {
+		return len(p), nil
+	}
+	if _, err := l.writeAdmitted(*buffer); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// filterRecords returns the filtered records, which are copied into the
+// buffer. The records without any remaining line are removed. It is called
+// holding the filterMutex.
+func (l *Logger) filterRecords(records [][]byte, buffer *[]byte) [][]byte {
+	joined, sampled := getWriteBuffer(0), getWriteBuffer(0)
+	defer putWriteBuffer(joined)
+	defer putWriteBuffer(sampled)
+
+	now := l.RotationOption.now()
+	ends := make([]int, 0, len(records))
+	*buffer = (*buffer)[:0]
+	for _, record := range records {
+		*buffer = l.filter(*buffer, record, l.partial, joined, sampled, now)
+		ends = append(ends, len(*buffer))
+	}
+
+	// Slicing the records after the buffer is filled, as it may be reallocated
+	filtered := make([][]byte, 0, len(ends))
+	start := 0
+	for _, end := range ends {
+		if end > start {
+			filtered = append(filtered, (*buffer)[start:end])
+		}
+		start = end
+	}
+	return filtered
+}
@@ -0,0 +1,44 @@
+package eidos
+
+// Hooks is the interface implemented by the observability and the shipping
+// integrations, which attach to every stage of the lifecycle of the log files.
+// The NopHooks can be embedded to implement only the required methods.
+type Hooks interface {
+	// OnOpen is called after a log file is opened for writing. It is called
+	// with the lock of the logger held, so it must not write to the logger.
+	OnOpen(file string)
+	// OnRotate is called after a log file is rotated and compressed, if
+	// compression is enabled
+	OnRotate(event RotationEvent)
+	// OnCompressStart is called before a rotated log file is compressed
+	OnCompressStart(file string)
+	// OnCompressDone is called after a rotated log file is compressed
+	OnCompressDone(stats CompressionStats)
+	// OnDelete is called after a rotated log file is removed, or
+	// archived into the ArchiveDir, by the retention or on a low disk space
+	OnDelete(file string)
+	// OnError is called on a failure of a background operation, ex- a
+	// compression or a removal of a rotated log file
+	OnError(err error)
+}
+
+// NopHooks implements the Hooks interface without any operation
+type NopHooks struct{}
+
+// OnOpen implements the Hooks interface
+func (NopHooks) OnOpen(string) {}
+
+// OnRotate implements the Hooks interface
+func (NopHooks) OnRotate(RotationEvent) {}
+
+// OnCompressStart implements the Hooks interface
+func (NopHooks) OnCompressStart(string) {}
+
+// OnCompressDone implements the Hooks interface
+func (NopHooks) OnCompressDone(CompressionStats) {}
+
+// OnDelete implements the Hooks interface
+func (NopHooks) OnDelete(string) {}
+
+// OnError implements the Hooks interface
+func (NopHooks) OnError(error) {}
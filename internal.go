@@ -1,13 +1,19 @@
 package eidos
 
 import (
-	"compress/gzip"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -18,23 +24,71 @@ var (
 	defaultMaxPeriod = 7 * 24 * time.Hour
 	megabyte         = 1024 * 1024
 	backupTimeFormat = "2006-01-02T15-04-05.000"
-	currentTime      = time.Now
+	// dailyFileTimeFormat represents the default time format of the daily log files
+	dailyFileTimeFormat = "2006-01-02"
+	// fileCheckInterval represents the minimum interval between two checks
+	// for the external removal or move of the log file
+	fileCheckInterval = time.Second
+	// sizeCheckInterval represents the minimum interval between two
+	// reconciliations of the tracked size with the size of the log file
+	sizeCheckInterval = time.Second
+	// markerCheckInterval represents the interval between two checks
+	// for the modification of the rotation marker file
+	markerCheckInterval = time.Second
+	// tempFileExtension represents the extension of the temporary compressed files
+	tempFileExtension = ".tmp"
+	// checksumExtension represents the extension of the checksum files
+	checksumExtension = ".sha256"
+	// defaultFlushInterval represents the default interval between two flushes
+	// of the write buffer and the compression stream of the active log file
+	defaultFlushInterval = time.Second
+	// compressCheckInterval represents the maximum interval between two
+	// sweeps for the rotated log files, which are older than CompressAfter
+	compressCheckInterval = time.Minute
+	// diskCheckInterval represents the interval between two checks
+	// for the free space of the filesystem of the log files
+	diskCheckInterval = time.Minute
+	// defaultCallbackQueueSize represents the default maximum number
+	// of the rotated file names queued for the Callback.Execute
+	defaultCallbackQueueSize = 16
+	// defaultCallbackBackoff represents the default
+	// backoff of the first retry of the Callback.Deliver
+	defaultCallbackBackoff = time.Second
+	// webhookTimeout, webhookRetries and webhookBackoff represent the timeout
+	// of a request, the number of the retries and the backoff of the first
+	// retry of the WebhookNotifier
+	webhookTimeout = 10 * time.Second
+	webhookRetries = 3
+	webhookBackoff = time.Second
+	// defaultCleanupInterval represents the default interval between
+	// two scans for the rotated log files exceeding the retention
+	defaultCleanupInterval = time.Hour
+	diskFreePercent        = freeDiskPercent
+	currentTime            = time.Now
 )
 
 // max return the maximum filesize
 func (l *Logger) max() int64 {
+	if l.RotationOption.SizeBytes > 0 {
+		return l.RotationOption.SizeBytes
+	}
 	return int64(l.RotationOption.Size) * int64(megabyte)
 }
 
 // openExistingOrNewFile opens an existing log file or creates a new file.
 func (l *Logger) openExistingOrNewFile() error {
+	if l.RotationOption.DailyFile {
+		_, err := l.openDailyFile()
+		return err
+	}
+
 	fileName := l.Filename
 	fileInfo, err := os.Stat(fileName)
 
 	// Checking for existence of the file
 	if os.IsNotExist(err) {
 		// Files does not exist, creating a new file
-		return l.openNewFile()
+		return l.openNewFile("", RotationOpen)
 	}
 
 	if err != nil {
@@ -45,18 +99,398 @@ func (l *Logger) openExistingOrNewFile() error {
 	file, err := os.OpenFile(fileName, os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
 		// If for any reason, the existing file can not be opened, open a new file
-		return l.openNewFile()
+		return l.openNewFile("", RotationOpen)
 	}
 
 	// Assigning the file pointer and file size to *Logger
-	l.file = file
+	if err := l.setFile(file); err != nil {
+		return err
+	}
 	l.size = fileInfo.Size()
-	return nil
+	return l.writeHeader()
+}
+
+// write writes the data admitted by the rate limit, if any. The dropped data
+// is accounted as written.
+func (l *Logger) write(p []byte) (int, error) {
+	// The synchronous callbacks are called after unlocking the logger
+	defer l.runPendingRotations()
+	return l.writeAdmitted(p)
+}
+
+// writeAdmitted writes the data like the write, the post rotation of
+// the rotations done by the write is left to the caller
+func (l *Logger) writeAdmitted(p []byte) (n int, err error) {
+	// The writes exceeding the rate limit are dropped, or they wait
+	// for the rate limit before locking the logger
+	if !l.admit(p) {
+		return len(p), nil
+	}
+
+	// The writes, which neither rotate nor reopen the log file, are done
+	// holding the shared lock, so the concurrent writes are not serialized
+	shared, ok := l.writeShared(p)
+	if ok {
+		return shared, nil
+	}
+	n, err = l.writeExclusive(p[shared:])
+	return shared + n, err
+}
+
+// writeExclusive writes the data holding the exclusive lock of the logger,
+// rotating, reopening or falling back the log file, if required
+func (l *Logger) writeExclusive(p []byte) (n int, err error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// The closed logger does not reopen the log file
+	if l.lifecycle == lifecycleClosed {
+		return 0, ErrClosed
+	}
+	return l.writeLocked(p)
+}
+
+// writeLocked writes the data holding the exclusive lock of the logger. The
+// oversize data is rejected, unless it is requested to be split or allowed.
+func (l *Logger) writeLocked(p []byte) (n int, err error) {
+	writeRequestLength := int64(len(p))
+	maxFileSize := l.max()
+	sizeRotation := !l.RotationOption.DisableSizeRotation
+
+	// If the requested write length exceeds the maximum file size then return err,
+	// unless the oversize writes are requested to be split across rotations or allowed
+	oversize := sizeRotation && writeRequestLength > maxFileSize
+	if oversize && !l.RotationOption.SplitOversizeWrites && !l.RotationOption.AllowOversizeWrites {
+		return 0,
+			fmt.Errorf(
+				"write request size %d exceed max file size %d", writeRequestLength, maxFileSize,
+			)
+	}
+
+	// If the log file can not be opened or written,
+	// then the data is written to the FallbackWriter
+	n, err = l.writeLog(p, oversize)
+	if err != nil {
+		l.writeFallback(p[n:], err)
+	} else {
+		l.leaveFallback()
+	}
+	return n, err
+}
+
+// writeShared writes the data to the log file holding the shared lock of the
+// logger, if the write neither rotates, reopens nor falls back the log file,
+// and it goes neither through the write buffer, the compression stream nor
+// the writer goroutine of the WriteTimeout. The concurrent shared writes are
+// atomic, as the log file is opened in the append mode. It returns false, if
+// the data after the returned number of the written bytes should be written
+// holding the exclusive lock of the logger.
+func (l *Logger) writeShared(p []byte) (int, bool) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	now := l.RotationOption.now()
+	if !l.shareable(now) {
+		return 0, false
+	}
+
+	// Reserving the room for the data in the log file, as the sizes
+	// of the concurrent shared writes are accounted atomically
+	length := int64(len(p))
+	if size := atomic.AddInt64(&l.size, length); !l.RotationOption.DisableSizeRotation && size > l.max() {
+		atomic.AddInt64(&l.size, -length)
+		return 0, false
+	}
+
+	n, err := l.file.Write(p)
+	if n > 0 {
+		l.recordWrite(p[:n], now)
+	}
+	if err != nil {
+		// The failed write is retried holding the exclusive
+		// lock, which falls back the log file, if required
+		atomic.AddInt64(&l.size, int64(n)-length)
+		return n, false
+	}
+	return n, true
+}
+
+// shareable returns true, if the log file can be written holding the shared
+// lock of the logger. The periodic checks of the log file, its directory and
+// its size are done by the writes holding the exclusive lock.
+func (l *Logger) shareable(now time.Time) bool {
+	if l.lifecycle == lifecycleClosed || l.file == nil || l.buffer != nil || l.stream != nil ||
+		l.deadline != nil || l.fallback {
+		return false
+	}
+	return now.Sub(l.lastDirCheck) < fileCheckInterval &&
+		now.Sub(l.lastSizeCheck) < sizeCheckInterval &&
+		(!l.RotationOption.DetectExternalRotation || now.Sub(l.lastFileCheck) < fileCheckInterval)
+}
+
+// recordWrite updates the statistics of the log file and the time of the
+// last write. The statistics are guarded by the statsMutex, as the shared
+// writes update them concurrently.
+func (l *Logger) recordWrite(p []byte, now time.Time) {
+	lines := int64(bytes.Count(p, []byte{'\n'}))
+	l.statsMutex.Lock()
+	defer l.statsMutex.Unlock()
+	if l.stats.FirstWrite.IsZero() {
+		l.stats.FirstWrite = now
+	}
+	l.stats.LastWrite = now
+	l.stats.Lines += lines
+	l.stats.Bytes += int64(len(p))
+	l.lastWrite = now
+}
+
+// writeLog opens the log file, if required, and writes the data
+// to the log file, rotating it on reaching the maximum size
+func (l *Logger) writeLog(p []byte, oversize bool) (n int, err error) {
+	// If the log directory has been removed then recreate it and reopen the log file
+	if err := l.recoverLogDir(); err != nil {
+		return 0, err
+	}
+
+	// If the file pointer in the Logger object is nil then open a new/existing log file
+	if l.file == nil {
+		if err := l.openExistingOrNewFile(); err != nil {
+			return 0, err
+		}
+	}
+
+	// If the log file has been removed or moved by an external tool then reopen the log file
+	if err := l.reopenIfMoved(); err != nil {
+		return 0, err
+	}
+
+	// If the log file has been appended by another process then correct the tracked size
+	l.reconcileSize()
+
+	switch {
+	case oversize && l.RotationOption.SplitOversizeWrites:
+		// Write the requested data across multiple rotations
+		n, err = l.writeSplit(p)
+	case oversize:
+		// Write the requested data in full to a fresh log file
+		n, err = l.writeOversize(p)
+	default:
+		// If writing the requested data to the file will make the file size
+		// exceed the max allowed filesize, then rotate the current file.
+		if !l.RotationOption.DisableSizeRotation && l.size+int64(len(p)) > l.max() {
+			if err := l.rotate(RotationSize); err != nil {
+				return 0, err
+			}
+		}
+
+		// Write the requested data to the file, increasing
+		// the file size by request content length
+		n, err = l.writeFile(p)
+	}
+	return n, err
+}
+
+// writeFallback writes the data, which could not be written to the log file,
+// to the FallbackWriter. The switch to the FallbackWriter is reported once,
+// till the writes go back to the log file.
+func (l *Logger) writeFallback(p []byte, err error) {
+	if !l.fallback {
+		l.fallback = true
+		l.reportError(fmt.Errorf("%w: %v", ErrWriteFallback, err))
+	}
+	_, _ = l.RotationOption.FallbackWriter.Write(p)
+}
+
+// leaveFallback calls the Recovered callback, if the writes have
+// gone back to the log file after being redirected to the FallbackWriter
+func (l *Logger) leaveFallback() {
+	if !l.fallback {
+		return
+	}
+	l.fallback = false
+	go l.callback.Recovered(l.Filename)
+}
+
+// recoverLogDir recreates the directory of the log file and reopens the log
+// file, if the directory has been removed at runtime. Otherwise, the writes to
+// the unlinked log file are lost and the rotations keep failing. The check is
+// done at most once in fileCheckInterval, while a log file is opened.
+func (l *Logger) recoverLogDir() error {
+	now := l.RotationOption.now()
+	if l.file != nil && now.Sub(l.lastDirCheck) < fileCheckInterval {
+		return nil
+	}
+	l.lastDirCheck = now
+
+	dir := filepath.Dir(l.Filename)
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		return nil
+	}
+	l.reportError(fmt.Errorf("%w: %s", ErrLogDirRemoved, dir))
+
+	// The opened log file has been unlinked along with the directory
+	_ = l.close()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("can't recreate log directory: %s", err)
+	}
+	return l.openExistingOrNewFile()
+}
+
+// reopenIfMoved reopens the log file, if the opened log file has been removed or
+// moved by an external tool. The check is done at most once in fileCheckInterval.
+func (l *Logger) reopenIfMoved() error {
+	if !l.RotationOption.DetectExternalRotation || l.file == nil {
+		return nil
+	}
+
+	now := l.RotationOption.now()
+	if now.Sub(l.lastFileCheck) < fileCheckInterval {
+		return nil
+	}
+	l.lastFileCheck = now
+
+	openedFileInfo, err := l.file.Stat()
+	if err != nil {
+		return nil
+	}
+
+	// Checking whether the filename still points to the opened log file
+	fileName := l.Filename
+	if l.RotationOption.DailyFile {
+		fileName = l.dailyFileName
+	}
+	fileInfo, err := os.Stat(fileName)
+	if err == nil && os.SameFile(openedFileInfo, fileInfo) {
+		return nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil
+	}
+
+	if err := l.close(); err != nil {
+		return err
+	}
+	return l.openExistingOrNewFile()
+}
+
+// reconcileSize corrects the tracked size of the log file, which drifts from
+// the size of the log file, if another process appends to the log file or a
+// write partially fails. The check is done at most once in sizeCheckInterval,
+// or on the next write after a failed write. The size of a compressed stream
+// is the uncompressed size, so it is never reconciled.
+func (l *Logger) reconcileSize() {
+	if l.file == nil || l.stream != nil {
+		return
+	}
+
+	now := l.RotationOption.now()
+	if now.Sub(l.lastSizeCheck) < sizeCheckInterval {
+		return
+	}
+	l.lastSizeCheck = now
+
+	if fileInfo, err := l.file.Stat(); err == nil {
+		l.size = fileInfo.Size()
+		// The buffered logs are not written to the log file yet
+		if l.buffer != nil {
+			l.size += int64(l.buffer.Buffered())
+		}
+	}
+}
+
+// openNewFile opens a new file and triggers the post rotation
+// thread for the backup file, if any
+func (l *Logger) openNewFile(tag string, reason RotationReason) error {
+	start := l.RotationOption.now()
+	backupFileName, err := l.backupAndOpenNewFile(tag)
+	if backupFileName == "" {
+		return err
+	}
+	if reason != RotationPeriod {
+		l.resetPeriod()
+	}
+
+	// The post rotation of the backup file is triggered after unlocking the logger
+	l.pendingEvents = append(l.pendingEvents, l.rotationEvent(backupFileName, reason, start))
+	atomic.StoreInt32(&l.eventsPending, 1)
+	return err
+}
+
+// runPendingRotations triggers the post rotation of the rotations done while
+// the logger was locked. If the callbacks are synchronous, then the backup
+// files are compressed and the callbacks are called in the rotating goroutine.
+// It is called after unlocking the logger, so the callbacks can write to the
+// logger, and a full compression pool does not block the other writers.
+func (l *Logger) runPendingRotations() {
+	// The writes without any rotation do not lock the logger again
+	if atomic.LoadInt32(&l.eventsPending) == 0 {
+		return
+	}
+
+	l.mutex.Lock()
+	events := l.pendingEvents
+	l.pendingEvents = nil
+	atomic.StoreInt32(&l.eventsPending, 0)
+	l.mutex.Unlock()
+
+	for _, event := range events {
+		if l.RotationOption.SynchronousCallbacks {
+			l.finishRotation(event)
+		} else {
+			l.postRotation(event)
+		}
+	}
+}
+
+// finishRotation compresses or bundles the backup file of the rotation in
+// the calling goroutine and triggers the callbacks, synchronously if the
+// callbacks are synchronous. It returns the final name of the backup file,
+// which is the name of the archive, if the backup file was bundled.
+func (l *Logger) finishRotation(event RotationEvent) string {
+	if l.RotationOption.bundling() {
+		if bundleFileName, ok := l.bundlePending()[event.BackupFile]; ok {
+			return bundleFileName
+		}
+		return event.BackupFile
+	}
+
+	event = l.compressBackup(event)
+	if l.RotationOption.SynchronousCallbacks {
+		l.notifyRotation(event)
+	} else {
+		l.goTracked(func() { l.notifyRotation(event) })
+	}
+	return event.rotatedFile()
+}
+
+// rotationEvent returns the event of the rotation of the log
+// file into the backup file, which was started at the start
+func (l *Logger) rotationEvent(backupFileName string, reason RotationReason, start time.Time) RotationEvent {
+	event := RotationEvent{
+		File:             l.Filename,
+		BackupFile:       backupFileName,
+		Reason:           reason,
+		RotationDuration: l.RotationOption.now().Sub(start),
+	}
+	if fileInfo, err := os.Stat(backupFileName); err == nil {
+		event.Size = fileInfo.Size()
+	}
+	return event
 }
 
-// openNewFile opens a new file
-func (l *Logger) openNewFile() error {
+// backupAndOpenNewFile renames the existing log file as a backup file and
+// opens a new file. It returns the backup filename, if a backup was created.
+// The tag, if any, is embedded into the backup filename.
+func (l *Logger) backupAndOpenNewFile(tag string) (string, error) {
+	if l.RotationOption.TimestampedActiveFile {
+		return l.openTimestampedFile()
+	}
+	if l.RotationOption.DailyFile {
+		return l.openDailyFile()
+	}
+
 	fileName := l.Filename
+	backupFileName := ""
 	fileMode := os.FileMode(0666)
 
 	// Getting the status of the requested file
@@ -64,206 +498,1216 @@ func (l *Logger) openNewFile() error {
 	// If there is not error in file status request
 	if err == nil {
 		// get a backup filename
-		backupFileName := backupName(fileName, l.RotationOption.LocalTime)
+		if l.RotationOption.BackupNaming == SequenceNaming {
+			// The backups are not shifted while a backup is being compressed
+			l.shiftMutex.Lock()
+			backupFileName, err = shiftBackups(fileName)
+			l.shiftMutex.Unlock()
+			if err != nil {
+				return "", err
+			}
+		} else {
+			// The backups are placed under the BackupDir, if any
+			backupFileName = uniqueBackupName(fileName, backupName(filepath.Join(l.RotationOption.backupDir(fileName), filepath.Base(fileName)), tag, l.RotationOption), l.RotationOption)
+		}
+		// The streamed log file is already compressed
+		if l.RotationOption.StreamCompression {
+			backupFileName += Gzip.extension()
+		}
 		fileMode = fileInfo.Mode()
 
-		// rename file as backup file
-		if err := os.Rename(fileName, backupFileName); err != nil {
-			return fmt.Errorf("can't rename log file: %s", err)
+		// Creating the date partition directory of the backup file
+		if err := os.MkdirAll(filepath.Dir(backupFileName), 0755); err != nil {
+			return "", fmt.Errorf("can't create backup directory: %s", err)
 		}
 
-		if err := chown(fileName, fileInfo); err != nil {
-			return err
-		}
+		if l.RotationOption.CopyTruncate {
+			// copy file as backup file, the file will be truncated in place
+			if err := copyFile(fileName, backupFileName, fileInfo); err != nil {
+				return "", fmt.Errorf("can't copy log file: %s", err)
+			}
+		} else {
+			// rename file as backup file
+			if err := os.Rename(fileName, backupFileName); err != nil {
+				return "", fmt.Errorf("can't rename log file: %s", err)
+			}
 
-		// Trigger the post rotation thread
-		go postRotation(
-			backupFileName,
-			l.RotationOption.Compress,
-			l.RotationOption.CompressionLevel,
-		)
+			if err := chown(fileName, fileInfo); err != nil {
+				return backupFileName, err
+			}
+		}
 	}
 
 	// create a file to write current logs
-	f, err := os.OpenFile(fileName, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fileMode)
+	f, err := os.OpenFile(fileName, os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_APPEND, fileMode)
 	if err != nil {
-		return fmt.Errorf("can't open new logfile: %s", err)
+		return backupFileName, fmt.Errorf("can't open new logfile: %s", err)
+	}
+
+	// Committing the renamed backup and the new log file entries to the disk
+	if err := l.syncDirs(fileName, backupFileName); err != nil {
+		f.Close()
+		return backupFileName, err
 	}
 
 	// Assigning the file pointer and file size to *Logger
-	l.file = f
+	if err := l.setFile(f); err != nil {
+		return backupFileName, err
+	}
 	l.size = 0
-	return nil
+	return backupFileName, l.writeHeader()
 }
 
-// backupName returns a backup name for the current file
-func backupName(name string, localTime bool) string {
-	dir := filepath.Dir(name)
-	filename := filepath.Base(name)
-	ext := filepath.Ext(filename)
-	// if the localTime is true then use the system time to generate backup file name
-	//if the localTime is false then use UTC time to generate backup file name
-	t := currentTime()
-	if !localTime {
-		t = t.UTC()
+// openTimestampedFile opens a new timestamped log file and points the Filename
+// symlink to it. The previous timestamped log file becomes the backup file, so
+// no file is renamed on rotation. It returns the backup filename, if any.
+func (l *Logger) openTimestampedFile() (string, error) {
+	fileName := l.Filename
+	backupFileName := ""
+	fileMode := os.FileMode(0666)
+
+	if linkInfo, err := os.Lstat(fileName); err == nil {
+		if linkInfo.Mode()&os.ModeSymlink != 0 {
+			// The previous active log file becomes the backup file
+			if target, err := filepath.EvalSymlinks(fileName); err == nil {
+				backupFileName = target
+				if fileInfo, err := os.Stat(target); err == nil {
+					fileMode = fileInfo.Mode()
+				}
+			}
+		} else {
+			// A regular log file, ex- written before enabling the
+			// timestamped active file, is renamed as a backup file
+			backupFileName = uniqueBackupName(fileName, backupName(fileName, "", l.RotationOption), l.RotationOption)
+			fileMode = linkInfo.Mode()
+			if err := os.Rename(fileName, backupFileName); err != nil {
+				return "", fmt.Errorf("can't rename log file: %s", err)
+			}
+		}
 	}
 
-	return filepath.Join(
-		dir,
-		fmt.Sprintf("%s-%s%s", filename[:len(filename)-len(ext)], t.Format(backupTimeFormat), ext),
-	)
+	// create a timestamped file to write current logs
+	activeFileName := uniqueBackupName(fileName, backupName(fileName, "", l.RotationOption), l.RotationOption)
+	f, err := os.OpenFile(activeFileName, os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_APPEND, fileMode)
+	if err != nil {
+		return backupFileName, fmt.Errorf("can't open new logfile: %s", err)
+	}
+
+	// Replacing the symlink atomically, so the readers always find a log file
+	if err := replaceSymlink(activeFileName, fileName); err != nil {
+		f.Close()
+		return backupFileName, fmt.Errorf("can't replace log file symlink: %s", err)
+	}
+
+	// Committing the symlink and the new log file entries to the disk
+	if err := l.syncDirs(fileName, activeFileName); err != nil {
+		f.Close()
+		return backupFileName, err
+	}
+
+	// Assigning the file pointer and file size to *Logger
+	if err := l.setFile(f); err != nil {
+		return backupFileName, err
+	}
+	l.size = 0
+	return backupFileName, l.writeHeader()
 }
 
-// rotate, rotates the currently opened log file
-func (l *Logger) rotate() error {
-	// Close the current log file
-	if err := l.close(); err != nil {
-		return err
+// openDailyFile opens the log file of the current day in the append mode.
+// The log file of the previous day, if any, is returned as the backup file.
+func (l *Logger) openDailyFile() (string, error) {
+	backupFileName := l.dailyFileName
+	activeFileName := backupName(l.Filename, "", l.RotationOption)
+	// A rotation within the same day reopens the same file
+	if activeFileName == backupFileName {
+		backupFileName = ""
 	}
 
-	// Open a new log file
-	if err := l.openNewFile(); err != nil {
-		return err
+	f, err := os.OpenFile(activeFileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return backupFileName, fmt.Errorf("can't open daily logfile: %s", err)
+	}
+	fileInfo, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return backupFileName, fmt.Errorf("failed to get the log file info-%v", err)
+	}
+
+	// Assigning the file pointer and file size to *Logger
+	if err := l.setFile(f); err != nil {
+		return backupFileName, err
+	}
+	l.size = fileInfo.Size()
+	l.dailyFileName = activeFileName
+	return backupFileName, l.writeHeader()
+}
+
+// setFile assigns the opened log file to the logger. If StreamCompression is
+// enabled, then the writes to the log file go through a gzip stream. A gzip
+// stream appended to an existing log file is a new gzip member of the file.
+// If BufferSize is set, then the writes are buffered in front of the stream.
+// If Preallocate is enabled, then the blocks of the log file are reserved.
+// If WriteTimeout is set, then the log file is written by a writer goroutine.
+func (l *Logger) setFile(f *os.File) error {
+	if l.preallocating() {
+		if err := preallocate(f, l.max()); err != nil {
+			f.Close()
+			return fmt.Errorf("can't preallocate log file: %s", err)
+		}
 	}
 
+	l.file = f
+	l.stream = nil
+	l.buffer = nil
+	l.deadline = nil
+	if l.RotationOption.WriteTimeout > 0 {
+		l.deadline = newDeadlineWriter(f, l.RotationOption.WriteTimeout)
+	}
+	if l.RotationOption.StreamCompression {
+		stream, err := getGzipWriter(l.sink(), l.RotationOption.CompressionLevel)
+		if err != nil {
+			l.closeDeadline()
+			l.file = nil
+			f.Close()
+			return fmt.Errorf("can't open log file stream: %s", err)
+		}
+		l.stream = stream
+	}
+	if l.RotationOption.BufferSize > 0 {
+		l.buffer = bufio.NewWriterSize(l.unbuffered(), l.RotationOption.BufferSize)
+	}
+	l.callback.Hooks.OnOpen(f.Name())
 	return nil
 }
 
-// close, closes the current log file
-func (l *Logger) close() error {
-	// If currently no file is opened
-	if l.file == nil {
-		return nil
+// preallocating returns true if the blocks of the log file are preallocated
+func (l *Logger) preallocating() bool {
+	return l.RotationOption.Preallocate && !l.RotationOption.DisableSizeRotation
+}
+
+// output returns the writer of the log file, which is the write buffer of
+// the log file, if BufferSize is set, or the compression stream of the log
+// file, if StreamCompression is enabled
+func (l *Logger) output() io.Writer {
+	if l.buffer != nil {
+		return l.buffer
 	}
+	return l.unbuffered()
+}
 
-	// close the file, assign nil to the file pointer
-	err := l.file.Close()
-	l.file = nil
-	return err
+// unbuffered returns the writer of the log file behind the write buffer
+func (l *Logger) unbuffered() io.Writer {
+	if l.stream != nil {
+		return l.stream
+	}
+	return l.sink()
 }
 
-// postRotation is used to trigger callback function,
-// compress the log files, if compression if enabled
-func postRotation(backupFileName string, compress bool, compressionLevel int) {
-	// If compression is enabled
-	if compress {
-		// Get a compressed file name
-		compressedFileName := fmt.Sprintf(
-			"%s%s.gz",
-			backupFileName[0:len(backupFileName)-len(filepath.Ext(backupFileName))],
-			filepath.Ext(backupFileName),
-		)
-		// Compress the log file
-		if err := compressLogFile(backupFileName, compressedFileName, compressionLevel); err != nil {
-			// Failed to compress the log file,
-			// passing the uncompressed log file path in the callback trigger channel
-			callbackExecutor <- backupFileName
-		} else {
-			// Pass the compressed file name in the callback trigger channel
-			callbackExecutor <- compressedFileName
+// sink returns the writer of the log file behind the compression stream,
+// which writes in the writer goroutine, if WriteTimeout is set
+func (l *Logger) sink() io.Writer {
+	if l.deadline != nil {
+		return l.deadline
+	}
+	return l.file
+}
+
+// closeDeadline stops the writer goroutine of the log file, if any
+func (l *Logger) closeDeadline() {
+	if l.deadline != nil {
+		l.deadline.close()
+		l.deadline = nil
+	}
+}
+
+// flush writes the buffered logs and the pending compressed data of the
+// log file, so the log file can be tailed, ex- with "zcat -f"
+func (l *Logger) flush() error {
+	if l.buffer != nil {
+		if err := l.buffer.Flush(); err != nil {
+			return err
 		}
-	} else {
-		// Pass the backup file name in the callback trigger channel
-		callbackExecutor <- backupFileName
 	}
+	if l.stream != nil {
+		return l.stream.Flush()
+	}
+	return nil
 }
 
-// compressLogFile compressed the requested log file
-func compressLogFile(sourceFile, destinationFile string, compressionLevel int) error {
-	file, err := os.Open(sourceFile)
-	if err != nil {
-		return fmt.Errorf("failed to open log file: %v", err)
+// writeHeader writes the output of the FileHeader at the top of the
+// freshly opened log file. The header is not written to a non-empty file.
+func (l *Logger) writeHeader() error {
+	// Resetting the statistics of the freshly opened log file
+	l.headerSize = 0
+	l.stats = FileStats{}
+	if l.RotationOption.FileHeader == nil || l.size > 0 {
+		return nil
 	}
-	defer file.Close()
 
-	fileInfo, err := os.Stat(sourceFile)
+	n, err := l.output().Write(l.RotationOption.FileHeader())
+	l.size += int64(n)
+	l.headerSize = int64(n)
 	if err != nil {
-		return fmt.Errorf("failed to stat log file: %v", err)
+		return fmt.Errorf("can't write log file header: %s", err)
 	}
+	return nil
+}
 
-	err = chown(destinationFile, fileInfo)
-	if err != nil {
-		return fmt.Errorf("failed to chown compressed log file: %v", err)
+// writeFooter appends the output of the FileFooter at the end of the log
+// file, which is about to be rotated. The footer is not written if the
+// daily log file is going to be reopened within the same day.
+func (l *Logger) writeFooter() error {
+	if l.RotationOption.FileFooter == nil || l.file == nil {
+		return nil
+	}
+	if l.RotationOption.DailyFile && backupName(l.Filename, "", l.RotationOption) == l.dailyFileName {
+		return nil
 	}
 
-	compressedFile, err := os.OpenFile(destinationFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fileInfo.Mode())
+	n, err := l.output().Write(l.RotationOption.FileFooter(l.stats))
+	l.size += int64(n)
 	if err != nil {
-		return fmt.Errorf("failed to open compressed log file: %v", err)
+		return fmt.Errorf("can't write log file footer: %s", err)
 	}
-	defer compressedFile.Close()
+	return nil
+}
 
-	// Using BestCompression method to compress the log files
-	gzWriter, err := gzip.NewWriterLevel(compressedFile, compressionLevel)
+// writeFile writes the data to the log file and updates
+// the size and the statistics of the log file
+func (l *Logger) writeFile(p []byte) (n int, err error) {
+	n, err = l.output().Write(p)
+	l.size += int64(n)
 	if err != nil {
-		return err
+		// The failed write may have been partially written,
+		// so the size is reconciled on the next write
+		l.lastSizeCheck = time.Time{}
+		// The write buffer keeps failing after an error, so
+		// it is reset to retry the log file on the next write
+		if l.buffer != nil {
+			l.buffer.Reset(l.unbuffered())
+		}
+	}
+	if n > 0 {
+		l.recordWrite(p[:n], l.RotationOption.now())
 	}
+	return n, err
+}
 
-	// If any error occurs, then remove the opened compressed file
-	defer func() {
-		if err != nil {
-			os.Remove(destinationFile)
-			err = fmt.Errorf("failed to compress log file: %v", err)
-		}
-	}()
+// replaceSymlink atomically creates or replaces the symlink pointing to
+// the target. The symlink points to the target relative to its directory.
+func replaceSymlink(target, link string) error {
+	relativeTarget, err := filepath.Rel(filepath.Dir(link), target)
+	if err != nil {
+		relativeTarget = target
+	}
 
-	if _, err := io.Copy(gzWriter, file); err != nil {
-		fmt.Println("Failed to compress the file", err)
+	tempLink := link + ".link"
+	_ = os.Remove(tempLink)
+	if err := os.Symlink(relativeTarget, tempLink); err != nil {
+		return err
+	}
+	if err := os.Rename(tempLink, link); err != nil {
+		_ = os.Remove(tempLink)
 		return err
 	}
+	return nil
+}
 
-	if err := gzWriter.Close(); err != nil {
-		fmt.Println("Failed to close the compress writer", err)
+// copyFile copies the source file to the destination file
+// with the mode and the ownership of the source file
+func copyFile(sourceFile, destinationFile string, fileInfo os.FileInfo) error {
+	source, err := os.Open(sourceFile)
+	if err != nil {
 		return err
 	}
+	defer source.Close()
 
-	if err := file.Close(); err != nil {
+	if err := chown(destinationFile, fileInfo); err != nil {
 		return err
 	}
 
-	// Removing the source file which is the uncompressed file
-	if err := os.Remove(sourceFile); err != nil {
+	destination, err := os.OpenFile(destinationFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fileInfo.Mode())
+	if err != nil {
 		return err
 	}
 
-	return nil
+	if _, err := copyFileData(destination, source); err != nil {
+		destination.Close()
+		os.Remove(destinationFile)
+		return err
+	}
+	return destination.Close()
 }
 
-func cleanUpOldLogs(file string, compress bool, period int) {
-	filename := filepath.Base(file)
-	// For both compressed and uncompressed files the prefix will be
-	// the base filename without extension
-	prefix := filename[0 : len(filename)-len(filepath.Ext(filename))]
+// now returns the current time of the clock of the logger
+func (o *Options) now() time.Time {
+	if o.clock != nil {
+		return o.clock()
+	}
+	return currentTime()
+}
 
-	// For uncompressed files the suffix will be the base file extension
-	suffix := filepath.Ext(file)
+// diskFree returns the percentage of the free space of the
+// filesystem of the dir, as probed by the logger
+func (o *Options) diskFree(dir string) (float64, error) {
+	if o.freeDiskPercent != nil {
+		return o.freeDiskPercent(dir)
+	}
+	return diskFreePercent(dir)
+}
 
-	if compress {
-		// For compressed files the suffix will be the extension of the compressed file
-		suffix = filepath.Ext(file) + ".gz"
+// location returns the timezone of the timestamps in the backup file names
+func (o *Options) location() *time.Location {
+	if o.Location != nil {
+		return o.Location
 	}
+	if o.LocalTime {
+		return time.Local
+	}
+	return time.UTC
+}
 
-	// get the list of all the files and folders in the log folder
-	files, err := ioutil.ReadDir(filepath.Dir(file))
+// backupName returns a backup name for the current file,
+// the tag, if any, is appended after the timestamp
+func backupName(name, tag string, options *Options) string {
+	dir := filepath.Dir(name)
+	// The timestamp is generated in the Location, if any, or in the system
+	// time if the LocalTime is true, otherwise in UTC time
+	t := options.now().In(options.location())
+
+	// The date partitioned backups are placed under the YYYY/MM/DD directories
+	if options.DatePartitionedBackups {
+		dir = filepath.Join(dir, t.Format("2006"), t.Format("01"), t.Format("02"))
+	}
+
+	return filepath.Join(dir, newBackupCodec(name, options).format(t, sanitizeTag(tag)))
+}
+
+// uniqueBackupName returns the backup name, if neither the backup file nor
+// its compressed file exists. Otherwise, a sequence suffix (-1, -2, ...) is
+// appended to the backup name, so the rapid rotations within the same
+// timestamp do not overwrite each other.
+func uniqueBackupName(file, name string, options *Options) string {
+	// The sequence suffix is placed between the stem and the extension of the
+	// log file, which may be empty, ex- app-2020-10-15T10-30-00.000-1.log
+	codec := newBackupCodec(file, options)
+	base := filepath.Base(name)
+	stem, suffix := codec.stem(base), codec.suffix(base)
+	uniqueName := name
+	for sequence := 1; ; sequence++ {
+		_, err := os.Stat(uniqueName)
+		_, compressedErr := os.Stat(uniqueName + options.compressor().Ext())
+		if os.IsNotExist(err) && os.IsNotExist(compressedErr) {
+			return uniqueName
+		}
+		uniqueName = filepath.Join(filepath.Dir(name), fmt.Sprintf("%s-%d%s", stem, sequence, suffix))
+	}
+}
+
+// sanitizeTag replaces the characters of the tag, which are
+// not safe to be used in a filename, with "-"
+func sanitizeTag(tag string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		}
+		return '-'
+	}, strings.TrimSpace(tag))
+}
+
+// rotate, rotates the currently opened log file for the reason. The
+// rotation vetoed by the Callback.BeforeRotate is deferred till the
+// next write exceeding the maximum file size.
+func (l *Logger) rotate(reason RotationReason) error {
+	if l.callback.BeforeRotate(l.Filename) != nil {
+		return nil
+	}
+	return l.rotateTagged("", reason)
+}
+
+// rotateTagged, rotates the currently opened log file for the reason,
+// the tag, if any, is embedded into the backup filename
+func (l *Logger) rotateTagged(tag string, reason RotationReason) error {
+	// Append the footer and close the current log file
+	if err := l.writeFooter(); err != nil {
+		return err
+	}
+	if err := l.closeRotated(); err != nil {
+		return err
+	}
+
+	// Open a new log file
+	if err := l.openNewFile(tag, reason); err != nil {
+		return err
+	}
+
+	l.lastRotation = l.RotationOption.now()
+	return nil
+}
+
+// rotateWithResult, rotates the currently opened log file without
+// triggering the post rotation thread and returns the backup filename
+func (l *Logger) rotateWithResult() (string, error) {
+	// Append the footer and close the current log file
+	if err := l.writeFooter(); err != nil {
+		return "", err
+	}
+	if err := l.closeRotated(); err != nil {
+		return "", err
+	}
+
+	// Open a new log file
+	backupFileName, err := l.backupAndOpenNewFile("")
 	if err != nil {
+		return backupFileName, err
+	}
+
+	l.lastRotation = l.RotationOption.now()
+	return backupFileName, nil
+}
+
+// writeSplit writes the data which is larger than the maximum file size
+// in chunks of the maximum file size, rotating the log file before every
+// chunk. The chunks are split on the newline boundaries when possible.
+func (l *Logger) writeSplit(p []byte) (n int, err error) {
+	maxFileSize := l.max()
+	for len(p) > 0 {
+		// Every chunk is written to a fresh log file
+		if l.size > l.headerSize {
+			if err := l.rotate(RotationSize); err != nil {
+				return n, err
+			}
+		}
+
+		// The header, if any, is accounted in the chunk size
+		chunkSize := maxFileSize - l.size
+		if chunkSize <= 0 {
+			chunkSize = maxFileSize
+		}
+
+		chunk := p
+		if int64(len(chunk)) > chunkSize {
+			chunk = p[:chunkSize]
+			if index := bytes.LastIndexByte(chunk, '\n'); index >= 0 {
+				chunk = chunk[:index+1]
+			}
+		}
+
+		written, err := l.writeFile(chunk)
+		n += written
+		if err != nil {
+			return n, err
+		}
+		p = p[written:]
+	}
+	return n, nil
+}
+
+// writeOversize writes the data which is larger than the maximum file size
+// in full to a fresh log file and then immediately rotates the log file
+func (l *Logger) writeOversize(p []byte) (n int, err error) {
+	if l.size > l.headerSize {
+		if err := l.rotate(RotationSize); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err = l.writeFile(p)
+	if err != nil {
+		return n, err
+	}
+	return n, l.rotate(RotationSize)
+}
+
+// jitter returns a random delay within the RotationJitter window
+func (l *Logger) jitter() time.Duration {
+	if l.RotationOption.RotationJitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(l.RotationOption.RotationJitter)))
+}
+
+// timedRotate rotates the log file on a period or a schedule trigger.
+// The rotation is skipped if the log file is smaller than the MinSize
+// or if the log file is idle and SkipIdleRotation is enabled.
+func (l *Logger) timedRotate() {
+	// The rotation vetoed by the Callback.BeforeRotate is deferred
+	// till the next period, the hook is called before locking the
+	// logger, so it can flush the buffered logs into the logger
+	if l.callback.BeforeRotate(l.Filename) != nil {
+		return
+	}
+
+	// The synchronous callbacks are called after unlocking the logger
+	defer l.runPendingRotations()
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// The closed logger is not rotated by the scheduler
+	if l.lifecycle == lifecycleClosed {
+		return
+	}
+	if l.currentSize() < l.RotationOption.MinSize {
+		return
+	}
+
+	// Checking for any write since the previous rotation
+	if l.RotationOption.SkipIdleRotation && !l.lastWrite.After(l.lastRotation) {
+		return
+	}
+	if err := l.rotateTagged("", RotationPeriod); err != nil {
+		l.reportError(err)
+	}
+}
+
+// currentSize returns the size of the log file. If the log file is not
+// opened yet, then the size of the existing log file is returned.
+func (l *Logger) currentSize() int64 {
+	if l.file != nil {
+		return l.size
+	}
+	fileName := l.Filename
+	if l.RotationOption.DailyFile {
+		fileName = l.dailyFileName
+	}
+	if fileInfo, err := os.Stat(fileName); err == nil {
+		return fileInfo.Size()
+	}
+	return 0
+}
+
+// markerModTime returns the modification time of the marker file,
+// zero time is returned if the marker file does not exist
+func markerModTime(marker string) time.Time {
+	if fileInfo, err := os.Stat(marker); err == nil {
+		return fileInfo.ModTime()
+	}
+	return time.Time{}
+}
+
+// syncDirs, commits the directories of the log file and its
+// backup to the disk, unless DisableRotationSync is set
+func (l *Logger) syncDirs(fileName, backupFileName string) error {
+	if l.RotationOption.DisableRotationSync {
+		return nil
+	}
+	dirs := []string{filepath.Dir(fileName)}
+	if backupFileName != "" && filepath.Dir(backupFileName) != dirs[0] {
+		dirs = append(dirs, filepath.Dir(backupFileName))
+	}
+	for _, dir := range dirs {
+		if err := syncDir(dir); err != nil {
+			return fmt.Errorf("can't sync log directory: %s", err)
+		}
+	}
+	return nil
+}
+
+// sync, commits the content of the current log file to the disk
+func (l *Logger) sync() error {
+	// If currently no file is opened
+	if l.file == nil {
+		return nil
+	}
+	if err := l.flush(); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+// checkDiskSpace rotates the log file and removes the oldest rotated log
+// files, if the free space of the filesystem drops below MinFreeDiskPercent.
+// The LowDiskSpace callback is triggered if the space can not be recovered.
+func (l *Logger) checkDiskSpace() {
+	dir := filepath.Dir(l.Filename)
+	free, err := l.RotationOption.diskFree(dir)
+	if err != nil || free >= l.RotationOption.MinFreeDiskPercent {
 		return
 	}
 
-	for _, f := range files {
-		// It the object is an directory, continue
-		if f.IsDir() {
+	if err := l.Rotate(); err != nil {
+		l.reportError(err)
+	}
+
+	// The retention is enforced by a single thread at a time, so a
+	// rotated log file is not archived twice by the concurrent scans
+	l.retentionMutex.Lock()
+	options := l.RotationOption
+	codec := newBackupCodec(l.Filename, options)
+	backups, _ := backupFiles(l.Filename, options)
+	var prunedBackups []PrunedBackup
+	for _, f := range backups {
+		if free >= options.MinFreeDiskPercent {
+			break
+		}
+		// The rotated log files pinned or protected by the RetentionFilter are never removed
+		if l.isPinned(f.Name()) ||
+			options.RetentionFilter != nil && !options.RetentionFilter(backupInfo(codec, f)) {
+			continue
+		}
+		age := options.now().Sub(backupTime(codec, f))
+		if err := l.pruneBackup(f); err != nil {
+			l.reportError(err)
 			continue
 		}
+		prunedBackups = append(prunedBackups, PrunedBackup{File: f.path, Size: f.Size(), Age: age, Reason: RetentionDiskSpace, backup: f})
+		l.callback.Hooks.OnDelete(f.path)
+		if free, err = options.diskFree(dir); err != nil {
+			break
+		}
+	}
+	l.retentionMutex.Unlock()
+	if len(prunedBackups) > 0 {
+		l.callback.Pruned(prunedBackups)
+	}
+	if err != nil {
+		return
+	}
 
-		// a qualified rotated file will have the defined prefix and suffix
-		if strings.HasPrefix(f.Name(), prefix) && strings.HasSuffix(f.Name(), suffix) && f.Name() != filename {
-			// Parsing the time from the file name
-			timeStamp, _ := time.Parse(backupTimeFormat, f.Name()[len(prefix)+1:len(f.Name())-len(suffix)])
+	if free < l.RotationOption.MinFreeDiskPercent {
+		l.callback.LowDiskSpace(free)
+	}
+}
 
-			// Checking the age of the file, if the age is greater than the provided retention period,
-			// then remove the file
-			if currentTime().Sub(timeStamp.Add(-time.Second*19800)) > time.Duration(period)*time.Hour*24 {
-				_ = os.Remove(filepath.Join(filepath.Dir(file), f.Name()))
+// close, closes the current log file
+func (l *Logger) close() error {
+	return l.closeFile(false)
+}
+
+// closeRotated, closes the current log file before it is rotated. The
+// content of the file is committed to the disk, unless DisableRotationSync
+// is set, so the tail of the file is not lost on a power failure.
+func (l *Logger) closeRotated() error {
+	return l.closeFile(!l.RotationOption.DisableRotationSync)
+}
+
+// closeFile, closes the current log file after committing
+// its content to the disk, if sync is true
+func (l *Logger) closeFile(sync bool) error {
+	// If currently no file is opened
+	if l.file == nil {
+		return nil
+	}
+
+	// Writing the buffered logs and the end of the compression stream, if any
+	var err error
+	if l.buffer != nil {
+		err = l.buffer.Flush()
+		l.buffer = nil
+	}
+	if l.stream != nil {
+		if closeErr := l.stream.Close(); err == nil {
+			err = closeErr
+		}
+		l.stream = nil
+	}
+	// Releasing the preallocated blocks after the end of the log file
+	if l.preallocating() && err == nil {
+		if fileInfo, statErr := l.file.Stat(); statErr == nil {
+			err = l.file.Truncate(fileInfo.Size())
+		}
+	}
+	if sync && err == nil {
+		err = l.file.Sync()
+	}
+	l.closeDeadline()
+
+	// close the file, assign nil to the file pointer
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	l.file = nil
+	return err
+}
+
+// postRotation is used to trigger callback function,
+// compress the log files in the compression pool, if compression
+// if enabled and clean up the log files, if a MaxTotalSize is configured
+func (l *Logger) postRotation(event RotationEvent) {
+	// The backups are compressed along with the bundle, and
+	// the callback is triggered with the name of the bundle
+	if l.RotationOption.bundling() {
+		l.submitTracked(l.bundleBackups)
+		return
+	}
+
+	// The backups are compressed by the sweep after the CompressAfter,
+	// and the streamed log files are compressed while being written
+	if !l.RotationOption.Compress || l.RotationOption.CompressAfter > 0 || l.RotationOption.StreamCompression {
+		l.goTracked(func() { l.notifyRotation(event) })
+		return
+	}
+
+	// The callback is not triggered in the compression pool,
+	// so a slow callback does not hold up the compressions
+	l.submitTracked(func() {
+		event := l.compressBackup(event)
+		l.goTracked(func() { l.notifyRotation(event) })
+	})
+}
+
+// compressBackup compresses the backup file of the rotation event, if
+// compression is enabled and not delayed by the CompressAfter, and returns
+// the event with the compressed filename. If the compression fails, the
+// event is returned without the compressed filename.
+func (l *Logger) compressBackup(event RotationEvent) RotationEvent {
+	options := l.RotationOption
+	if !options.Compress || options.CompressAfter > 0 || options.bundling() || options.StreamCompression {
+		return event
+	}
+
+	// The sequence named backups are not shifted while being compressed
+	if options.BackupNaming == SequenceNaming {
+		l.shiftMutex.Lock()
+		defer l.shiftMutex.Unlock()
+	}
+
+	// Get a compressed file name
+	compressedFileName := compressedFileName(l.Filename, event.BackupFile, options)
+	// Compress the log file
+	start := l.RotationOption.now()
+	if err := l.compressFile(event.BackupFile, compressedFileName, options.compressor()); err != nil {
+		// Failed to compress the log file
+		return event
+	}
+	event.CompressedFile = compressedFileName
+	event.CompressionDuration = l.RotationOption.now().Sub(start)
+	return event
+}
+
+// compressAgedBackups compresses the uncompressed backups, which are older
+// than the CompressAfter. The backups are compressed one by one in the
+// compression pool. The callback is not triggered for these backups.
+func (l *Logger) compressAgedBackups() {
+	backups, err := backupFiles(l.Filename, l.RotationOption)
+	if err != nil {
+		return
+	}
+	l.compressBackups(backups)
+}
+
+// compressBackups compresses the listed uncompressed backups, which are older
+// than the CompressAfter. The backups left behind by a previous run are listed
+// before the logger starts rotating, so the backups being compressed after a
+// rotation are not compressed twice.
+func (l *Logger) compressBackups(backups []backupFile) {
+	options := l.RotationOption
+	// The bundled backups are compressed along with the bundle
+	if options.bundling() {
+		return
+	}
+
+	compressor := options.compressor()
+	compressedAny := false
+	for _, f := range backups {
+		if newBackupCodec(l.Filename, options).compressed(f.Name()) || options.now().Sub(f.ModTime()) < options.CompressAfter {
+			continue
+		}
+
+		backupFileName := f.path
+		done := make(chan struct{})
+		submitCompression(func() {
+			defer close(done)
+
+			// The sequence named backups are not shifted while being compressed
+			if options.BackupNaming == SequenceNaming {
+				l.shiftMutex.Lock()
+				defer l.shiftMutex.Unlock()
+			}
+
+			// The backup may have been shifted or removed since it was listed
+			fileInfo, err := os.Stat(backupFileName)
+			if err != nil || options.now().Sub(fileInfo.ModTime()) < options.CompressAfter {
+				return
 			}
+			compressedFileName := compressedFileName(l.Filename, backupFileName, options)
+			if l.compressFile(backupFileName, compressedFileName, compressor) == nil && options.Checksum {
+				// The checksum of the uncompressed backup is replaced
+				// with the checksum of the compressed backup
+				_ = os.Remove(backupFileName + checksumExtension)
+				if _, err := writeChecksum(compressedFileName); err != nil {
+					l.reportError(err)
+				}
+			}
+		})
+		<-done
+		compressedAny = true
+	}
+
+	if compressedAny {
+		l.saveState()
+	}
+}
+
+// notifyRotation points the latest backup symlink to the rotated file,
+// passes the rotated filename to the callback daemon thread,
+// cleans up the log files, if a MaxTotalSize is configured and updates the
+// state file, if MaintainState is enabled
+func (l *Logger) notifyRotation(event RotationEvent) {
+	rotatedFileName := event.rotatedFile()
+
+	// Writing the checksum file of the rotated file before any callback,
+	// so the uploaders find the checksum file along with the rotated file
+	if l.RotationOption.Checksum {
+		if digest, err := writeChecksum(rotatedFileName); err == nil {
+			event.Checksum = digest
+			l.callback.Checksum(rotatedFileName, digest)
+		} else {
+			l.reportError(err)
+		}
+	}
+
+	// Pointing the latest backup symlink to the rotated file
+	if link := l.RotationOption.LatestBackupLink; link != "" {
+		if !filepath.IsAbs(link) {
+			link = filepath.Join(filepath.Dir(l.Filename), link)
+		}
+		if err := replaceSymlink(rotatedFileName, link); err != nil {
+			l.reportError(fmt.Errorf("failed to replace latest backup link: %v", err))
+		}
+	}
+
+	// Pass the rotated file name in the callback queue, or call
+	// the callbacks directly, if the callbacks are synchronous
+	l.updatePendingCallbacks(rotatedFileName, true)
+	if l.RotationOption.SynchronousCallbacks {
+		l.executeCallbacks(rotatedFileName)
+		l.deliver(rotatedFileName)
+	} else {
+		l.queueCallback(rotatedFileName)
+		l.goTracked(func() { l.deliver(rotatedFileName) })
+	}
+	for _, callback := range l.registeredCallbacks() {
+		callback(rotatedFileName)
+	}
+	l.callback.Rotated(event)
+	l.callback.Hooks.OnRotate(event)
+
+	// A burst of logs can fill up the disk before the retention ticker
+	// gets triggered, so the retention is enforced after every rotation,
+	// once the rotated file is compressed
+	if l.RotationOption.retention() > 0 || l.RotationOption.MaxTotalSize > 0 {
+		l.cleanUpOldLogs()
+	}
+
+	l.saveState()
+}
+
+// registeredCallbacks returns the callbacks registered by the OnRotate
+func (l *Logger) registeredCallbacks() []func(string) {
+	l.callbackMutex.Lock()
+	defer l.callbackMutex.Unlock()
+	return append([]func(string){}, l.rotateCallbacks...)
+}
+
+// reportError passes the failure of a background operation, which
+// can not be returned to the caller, to the Callback.OnError and
+// the Hooks.OnError. The failure is reported asynchronously, as it
+// may occur with the lock of the logger held, so the callback can
+// write the failure to the logger itself.
+func (l *Logger) reportError(err error) {
+	go func() {
+		l.callback.OnError(err)
+		l.callback.Hooks.OnError(err)
+	}()
+}
+
+// compressFile compresses the log file using the compressor and passes
+// the statistics of the compression to the Callback.Compressed
+func (l *Logger) compressFile(sourceFile, destinationFile string, compressor Compressor) error {
+	fileInfo, err := os.Stat(sourceFile)
+	if err != nil {
+		err = fmt.Errorf("failed to stat log file: %v", err)
+		l.reportError(err)
+		return err
+	}
+
+	l.callback.Hooks.OnCompressStart(sourceFile)
+	start := l.RotationOption.now()
+	if err := compressLogFile(sourceFile, destinationFile, compressor); err != nil {
+		l.reportError(err)
+		return err
+	}
+	stats := CompressionStats{
+		File:           sourceFile,
+		CompressedFile: destinationFile,
+		OriginalSize:   fileInfo.Size(),
+		Duration:       l.RotationOption.now().Sub(start),
+	}
+	if compressedFileInfo, err := os.Stat(destinationFile); err == nil {
+		stats.CompressedSize = compressedFileInfo.Size()
+	}
+	if stats.CompressedSize > 0 {
+		stats.Ratio = float64(stats.OriginalSize) / float64(stats.CompressedSize)
+	}
+
+	// The callback is not triggered in the compression pool,
+	// so a slow callback does not hold up the compressions
+	if l.RotationOption.SynchronousCallbacks {
+		l.callback.Compressed(stats)
+	} else {
+		l.goTracked(func() { l.callback.Compressed(stats) })
+	}
+	l.callback.Hooks.OnCompressDone(stats)
+	return nil
+}
+
+// compressLogFile compresses the requested log file using the compressor
+// and removes the source file, which is the uncompressed file
+func compressLogFile(sourceFile, destinationFile string, compressor Compressor) error {
+	fileInfo, err := os.Stat(sourceFile)
+	if err != nil {
+		return fmt.Errorf("failed to stat log file: %v", err)
+	}
+
+	// The compressed log file may be placed under a partition of the CompressDir
+	if err := os.MkdirAll(filepath.Dir(destinationFile), 0755); err != nil {
+		return fmt.Errorf("failed to create compressed log directory: %v", err)
+	}
+
+	// The log file is compressed into a temporary file, which is renamed
+	// on success, so a crash never leaves behind a truncated compressed file
+	tempFileName := destinationFile + tempFileExtension
+	err = chown(tempFileName, fileInfo)
+	if err != nil {
+		return fmt.Errorf("failed to chown compressed log file: %v", err)
+	}
+
+	// If any error occurs, then remove the temporary file
+	if err := compressor.Compress(sourceFile, tempFileName); err != nil {
+		os.Remove(tempFileName)
+		return fmt.Errorf("failed to compress log file: %v", err)
+	}
+	if err := os.Rename(tempFileName, destinationFile); err != nil {
+		os.Remove(tempFileName)
+		return fmt.Errorf("failed to rename compressed log file: %v", err)
+	}
+
+	// Removing the source file which is the uncompressed file
+	if err := os.Remove(sourceFile); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// writeChecksum writes the SHA-256 digest of the file to a checksum file,
+// ex- app.log.gz.sha256, in the format of the sha256sum utility, so the
+// file can be verified with "sha256sum -c". It returns the hex digest.
+func writeChecksum(name string) (string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to open log file: %v", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := copyFileData(hash, file); err != nil {
+		return "", fmt.Errorf("failed to read log file: %v", err)
+	}
+	digest := hex.EncodeToString(hash.Sum(nil))
+
+	// The checksum file is written atomically, so a reader
+	// never finds a truncated checksum file
+	checksum := fmt.Sprintf("%s  %s\n", digest, filepath.Base(name))
+	tempFileName := name + checksumExtension + tempFileExtension
+	if err := ioutil.WriteFile(tempFileName, []byte(checksum), 0644); err != nil {
+		return "", fmt.Errorf("failed to write checksum file: %v", err)
+	}
+	if err := os.Rename(tempFileName, name+checksumExtension); err != nil {
+		os.Remove(tempFileName)
+		return "", fmt.Errorf("failed to rename checksum file: %v", err)
+	}
+	return digest, nil
+}
+
+// removeTempFiles removes the temporary compressed files and bundles, which
+// are left behind by a crash in the middle of a compression or a bundling
+func removeTempFiles(file string, options *Options) {
+	prefix := newBackupCodec(file, options).prefix
+	patterns := []string{prefix + "*" + options.compressor().Ext() + tempFileExtension}
+	if options.bundling() {
+		// The temporary bundles are also removed
+		patterns = append(patterns, prefix+"*"+bundleExtension+tempFileExtension)
+	}
+	for _, dir := range backupDirs(file, options) {
+		for _, pattern := range patterns {
+			tempFiles, _ := filepath.Glob(filepath.Join(dir, pattern))
+			for _, tempFile := range tempFiles {
+				_ = os.Remove(tempFile)
+			}
+		}
+	}
+}
+
+// backupFile represents a rotated log file
+type backupFile struct {
+	// path is the path of the rotated log file
+	path string
+	os.FileInfo
+}
+
+// backupDirs returns the directories holding the backups of the log file
+func backupDirs(file string, options *Options) []string {
+	// The rotated backups are placed under the BackupDir, and the
+	// compressed backups are placed under the CompressDir
+	roots := []string{filepath.Dir(file), options.BackupDir, options.CompressDir}
+
+	var dirs []string
+	visited := make(map[string]bool)
+	for _, root := range roots {
+		if root == "" || visited[root] {
+			continue
+		}
+		visited[root] = true
+		dirs = append(dirs, root)
+		if options.DatePartitionedBackups {
+			// The date partitioned backups are placed under the YYYY/MM/DD directories
+			partitions, _ := filepath.Glob(filepath.Join(root, "[0-9][0-9][0-9][0-9]", "[0-9][0-9]", "[0-9][0-9]"))
+			dirs = append(dirs, partitions...)
+		}
+	}
+	return dirs
+}
+
+// backupDir returns the directory of the rotated log files of the log file
+func (o *Options) backupDir(file string) string {
+	if o.BackupDir != "" {
+		return o.BackupDir
+	}
+	return filepath.Dir(file)
+}
+
+// backupRoot returns the directory, which holds the date partition of the
+// backup file, ex- the CompressDir for the compressed backup files
+func backupRoot(file, backupFileName string, options *Options) string {
+	for _, dir := range []string{options.CompressDir, options.BackupDir} {
+		if dir != "" && strings.HasPrefix(backupFileName, dir+string(filepath.Separator)) {
+			return dir
+		}
+	}
+	return filepath.Dir(file)
+}
+
+// compressedFileName returns the name of the compressed backup file. If a
+// CompressDir is configured, then the compressed backup file is placed under
+// the CompressDir, retaining the date partition of the backup file, if any.
+func compressedFileName(file, backupFileName string, options *Options) string {
+	compressedFileName := backupFileName + options.compressor().Ext()
+	if options.CompressDir == "" || strings.HasPrefix(compressedFileName, options.CompressDir+string(filepath.Separator)) {
+		return compressedFileName
+	}
+	relativeName, err := filepath.Rel(backupRoot(file, backupFileName, options), compressedFileName)
+	if err != nil {
+		relativeName = filepath.Base(compressedFileName)
+	}
+	return filepath.Join(options.CompressDir, relativeName)
+}
+
+// backupFiles returns the rotated log files of the log file sorted by age,
+// so the oldest backup will be the first element
+func backupFiles(file string, options *Options) ([]backupFile, error) {
+	if options.BackupNaming == SequenceNaming {
+		return sequenceBackupFiles(file)
+	}
+
+	filename := filepath.Base(file)
+	codec := newBackupCodec(file, options)
+
+	// get the list of all the files and folders in the log folder
+	dirs := backupDirs(file, options)
+
+	// The active timestamped or daily log file is not a backup
+	active := filename
+	if target, err := filepath.EvalSymlinks(file); err == nil {
+		active = filepath.Base(target)
+	}
+	if options.DailyFile {
+		active = filepath.Base(backupName(file, "", options))
+	}
+
+	var backups []backupFile
+	var parsed []parsedBackup
+	for _, dir := range dirs {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range files {
+			// It the object is an directory, continue
+			if f.IsDir() {
+				continue
+			}
+
+			// a qualified rotated file will have the prefix and a suffix
+			// followed by the timestamp. The files of the other applications
+			// sharing the prefix, ex- app-worker.log, are not backups.
+			if f.Name() == filename || f.Name() == active {
+				continue
+			}
+			if p, err := codec.parse(f.Name()); err == nil {
+				backups = append(backups, backupFile{path: filepath.Join(dir, f.Name()), FileInfo: f})
+				parsed = append(parsed, p)
+			}
+		}
+	}
+
+	// The backups are sorted by the parsed rotation time and then by the
+	// collision sequence, as neither the timestamps of every layout nor the
+	// sequence suffixes (app-T.log, app-T-1.log, app-T-10.log) sort as text
+	sort.Sort(backupsByTime{backups: backups, parsed: parsed})
+	return backups, nil
+}
+
+// backupsByTime sorts the rotated log files by the parsed rotation time,
+// the collision sequence and the name, so the oldest backup is the first
+type backupsByTime struct {
+	backups []backupFile
+	parsed  []parsedBackup
+}
+
+func (b backupsByTime) Len() int { return len(b.backups) }
+
+func (b backupsByTime) Swap(i, j int) {
+	b.backups[i], b.backups[j] = b.backups[j], b.backups[i]
+	b.parsed[i], b.parsed[j] = b.parsed[j], b.parsed[i]
+}
+
+func (b backupsByTime) Less(i, j int) bool {
+	if !b.parsed[i].time.Equal(b.parsed[j].time) {
+		return b.parsed[i].time.Before(b.parsed[j].time)
+	}
+	if a, c := b.parsed[i].sequence(), b.parsed[j].sequence(); a != c {
+		return a < c
+	}
+	return b.backups[i].Name() < b.backups[j].Name()
+}
+
+// removeBackup removes the rotated log file. If the rotated log file is in
+// a date partition directory, then the emptied partition directories are
+// also removed. The checksum file of the rotated log file is also removed.
+func removeBackup(file string, backup backupFile, options *Options) error {
+	if err := os.Remove(backup.path); err != nil {
+		return err
+	}
+	// Removing the checksum file of the rotated log file, if any
+	_ = os.Remove(backup.path + checksumExtension)
+
+	removeEmptyPartitions(file, backup.path, options)
+	return nil
+}
+
+// removeEmptyPartitions removes the date partition directories of the
+// removed rotated log file, if they are emptied
+func removeEmptyPartitions(file, backupFileName string, options *Options) {
+	root := backupRoot(file, backupFileName, options)
+
+	// os.Remove does not remove the non empty directories
+	for dir := filepath.Dir(backupFileName); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
 		}
 	}
 }
@@ -0,0 +1,228 @@
+package eidos
+
+import (
+	"encoding/binary"
+	"io"
+	"math/bits"
+)
+
+const (
+	// lz4Magic is the magic number of the lz4 frame format
+	lz4Magic = 0x184D2204
+	// lz4BlockSize is the maximum size of the uncompressed data in a block, which is 4 mb
+	lz4BlockSize = 4 << 20
+	// lz4MinMatch is the minimum length of a match
+	lz4MinMatch = 4
+	// lz4HashLog is the number of bits of the hash of a 4 byte sequence, the
+	// hash table of 16 kb fits into the L1 cache as of the reference lz4
+	lz4HashLog = 12
+	// lz4MatchLimit is the minimum distance between the start of the last
+	// match and the end of the block, lz4LastLiterals is the minimum number
+	// of literals at the end of the block
+	lz4MatchLimit   = 12
+	lz4LastLiterals = 5
+	// lz4SkipTrigger is the log2 of the number of the failed match searches
+	// after which the search advances by one more byte per search
+	lz4SkipTrigger = 6
+)
+
+// lz4Writer compresses the data in the lz4 frame format. The blocks
+// are compressed independently, and there is no content checksum.
+type lz4Writer struct {
+	w             io.Writer
+	buffer        []byte
+	compressed    []byte
+	headerWritten bool
+}
+
+// newLZ4Writer returns a lz4Writer writing the frame to w
+func newLZ4Writer(w io.Writer) *lz4Writer {
+	return &lz4Writer{w: w}
+}
+
+// Write buffers the data and writes the complete blocks
+func (z *lz4Writer) Write(p []byte) (int, error) {
+	z.buffer = append(z.buffer, p...)
+	for len(z.buffer) >= lz4BlockSize {
+		if err := z.writeBlock(z.buffer[:lz4BlockSize]); err != nil {
+			return 0, err
+		}
+		z.buffer = z.buffer[lz4BlockSize:]
+	}
+	return len(p), nil
+}
+
+// Close writes the remaining data and the end mark of the frame
+func (z *lz4Writer) Close() error {
+	if err := z.writeHeader(); err != nil {
+		return err
+	}
+	if len(z.buffer) > 0 {
+		if err := z.writeBlock(z.buffer); err != nil {
+			return err
+		}
+		z.buffer = nil
+	}
+	_, err := z.w.Write([]byte{0, 0, 0, 0})
+	return err
+}
+
+// writeHeader writes the frame header, if it has not been written yet
+func (z *lz4Writer) writeHeader() error {
+	if z.headerWritten {
+		return nil
+	}
+	z.headerWritten = true
+
+	// Version 01, independent blocks and 4 mb maximum block size
+	descriptor := []byte{0x60, 0x70}
+	header := make([]byte, 4, 7)
+	binary.LittleEndian.PutUint32(header, lz4Magic)
+	header = append(header, descriptor...)
+	header = append(header, byte(xxh32(descriptor)>>8))
+	_, err := z.w.Write(header)
+	return err
+}
+
+// writeBlock compresses and writes a block. If the data can not be
+// compressed, then the block is stored uncompressed.
+func (z *lz4Writer) writeBlock(block []byte) error {
+	if err := z.writeHeader(); err != nil {
+		return err
+	}
+
+	z.compressed = lz4CompressBlock(z.compressed[:0], block)
+	compressed := z.compressed
+	size := uint32(len(compressed))
+	if len(compressed) >= len(block) {
+		// The highest bit of the block size marks an uncompressed block
+		compressed = block
+		size = uint32(len(block)) | 1<<31
+	}
+
+	header := make([]byte, 4)
+	binary.LittleEndian.PutUint32(header, size)
+	if _, err := z.w.Write(header); err != nil {
+		return err
+	}
+	_, err := z.w.Write(compressed)
+	return err
+}
+
+// lz4CompressBlock appends the block compressed in the lz4 block format to
+// dst using a greedy match search over a hash table of 4 byte sequences
+func lz4CompressBlock(dst, src []byte) []byte {
+	// table holds the position+1 of the last occurrence of a hash
+	pooledTable := lz4Tables.Get().(*[]int32)
+	defer lz4Tables.Put(pooledTable)
+	table := *pooledTable
+	for i := range table {
+		table[i] = 0
+	}
+
+	anchor, i, misses := 0, 0, 0
+	for i+lz4MatchLimit <= len(src) {
+		sequence := binary.LittleEndian.Uint32(src[i:])
+		hash := (sequence * 2654435761) >> (32 - lz4HashLog)
+		reference := int(table[hash]) - 1
+		table[hash] = int32(i + 1)
+
+		if reference < 0 || i-reference > 65535 || binary.LittleEndian.Uint32(src[reference:]) != sequence {
+			// The search skips faster through the incompressible data
+			i += 1 + misses>>lz4SkipTrigger
+			misses++
+			continue
+		}
+
+		// Extending the match, the last literals are never matched
+		end := extendMatch(src, reference+lz4MinMatch, i+lz4MinMatch, len(src)-lz4LastLiterals)
+		dst = lz4AppendSequence(dst, src[anchor:i], i-reference, end-i)
+		i, anchor, misses = end, end, 0
+	}
+
+	// The last sequence holds only the literals
+	return lz4AppendSequence(dst, src[anchor:], 0, 0)
+}
+
+// lz4AppendSequence appends a sequence of the literals followed by a match.
+// A matchLength of 0 represents the last sequence, which has no match.
+func lz4AppendSequence(dst, literals []byte, offset, matchLength int) []byte {
+	literalLength := len(literals)
+	token := byte(min(literalLength, 15) << 4)
+	if matchLength > 0 {
+		token |= byte(min(matchLength-lz4MinMatch, 15))
+	}
+
+	dst = append(dst, token)
+	dst = lz4AppendLength(dst, literalLength)
+	dst = append(dst, literals...)
+	if matchLength > 0 {
+		dst = append(dst, byte(offset), byte(offset>>8))
+		dst = lz4AppendLength(dst, matchLength-lz4MinMatch)
+	}
+	return dst
+}
+
+// lz4AppendLength appends the remaining length of a length which
+// does not fit into the 4 bits of the token
+func lz4AppendLength(dst []byte, length int) []byte {
+	if length < 15 {
+		return dst
+	}
+	for length -= 15; length >= 255; length -= 255 {
+		dst = append(dst, 255)
+	}
+	return append(dst, byte(length))
+}
+
+// extendMatch returns the end of the match of src[i:] with src[reference:],
+// which is not extended beyond the limit. The bytes are compared 8 at a time.
+func extendMatch(src []byte, reference, i, limit int) int {
+	for i+8 <= limit {
+		if diff := binary.LittleEndian.Uint64(src[i:]) ^ binary.LittleEndian.Uint64(src[reference:]); diff != 0 {
+			return i + bits.TrailingZeros64(diff)>>3
+		}
+		i, reference = i+8, reference+8
+	}
+	for i < limit && src[i] == src[reference] {
+		i, reference = i+1, reference+1
+	}
+	return i
+}
+
+// min returns the minimum of the two integers
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// xxh32 returns the xxHash32 digest of the data with a seed of 0.
+// It is used for the lz4 frame header checksum, so only the short
+// inputs (less than 16 bytes) are supported.
+func xxh32(data []byte) uint32 {
+	const (
+		prime1 uint32 = 2654435761
+		prime2 uint32 = 2246822519
+		prime3 uint32 = 3266489917
+		prime4 uint32 = 668265263
+		prime5 uint32 = 374761393
+	)
+	rotl := func(x uint32, r uint) uint32 { return x<<r | x>>(32-r) }
+
+	h := prime5 + uint32(len(data))
+	for ; len(data) >= 4; data = data[4:] {
+		h = rotl(h+binary.LittleEndian.Uint32(data)*prime3, 17) * prime4
+	}
+	for _, b := range data {
+		h = rotl(h+uint32(b)*prime5, 11) * prime1
+	}
+
+	h ^= h >> 15
+	h *= prime2
+	h ^= h >> 13
+	h *= prime3
+	h ^= h >> 16
+	return h
+}
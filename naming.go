@@ -0,0 +1,104 @@
+package eidos
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// BackupNaming represents the naming convention of the rotated log files
+type BackupNaming int
+
+const (
+	// TimestampNaming names the rotated log files with the rotation
+	// timestamp, ex- app-2020-10-15T10-30-00.000.log
+	TimestampNaming BackupNaming = iota
+	// SequenceNaming names the rotated log files with a sequence number
+	// like logrotate, ex- app.log.1, app.log.2. The rotated log files are
+	// shifted on every rotation, so app.log.1 is always the newest one.
+	SequenceNaming
+)
+
+// String returns the name of the backup naming convention
+func (n BackupNaming) String() string {
+	switch n {
+	case TimestampNaming:
+		return "timestamp"
+	case SequenceNaming:
+		return "sequence"
+	}
+	return fmt.Sprintf("BackupNaming(%d)", int(n))
+}
+
+// sequenceNumber returns the sequence number of a sequence named
+// backup of the log file and the compression extension, if any,
+// ex- 2 and ".gz" for app.log.2.gz
+func sequenceNumber(filename, name string) (int, string, bool) {
+	if !strings.HasPrefix(name, filename+".") {
+		return 0, "", false
+	}
+	suffix := name[len(filename)+1:]
+	extension := ""
+	if index := strings.Index(suffix, "."); index >= 0 {
+		suffix, extension = suffix[:index], suffix[index:]
+	}
+	number, err := strconv.Atoi(suffix)
+	if err != nil || number <= 0 {
+		return 0, "", false
+	}
+	return number, extension, true
+}
+
+// sequenceBackupFiles returns the sequence named backups of the
+// log file sorted by the sequence number in descending order, so
+// the oldest backup will be the first element
+func sequenceBackupFiles(file string) ([]backupFile, error) {
+	filename := filepath.Base(file)
+	files, err := ioutil.ReadDir(filepath.Dir(file))
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []backupFile
+	for _, f := range files {
+		// The temporary compressed files and the checksum files are not backups
+		if _, extension, ok := sequenceNumber(filename, f.Name()); ok && !f.IsDir() &&
+			!strings.HasSuffix(extension, tempFileExtension) && !strings.HasSuffix(extension, checksumExtension) {
+			backups = append(backups, backupFile{path: filepath.Join(filepath.Dir(file), f.Name()), FileInfo: f})
+		}
+	}
+	sort.SliceStable(backups, func(i, j int) bool {
+		a, _, _ := sequenceNumber(filename, backups[i].Name())
+		b, _, _ := sequenceNumber(filename, backups[j].Name())
+		return a > b
+	})
+	return backups, nil
+}
+
+// shiftBackups shifts the sequence named backups of the log file by one,
+// ex- app.log.2 to app.log.3 and app.log.1 to app.log.2, and returns the
+// backup name for the log file, which is app.log.1
+func shiftBackups(file string) (string, error) {
+	backups, err := sequenceBackupFiles(file)
+	if err != nil {
+		return "", err
+	}
+
+	filename := filepath.Base(file)
+	dir := filepath.Dir(file)
+	for _, f := range backups {
+		// Retaining the compression extension, if any
+		number, extension, _ := sequenceNumber(filename, f.Name())
+		shiftedName := fmt.Sprintf("%s.%d%s", filename, number+1, extension)
+		if err := os.Rename(f.path, filepath.Join(dir, shiftedName)); err != nil {
+			return "", fmt.Errorf("can't shift backup file: %s", err)
+		}
+		// The checksum file, if any, is shifted along with the backup
+		_ = os.Rename(f.path+checksumExtension, filepath.Join(dir, shiftedName+checksumExtension))
+	}
+	return file + ".1", nil
+}
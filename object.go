@@ -1,6 +1,9 @@
 package eidos
 
 import (
+	"bufio"
+	"context"
+	"io"
 	"os"
 	"sync"
 	"time"
@@ -17,11 +20,48 @@ type Logger struct {
 	// RotationOption specifies set of parameters for the rotating operation.
 	RotationOption *Options `json:"rotation_option"`
 
+	callback        *Callback
 	size            int64
+	lastWrite       time.Time
+	lastRotation    time.Time
+	lastFileCheck   time.Time
+	lastDirCheck    time.Time
+	lastSizeCheck   time.Time
 	file            *os.File
-	rotationTicker  *time.Ticker
-	retentionTicker *time.Ticker
-	mutex           sync.Mutex
+	stream          *pooledGzipWriter
+	buffer          *bufio.Writer
+	deadline        *deadlineWriter
+	dailyFileName   string
+	headerSize      int64
+	stats           FileStats
+	mutex           sync.RWMutex
+	statsMutex      sync.Mutex
+	stateMutex      sync.Mutex
+	shiftMutex      sync.Mutex
+	bundleMutex     sync.Mutex
+	pinned          map[string]bool
+	pinMutex        sync.Mutex
+	retentionMutex  sync.Mutex
+	rotateCallbacks []func(string)
+	callbackMutex   sync.Mutex
+	callbackQueue   chan string
+	callbackDrops   uint64
+	limiter         *rateLimiter
+	dedup           *deduplicator
+	partial         *partialLine
+	sampler         *sampler
+	filterMutex     sync.Mutex
+	droppedRecords  uint64
+	ctx             context.Context
+	cancel          context.CancelFunc
+	pendingEvents   []RotationEvent
+	eventsPending   int32
+	inflight        inflight
+	periodReset     chan struct{}
+	tasks           []*task
+	daemons         sync.WaitGroup
+	lifecycle       lifecycle
+	fallback        bool
 }
 
 type Options struct {
@@ -30,10 +70,82 @@ type Options struct {
 	// rotated. The default Size is 100 megabyte
 	Size int `json:"size"`
 
+	// SizeBytes is the maximum size in bytes of the log file, which takes
+	// precedence over the Size. The default is to use the Size
+	SizeBytes int64 `json:"size_bytes"`
+
 	// Period is the maximum age of the log file before it gets rotated.
 	// The default Period of the log file is 7 days
 	Period time.Duration `json:"period"`
 
+	// PersistRotationDeadline determines if the deadline of the Period based rotation
+	// is kept in the "<filename>.state" file across the restarts. The default is false
+	PersistRotationDeadline bool `json:"persist_rotation_deadline"`
+
+	// MaintainState determines if the rotation metadata is kept in the
+	// "<filename>.state" file in json format. The default is false
+	MaintainState bool `json:"maintain_state"`
+
+	// Schedule is a five field cron expression, ex- "0 0 * * *", of the
+	// rotations, which takes precedence over the Period. The default is no schedule
+	Schedule string `json:"schedule"`
+
+	// RotationBoundary aligns the rotations to the calendar boundaries, which
+	// takes precedence over the Period. The default is NoBoundary
+	RotationBoundary RotationBoundary `json:"rotation_boundary"`
+
+	// DisableSizeRotation disables the Size based rotation of the log file.
+	// The default value of DisableSizeRotation is false
+	DisableSizeRotation bool `json:"disable_size_rotation"`
+
+	// SplitOversizeWrites determines if a write larger than the maximum file size
+	// is split across the rotations instead of failing. The default is false
+	SplitOversizeWrites bool `json:"split_oversize_writes"`
+
+	// AllowOversizeWrites determines if a write larger than the maximum file size
+	// is written to a fresh log file instead of failing. The default is false
+	AllowOversizeWrites bool `json:"allow_oversize_writes"`
+
+	// Preallocate determines if the disk blocks of the log file are reserved up
+	// to the maximum file size on linux. The default value of Preallocate is false
+	Preallocate bool `json:"preallocate"`
+
+	// DisablePeriodRotation disables the Period based rotation of the log file.
+	// The default value of DisablePeriodRotation is false
+	DisablePeriodRotation bool `json:"disable_period_rotation"`
+
+	// ManualRotation disables all the size and time based rotations of the
+	// log file. The default value of ManualRotation is false
+	ManualRotation bool `json:"manual_rotation"`
+
+	// RotationJitter delays every time based rotation by a random duration
+	// within [0, RotationJitter). The default is no delay
+	RotationJitter time.Duration `json:"rotation_jitter"`
+
+	// MinSize is the minimum size in bytes of the log file for a time based
+	// rotation. The default is to always rotate
+	MinSize int64 `json:"min_size"`
+
+	// SkipIdleRotation skips the time based rotation of a log file, which is not
+	// written since the previous rotation. The default is false
+	SkipIdleRotation bool `json:"skip_idle_rotation"`
+
+	// CopyTruncate determines if the log file is copied and truncated
+	// instead of being renamed. The default value of CopyTruncate is false
+	CopyTruncate bool `json:"copy_truncate"`
+
+	// DisableRotationSync skips syncing the log file and its directory during
+	// the rotations. The default value of DisableRotationSync is false
+	DisableRotationSync bool `json:"disable_rotation_sync"`
+
+	// DetectExternalRotation determines if a log file removed or moved by an
+	// external tool is reopened. The default is false
+	DetectExternalRotation bool `json:"detect_external_rotation"`
+
+	// RotationMarker is the path of a file, which rotates the log file when it
+	// is created or touched. The default is not to watch any marker file
+	RotationMarker string `json:"rotation_marker"`
+
 	// RetentionPeriod is the maximum number of days to retain old log files based
 	// on the timestamp encoded in their filename.  Note that a day is defined as 24
 	// hours and may not exactly correspond to calendar days due to daylight
@@ -41,21 +153,197 @@ type Options struct {
 	// based on age.
 	RetentionPeriod int `json:"retention_period"`
 
+	// RetentionDuration is the maximum age of the old log files, which takes
+	// precedence over the RetentionPeriod. The default is to use the RetentionPeriod
+	RetentionDuration time.Duration `json:"retention_duration"`
+
+	// CleanupInterval is the interval between two retention scans. The default
+	// is an hour, or the retention, if it is shorter
+	CleanupInterval time.Duration `json:"cleanup_interval"`
+
+	// ArchiveDir is the directory, where the log files removed by the retention
+	// are moved. The default is to delete them
+	ArchiveDir string `json:"archive_dir"`
+
+	// RetentionFilter protects the rotated log files, for which it returns false,
+	// from the retention. The default is to apply the retention to all of them
+	RetentionFilter func(BackupInfo) bool `json:"-"`
+
+	// MaxTotalSize is the maximum cumulative size in megabytes of the log files.
+	// The default is not to remove old log files based on disk usage
+	MaxTotalSize int `json:"max_total_size"`
+
+	// MinFreeDiskPercent is the minimum percentage of free space, below which the
+	// oldest log files are pruned. The default is not to monitor the free space
+	MinFreeDiskPercent float64 `json:"min_free_disk_percent"`
+
 	// Compress determines if the rotated log files should be compressed is "extension.gz" format.
 	// The default value of Compress in false
 	Compress bool `json:"compress"`
 
-	// CompressionLevel basically indicates the compression ratio.
-	// Only three types of compression levels are supported
+	// CompressionFormat determines the format of the compressed log files,
+	// Gzip, LZ4, Snappy, XZ or Zip. The default value of CompressionFormat is Gzip
+	CompressionFormat CompressionFormat `json:"compression_format"`
+
+	// Compressor is a custom compression of the rotated log files, which takes
+	// precedence over the CompressionFormat. The default is to use the CompressionFormat
+	Compressor Compressor `json:"-"`
+
+	// CompressionLevel basically indicates the compression ratio of the gzip format.
+	// All the gzip compression levels are supported, an invalid level is rejected
+	// HuffmanOnly        = -2
+	// DefaultCompression = -1
 	// NoCompression      = 0
 	// BestSpeed          = 1
+	// ...
 	// BestCompression    = 9
 	CompressionLevel int `json:"compression_level"`
 
+	// CompressionWorkers is the number of goroutines compressing a rotated log file
+	// in the gzip format. The default is a single goroutine
+	CompressionWorkers int `json:"compression_workers"`
+
+	// CompressAfter is the age of the rotated log files, after which they are
+	// compressed. The default is to compress them immediately
+	CompressAfter time.Duration `json:"compress_after"`
+
+	// CompressDir is the directory of the compressed log files. The default
+	// is the directory of the log file
+	CompressDir string `json:"compress_dir"`
+
+	// StreamCompression determines if the active log file is written through a
+	// gzip stream. The default value of StreamCompression is false
+	StreamCompression bool `json:"stream_compression"`
+
+	// Checksum determines if a SHA-256 checksum file is written for every
+	// rotated log file. The default value of Checksum is false
+	Checksum bool `json:"checksum"`
+
+	// BundleBackups is the number of the rotated log files bundled into a tar
+	// archive. The default is not to bundle the rotated log files
+	BundleBackups int `json:"bundle_backups"`
+
+	// BundleDaily determines if the rotated log files of a day are bundled into
+	// a tar archive. The default value of BundleDaily is false
+	BundleDaily bool `json:"bundle_daily"`
+
 	// LocalTime determines if the time used for formatting the timestamps in
 	// backup files is the computer's local time.  The default is to use UTC
 	// time.
 	LocalTime bool `json:"localtime" yaml:"localtime"`
+
+	// Location is the timezone of the backup timestamps, the schedules and the
+	// retention. The default is to use the LocalTime
+	Location *time.Location `json:"-"`
+
+	// BackupTimeFormat is the layout of the timestamps in the backup file names.
+	// The default is "2006-01-02T15-04-05.000"
+	BackupTimeFormat string `json:"backup_time_format"`
+
+	// BackupNaming determines the naming convention of the rotated log files.
+	// The default is TimestampNaming
+	BackupNaming BackupNaming `json:"backup_naming"`
+
+	// DatePartitionedBackups determines if the rotated log files are placed under
+	// the YYYY/MM/DD directories. The default value is false
+	DatePartitionedBackups bool `json:"date_partitioned_backups"`
+
+	// BackupDir is the directory of the rotated log files. The default
+	// is the directory of the log file
+	BackupDir string `json:"backup_dir"`
+
+	// TimestampedActiveFile determines if the active log file is named with the
+	// timestamp and the Filename is a symlink to it. The default is false
+	TimestampedActiveFile bool `json:"timestamped_active_file"`
+
+	// DailyFile determines if the logs are written to a file named with the
+	// current date. The default value of DailyFile is false
+	DailyFile bool `json:"daily_file"`
+
+	// LatestBackupLink is the name of a symlink to the most recent rotated log
+	// file. The default is not to maintain any symlink
+	LatestBackupLink string `json:"latest_backup_link"`
+
+	// FileHeader returns the content written at the top of every fresh log
+	// file. The default is not to write any header
+	FileHeader func() []byte `json:"-"`
+
+	// FileFooter returns the content appended at the end of the log file before
+	// it is rotated. The default is not to write any footer
+	FileFooter func(FileStats) []byte `json:"-"`
+
+	// FallbackWriter receives the logs, when the log file is not writable.
+	// The default FallbackWriter is os.Stderr
+	FallbackWriter io.Writer `json:"-"`
+
+	// WriteTimeout is the maximum duration of a write to the log file.
+	// The default is to wait for the writes
+	WriteTimeout time.Duration `json:"write_timeout"`
+
+	// BufferSize is the size in bytes of the in-memory buffer of the writes.
+	// The default is not to buffer the writes
+	BufferSize int `json:"buffer_size"`
+
+	// FlushInterval is the interval between two flushes of the buffered logs.
+	// The default value of FlushInterval is a second
+	FlushInterval time.Duration `json:"flush_interval"`
+
+	// RateLimit is the maximum number of the lines or the bytes written per
+	// second. The default is not to limit the rate of the writes
+	RateLimit float64 `json:"rate_limit"`
+
+	// RateLimitBurst is the maximum number of the lines or the bytes written at
+	// once above the RateLimit. The default is the RateLimit rounded up
+	RateLimitBurst int `json:"rate_limit_burst"`
+
+	// RateLimitUnit determines the unit of the RateLimit and the RateLimitBurst.
+	// The default is RateLimitLines
+	RateLimitUnit RateLimitUnit `json:"rate_limit_unit"`
+
+	// RateLimitPolicy determines the handling of the writes exceeding the
+	// RateLimit. The default is RateLimitDrop
+	RateLimitPolicy RateLimitPolicy `json:"rate_limit_policy"`
+
+	// BufferPartialLines determines if the data after the last newline of a write
+	// is held till its newline arrives, see Logger.PartialWriter. The default is false
+	BufferPartialLines bool `json:"buffer_partial_lines"`
+
+	// Sampling is the list of the rules sampling the lines before they are
+	// written. The default is not to sample any line
+	Sampling []SamplingRule `json:"sampling"`
+
+	// DedupWindow is the window, within which the consecutive identical lines
+	// are collapsed. The default is not to collapse any line
+	DedupWindow time.Duration `json:"dedup_window"`
+
+	// CallbackQueueSize is the maximum number of the rotated file names queued
+	// for the Callback.Execute. The default value of CallbackQueueSize is 16
+	CallbackQueueSize int `json:"callback_queue_size"`
+
+	// CallbackOverflow determines the delivery of the rotated file names, when
+	// the callback queue is full. The default is CallbackBlock
+	CallbackOverflow CallbackOverflow `json:"callback_overflow"`
+
+	// CallbackRetries is the maximum number of the retries of a failed
+	// Callback.Deliver. The default is not to retry
+	CallbackRetries int `json:"callback_retries"`
+
+	// CallbackBackoff is the delay before the first retry of a failed
+	// Callback.Deliver. The default value of CallbackBackoff is a second
+	CallbackBackoff time.Duration `json:"callback_backoff"`
+
+	// PersistCallbacks determines if the undelivered rotated file names are kept
+	// in the "<filename>.state" file. The default value of PersistCallbacks is false
+	PersistCallbacks bool `json:"persist_callbacks"`
+
+	// SynchronousCallbacks determines if the compression and the callbacks run in
+	// the rotating goroutine. The default value of SynchronousCallbacks is false
+	SynchronousCallbacks bool `json:"synchronous_callbacks"`
+
+	// clock and freeDiskPercent are captured from the package by New, so
+	// every logger keeps its own time source and disk space probe
+	clock           func() time.Time
+	freeDiskPercent func(string) (float64, error)
 }
 
 type Callback struct {
@@ -64,4 +352,81 @@ type Callback struct {
 	// rotated/compressed file name. The user can implement some additional functionalities
 	// example - upload the rotated file to s3
 	Execute func(string)
+
+	// Deliver will hold a func(string) error definition like the Execute, which
+	// is retried as per the Options.CallbackRetries, if it fails
+	Deliver func(string) error
+
+	// ExecuteContext will hold a func(context.Context, string) definition like the
+	// Execute, with a context, which is cancelled when the logger is closed
+	ExecuteContext func(context.Context, string)
+
+	// Rotated will hold a func(RotationEvent) definition which will be called
+	// with the event of the rotation
+	Rotated func(RotationEvent)
+
+	// BeforeRotate will hold a func(string) error definition which will be called
+	// before the log file is rotated, an error vetoes the rotation
+	BeforeRotate func(string) error
+
+	// LowDiskSpace will hold a func(float64) definition which will be called with the
+	// percentage of the free space, if it can not be recovered
+	LowDiskSpace func(float64)
+
+	// Checksum will hold a func(string, string) definition which will be called
+	// with the rotated file name and its SHA-256 digest
+	Checksum func(string, string)
+
+	// Compressed will hold a func(CompressionStats) definition which will be
+	// called after a rotated log file is compressed
+	Compressed func(CompressionStats)
+
+	// Archive will hold a func(string) error definition which will be called before
+	// the retention removes a rotated log file, an error retains the file
+	Archive func(string) error
+
+	// Pruned will hold a func([]PrunedBackup) definition which will be called
+	// with the rotated log files removed by the retention
+	Pruned func([]PrunedBackup)
+
+	// OnError will hold a func(error) definition which will be called on a
+	// failure of a background operation
+	OnError func(error)
+
+	// Recovered will hold a func(string) definition which will be called when
+	// the writes go back to the log file from the Options.FallbackWriter
+	Recovered func(string)
+
+	// Hooks will hold an implementation of the Hooks interface, which will be
+	// called on every stage of the lifecycle of the log files
+	Hooks Hooks
+}
+
+// CompressionStats holds the statistics of the compression of a rotated log file
+type CompressionStats struct {
+	// File is the name of the rotated log file
+	File string `json:"file"`
+	// CompressedFile is the name of the compressed log file
+	CompressedFile string `json:"compressed_file"`
+	// OriginalSize and CompressedSize are the sizes in bytes of
+	// the rotated log file and the compressed log file
+	OriginalSize   int64 `json:"original_size"`
+	CompressedSize int64 `json:"compressed_size"`
+	// Ratio is the OriginalSize divided by the CompressedSize
+	Ratio float64 `json:"ratio"`
+	// Duration is the time taken to compress the rotated log file
+	Duration time.Duration `json:"duration"`
+}
+
+// FileStats holds the statistics of the writes to a log file since it was opened
+type FileStats struct {
+	// Lines is the number of lines written to the log file
+	Lines int64 `json:"lines"`
+	// Bytes is the number of bytes written to the log file,
+	// excluding the header and the footer
+	Bytes int64 `json:"bytes"`
+	// FirstWrite and LastWrite are the times of the first
+	// and the last write to the log file
+	FirstWrite time.Time `json:"first_write"`
+	LastWrite  time.Time `json:"last_write"`
 }
@@ -0,0 +1,81 @@
+package eidos
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// maxPartialLineSize represents the maximum size of a held partial line,
+// a longer partial line is written without waiting for its newline
+const maxPartialLineSize = 1024 * 1024
+
+// partialLine holds the data after the last newline of the writes, till the
+// newline arrives, so a record written in multiple writes stays contiguous.
+// The mutex is never held while locking the logger, so the logger can drain
+// the partial line holding its own lock, ex- while closing the log file.
+type partialLine struct {
+	mutex   sync.Mutex
+	pending []byte
+}
+
+// complete appends the held partial line and the complete lines of p to
+// out, and holds the data after the last newline of p. The partial line is
+// appended without waiting for its newline, once it exceeds the maxPartialLineSize.
+func (b *partialLine) complete(out, p []byte) []byte {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	end := bytes.LastIndexByte(p, '\n') + 1
+	if end == 0 {
+		if len(b.pending)+len(p) <= maxPartialLineSize {
+			b.pending = append(b.pending, p...)
+			return out
+		}
+		end = len(p)
+	}
+	out = append(out, b.pending...)
+	out = append(out, p[:end]...)
+	b.pending = append(b.pending[:0], p[end:]...)
+	return out
+}
+
+// drain appends the held partial line to out, if any
+func (b *partialLine) drain(out []byte) []byte {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	out = append(out, b.pending...)
+	b.pending = b.pending[:0]
+	return out
+}
+
+// PartialWriter returns a writer, which holds the data after the last newline
+// of its writes till the newline arrives, like the BufferPartialLines, but
+// apart from the other writers. So the records written in multiple writes by
+// the concurrent goroutines, each with its own PartialWriter, are not joined.
+// The lines are sampled and collapsed like the lines of the Write. The Close
+// of the writer writes the held partial line, it does not close the logger.
+func (l *Logger) PartialWriter() io.WriteCloser {
+	return &partialWriter{logger: l}
+}
+
+// partialWriter is a writer of the logger holding its own partial line
+type partialWriter struct {
+	logger  *Logger
+	partial partialLine
+}
+
+// Write writes the complete lines of p, and holds the data after the last newline
+func (w *partialWriter) Write(p []byte) (int, error) {
+	return w.logger.writeFiltered(p, &w.partial)
+}
+
+// Close writes the held partial line, if any
+func (w *partialWriter) Close() error {
+	pending := w.partial.drain(nil)
+	if len(pending) == 0 {
+		return nil
+	}
+	_, err := w.logger.writeFiltered(pending, nil)
+	return err
+}
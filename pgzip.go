@@ -0,0 +1,108 @@
+package eidos
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// parallelGzipBlockSize is the size of the uncompressed data of a gzip member
+const parallelGzipBlockSize = 1 << 20
+
+// compressedBlock holds the result of the compression of a block
+type compressedBlock struct {
+	data *bytes.Buffer
+	err  error
+}
+
+// parallelGzipWriter compresses the blocks of the data concurrently. Every
+// block is compressed into a separate gzip member, and the members are
+// written in order. A multi member gzip file is decompressed by gunzip
+// and gzip.Reader like a single member file.
+type parallelGzipWriter struct {
+	w                io.Writer
+	compressionLevel int
+	workers          int
+	// header is written in every gzip member
+	header gzip.Header
+	buffer []byte
+	// queue holds the results of the blocks being compressed in order
+	queue   []chan compressedBlock
+	written bool
+}
+
+// newParallelGzipWriter returns a parallelGzipWriter compressing
+// at most workers blocks concurrently
+func newParallelGzipWriter(w io.Writer, compressionLevel, workers int) *parallelGzipWriter {
+	return &parallelGzipWriter{w: w, compressionLevel: compressionLevel, workers: workers}
+}
+
+// Write buffers the data and compresses the complete blocks
+func (z *parallelGzipWriter) Write(p []byte) (int, error) {
+	z.buffer = append(z.buffer, p...)
+	for len(z.buffer) >= parallelGzipBlockSize {
+		if err := z.compress(z.buffer[:parallelGzipBlockSize]); err != nil {
+			return 0, err
+		}
+		z.buffer = z.buffer[parallelGzipBlockSize:]
+	}
+	return len(p), nil
+}
+
+// Close compresses the remaining data and writes all the pending blocks
+func (z *parallelGzipWriter) Close() error {
+	// An empty input is written as an empty gzip member
+	if len(z.buffer) > 0 || !z.written {
+		if err := z.compress(z.buffer); err != nil {
+			return err
+		}
+		z.buffer = nil
+	}
+	for len(z.queue) > 0 {
+		if err := z.writeOldest(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compress starts the compression of the block. If the maximum number of
+// blocks are being compressed, then the oldest block is written first.
+func (z *parallelGzipWriter) compress(block []byte) error {
+	if len(z.queue) >= z.workers {
+		if err := z.writeOldest(); err != nil {
+			return err
+		}
+	}
+
+	result := make(chan compressedBlock, 1)
+	z.queue = append(z.queue, result)
+	z.written = true
+	go func() {
+		// The buffer is returned to the pool, once the block is written
+		buffer := blockBuffers.Get().(*bytes.Buffer)
+		buffer.Reset()
+		gzWriter, err := getGzipWriter(buffer, z.compressionLevel)
+		if err == nil {
+			gzWriter.Header = z.header
+			_, err = gzWriter.Write(block)
+			if closeErr := gzWriter.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		result <- compressedBlock{data: buffer, err: err}
+	}()
+	return nil
+}
+
+// writeOldest waits for the compression of the oldest block and writes it
+func (z *parallelGzipWriter) writeOldest() error {
+	result := <-z.queue[0]
+	z.queue = z.queue[1:]
+	defer blockBuffers.Put(result.data)
+	if result.err != nil {
+		return result.err
+	}
+	_, err := z.w.Write(result.data.Bytes())
+	return err
+}
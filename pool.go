@@ -0,0 +1,77 @@
+package eidos
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// defaultCompressionQueueLength is the default number of the rotated
+// log files which can wait for the compression
+const defaultCompressionQueueLength = 64
+
+// compressionPool bounds the number of the rotated log files
+// being compressed concurrently across all the loggers
+type compressionPool struct {
+	tasks chan func()
+}
+
+var (
+	// pool is the compression pool shared across all the loggers,
+	// it is started on the first compression
+	pool      *compressionPool
+	poolMutex sync.RWMutex
+)
+
+// newCompressionPool starts the workers of a compression pool
+func newCompressionPool(workers, queueLength int) *compressionPool {
+	p := &compressionPool{tasks: make(chan func(), queueLength)}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for task := range p.tasks {
+				task()
+			}
+		}()
+	}
+	return p
+}
+
+// SetCompressionPool configures the compression pool shared across all the
+// loggers. At most workers rotated log files are compressed concurrently and
+// at most queueLength rotated log files wait for the compression. If the queue
+// is full, then the rotating goroutine waits, after unlocking the logger, till
+// a queued log file gets compressed, so a burst of rotations can not saturate
+// the CPU and the disk. The rotated log files, which are already queued, are
+// compressed by the previous pool. The default pool has a worker per CPU and
+// a queue length of 64.
+func SetCompressionPool(workers, queueLength int) error {
+	if workers <= 0 || queueLength < 0 {
+		return fmt.Errorf("invalid compression pool workers %v, queue length %v", workers, queueLength)
+	}
+
+	poolMutex.Lock()
+	defer poolMutex.Unlock()
+	if pool != nil {
+		// The workers of the previous pool exit after the queued tasks
+		close(pool.tasks)
+	}
+	pool = newCompressionPool(workers, queueLength)
+	return nil
+}
+
+// submitCompression queues the compression task in the compression pool
+func submitCompression(task func()) {
+	poolMutex.RLock()
+	if pool == nil {
+		// Starting the default pool
+		poolMutex.RUnlock()
+		poolMutex.Lock()
+		if pool == nil {
+			pool = newCompressionPool(runtime.NumCPU(), defaultCompressionQueueLength)
+		}
+		poolMutex.Unlock()
+		poolMutex.RLock()
+	}
+	defer poolMutex.RUnlock()
+	pool.tasks <- task
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package eidos
+
+import "os"
+
+// preallocate is a no-op, as the preallocation is not supported on this platform
+func preallocate(_ *os.File, _ int64) error {
+	return nil
+}
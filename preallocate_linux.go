@@ -0,0 +1,20 @@
+package eidos
+
+import (
+	"os"
+	"syscall"
+)
+
+// fallocKeepSize reserves the blocks without changing the size of the file
+const fallocKeepSize = 0x01
+
+// preallocate reserves the blocks of the file up to the size, without
+// changing the size of the file. The filesystems, which do not support
+// the preallocation, are ignored.
+func preallocate(f *os.File, size int64) error {
+	err := syscall.Fallocate(int(f.Fd()), fallocKeepSize, 0, size)
+	if err == syscall.EOPNOTSUPP || err == syscall.ENOSYS {
+		return nil
+	}
+	return err
+}
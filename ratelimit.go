@@ -0,0 +1,152 @@
+package eidos
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RateLimitUnit represents the unit of the Options.RateLimit
+type RateLimitUnit int
+
+const (
+	// RateLimitLines limits the number of the lines written per second,
+	// a write without any newline is accounted as a single line
+	RateLimitLines RateLimitUnit = iota
+	// RateLimitBytes limits the number of the bytes written per second
+	RateLimitBytes
+)
+
+// String returns the name of the rate limit unit
+func (u RateLimitUnit) String() string {
+	switch u {
+	case RateLimitLines:
+		return "lines"
+	case RateLimitBytes:
+		return "bytes"
+	}
+	return fmt.Sprintf("RateLimitUnit(%d)", int(u))
+}
+
+// RateLimitPolicy represents the handling of the writes,
+// which exceed the Options.RateLimit
+type RateLimitPolicy int
+
+const (
+	// RateLimitDrop drops the writes exceeding the rate limit
+	RateLimitDrop RateLimitPolicy = iota
+	// RateLimitBlock waits till the rate limit admits the write
+	RateLimitBlock
+)
+
+// String returns the name of the rate limit policy
+func (p RateLimitPolicy) String() string {
+	switch p {
+	case RateLimitDrop:
+		return "drop"
+	case RateLimitBlock:
+		return "block"
+	}
+	return fmt.Sprintf("RateLimitPolicy(%d)", int(p))
+}
+
+// rateLimiter is a token bucket, which is refilled at the rate
+// tokens per second and holds at most the burst tokens
+type rateLimiter struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	// limited is true while the writes are dropped, so the
+	// start of a storm of the dropped writes is reported once
+	limited bool
+}
+
+// newRateLimiter returns a rate limiter with a full bucket
+func newRateLimiter(rate float64, burst int, now time.Time) *rateLimiter {
+	return &rateLimiter{rate: rate, burst: float64(burst), tokens: float64(burst), last: now}
+}
+
+// reserve takes the tokens of the cost from the bucket. A cost larger than
+// the burst is admitted with a full bucket, leaving the bucket in debt, so a
+// large write is not rejected forever. It returns zero, if the cost is
+// admitted, or else the time till the bucket holds enough tokens.
+func (r *rateLimiter) reserve(cost float64, now time.Time) time.Duration {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if now.After(r.last) {
+		r.tokens = math.Min(r.burst, r.tokens+now.Sub(r.last).Seconds()*r.rate)
+		r.last = now
+	}
+	required := math.Min(cost, r.burst)
+	if r.tokens >= required {
+		r.tokens -= cost
+		return 0
+	}
+	return time.Duration(math.Ceil((required - r.tokens) / r.rate * float64(time.Second)))
+}
+
+// limit marks the start of a storm of the dropped writes. It
+// returns true, if the storm has not been marked already.
+func (r *rateLimiter) limit(limited bool) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	changed := r.limited != limited
+	r.limited = limited
+	return changed && limited
+}
+
+// DroppedRecords returns the number of the lines, which were dropped
+// by the RateLimitDrop policy without being written to the log file
+func (l *Logger) DroppedRecords() uint64 {
+	return atomic.LoadUint64(&l.droppedRecords)
+}
+
+// admit returns true, if the data can be written as per the RateLimit. The
+// RateLimitDrop policy counts the lines of the dropped data and reports the
+// start of a storm of the dropped writes to the OnError, while the
+// RateLimitBlock policy waits till the data is admitted or the logger is closed.
+func (l *Logger) admit(p []byte) bool {
+	if l.limiter == nil {
+		return true
+	}
+
+	lines := bytes.Count(p, []byte{'\n'})
+	if lines == 0 {
+		lines = 1
+	}
+	cost := float64(lines)
+	if l.RotationOption.RateLimitUnit == RateLimitBytes {
+		cost = float64(len(p))
+	}
+
+	for {
+		wait := l.limiter.reserve(cost, l.RotationOption.now())
+		if wait == 0 {
+			l.limiter.limit(false)
+			return true
+		}
+		if l.RotationOption.RateLimitPolicy == RateLimitDrop {
+			atomic.AddUint64(&l.droppedRecords, uint64(lines))
+			if l.limiter.limit(true) {
+				l.reportError(fmt.Errorf("%w: %v %v per second", ErrRateLimited,
+					l.RotationOption.RateLimit, l.RotationOption.RateLimitUnit))
+			}
+			return false
+		}
+
+		// The blocked writes are released, when the logger is closed
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-l.ctx.Done():
+			timer.Stop()
+			return true
+		}
+	}
+}
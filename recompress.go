@@ -0,0 +1,123 @@
+package eidos
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Recompress re-encodes the gzip compressed rotated log files into the
+// CompressionFormat or the Compressor of the logger, so the historical
+// backups can be migrated after switching the compression format. The
+// names, the modes and the modification times of the backups are retained,
+// only the compression extension is changed. The Compress need not be
+// enabled, so a logger created only for the migration, ex- by the eidos
+// command, does not compress the backups of a running service at startup.
+// It returns the number of the re-encoded backups.
+func (l *Logger) Recompress() (int, error) {
+	options := l.RotationOption
+	compressor := options.compressor()
+	if compressor.Ext() == Gzip.extension() {
+		return 0, nil
+	}
+
+	// Listing the gzip compressed backups
+	gzipOptions := *options
+	gzipOptions.CompressionFormat = Gzip
+	gzipOptions.Compressor = nil
+	backups, err := backupFiles(l.Filename, &gzipOptions)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list backup files: %v", err)
+	}
+
+	// The sequence named backups are not shifted while being re-encoded
+	if options.BackupNaming == SequenceNaming {
+		l.shiftMutex.Lock()
+		defer l.shiftMutex.Unlock()
+	}
+
+	var recompressed int
+	for _, f := range backups {
+		if !strings.HasSuffix(f.Name(), Gzip.extension()) {
+			continue
+		}
+		if err := recompressFile(l.Filename, f, options); err != nil {
+			return recompressed, err
+		}
+		recompressed++
+	}
+	if recompressed > 0 {
+		l.saveState()
+	}
+	return recompressed, nil
+}
+
+// recompressFile decompresses the gzip compressed backup into a temporary
+// file and compresses it with the compressor of the options
+func recompressFile(file string, backup backupFile, options *Options) error {
+	backupFileName := strings.TrimSuffix(backup.path, Gzip.extension())
+
+	// The backup is decompressed under a temporary directory, so it is not
+	// listed as an uncompressed backup and the compressors, which embed the
+	// name of the log file, ex- zip, retain the name of the backup
+	tempDir, err := ioutil.TempDir(filepath.Dir(backup.path), ".recompress")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	tempFileName := filepath.Join(tempDir, filepath.Base(backupFileName))
+	if err := gunzipFile(backup.path, tempFileName, backup.Mode()); err != nil {
+		return err
+	}
+	// The compressors embedding the modification time, ex- gzip,
+	// should embed the modification time of the backup
+	_ = os.Chtimes(tempFileName, backup.ModTime(), backup.ModTime())
+
+	compressedFileName := compressedFileName(file, backupFileName, options)
+	if err := compressLogFile(tempFileName, compressedFileName, options.compressor()); err != nil {
+		return err
+	}
+
+	// Retaining the modification time of the backup, which the
+	// retention of the sequence named backups relies on
+	_ = os.Chtimes(compressedFileName, backup.ModTime(), backup.ModTime())
+
+	// Removing the gzip compressed backup and its checksum file
+	if err := os.Remove(backup.path); err != nil {
+		return err
+	}
+	if _, err := os.Stat(backup.path + checksumExtension); err == nil {
+		_ = os.Remove(backup.path + checksumExtension)
+		_, _ = writeChecksum(compressedFileName)
+	}
+	return nil
+}
+
+// gunzipFile decompresses the gzip compressed source file into the destination file
+func gunzipFile(source, destination string, mode os.FileMode) error {
+	file, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("failed to open compressed log file: %v", err)
+	}
+	defer file.Close()
+
+	gzReader, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("failed to read compressed log file: %v", err)
+	}
+
+	decompressedFile, err := os.OpenFile(destination, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %v", err)
+	}
+	defer decompressedFile.Close()
+
+	if _, err := copyFileData(decompressedFile, gzReader); err != nil {
+		return fmt.Errorf("failed to decompress log file: %v", err)
+	}
+	return decompressedFile.Close()
+}
@@ -0,0 +1,47 @@
+package eidos
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+)
+
+// registry holds the open loggers of the process keyed by the absolute
+// path of their log files, so two loggers never write to the same log
+// file and corrupt each other with the interleaved rotations
+var registry = struct {
+	sync.Mutex
+	loggers map[string]*Logger
+}{loggers: make(map[string]*Logger)}
+
+// registryKey returns the absolute path of the log file
+func registryKey(filename string) string {
+	if abs, err := filepath.Abs(filename); err == nil {
+		return abs
+	}
+	return filepath.Clean(filename)
+}
+
+// register adds the logger to the registry. An error is returned,
+// if another open logger is writing to the same log file.
+func register(l *Logger) error {
+	key := registryKey(l.Filename)
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.loggers[key]; ok {
+		return fmt.Errorf("%w: %s", ErrFileInUse, key)
+	}
+	registry.loggers[key] = l
+	return nil
+}
+
+// unregister removes the closed logger from the registry,
+// so the log file can be opened by a new logger
+func unregister(l *Logger) {
+	key := registryKey(l.Filename)
+	registry.Lock()
+	defer registry.Unlock()
+	if registry.loggers[key] == l {
+		delete(registry.loggers, key)
+	}
+}
@@ -0,0 +1,370 @@
+package eidos
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RetentionReason represents the retention policy, which
+// requires the removal of a rotated log file
+type RetentionReason int
+
+const (
+	// RetentionAge removes the rotated log files, which are
+	// older than the RetentionPeriod or the RetentionDuration
+	RetentionAge RetentionReason = iota
+	// RetentionSize removes the oldest rotated log files, while the
+	// cumulative size of the log files is more than the MaxTotalSize
+	RetentionSize
+	// RetentionDiskSpace removes the oldest rotated log files, while the
+	// free space of the disk is less than the MinFreeDiskPercent
+	RetentionDiskSpace
+)
+
+// String returns the name of the retention reason
+func (r RetentionReason) String() string {
+	switch r {
+	case RetentionAge:
+		return "age"
+	case RetentionSize:
+		return "size"
+	case RetentionDiskSpace:
+		return "disk_space"
+	}
+	return fmt.Sprintf("RetentionReason(%d)", int(r))
+}
+
+// PrunedBackup represents a rotated log file, which is removed by the retention
+type PrunedBackup struct {
+	// File is the name of the rotated log file
+	File string `json:"file"`
+	// Size is the size of the rotated log file in bytes
+	Size int64 `json:"size"`
+	// Age is the time elapsed since the rotation of the rotated log file
+	Age time.Duration `json:"age"`
+	// Reason is the retention policy, which requires the removal
+	Reason RetentionReason `json:"reason"`
+
+	backup backupFile
+}
+
+// BackupInfo describes a rotated log file
+type BackupInfo struct {
+	// File is the name of the rotated log file
+	File string `json:"file"`
+	// Size is the size of the rotated log file in bytes
+	Size int64 `json:"size"`
+	// RotationTime is the time of the rotation parsed from the file name,
+	// or the modification time, if the file name carries no timestamp
+	RotationTime time.Time `json:"rotation_time"`
+	// ModTime is the modification time of the rotated log file
+	ModTime time.Time `json:"mod_time"`
+	// Tag is the tag embedded into the file name by the Rotate, if any
+	Tag string `json:"tag"`
+	// Compressed determines if the rotated log file is compressed
+	Compressed bool `json:"compressed"`
+	// Checksum is the hex encoded SHA-256 digest read from the
+	// checksum file of the rotated log file, if any
+	Checksum string `json:"checksum"`
+}
+
+// backupInfo returns the description of the rotated log file
+func backupInfo(codec backupCodec, backup backupFile) BackupInfo {
+	info := BackupInfo{
+		File:         backup.path,
+		Size:         backup.Size(),
+		RotationTime: backupTime(codec, backup),
+		ModTime:      backup.ModTime(),
+		Compressed:   codec.compressed(backup.Name()),
+	}
+
+	// The checksum file holds the digest followed by the name of the file
+	if checksum, err := ioutil.ReadFile(backup.path + checksumExtension); err == nil {
+		if fields := strings.Fields(string(checksum)); len(fields) > 0 {
+			info.Checksum = fields[0]
+		}
+	}
+
+	// The tag follows the timestamp, ex- app-2020-10-15T10-30-00.000-incident.log
+	if codec.options.BackupNaming == TimestampNaming {
+		if parsed, err := codec.parse(backup.Name()); err == nil {
+			info.Tag = parsed.tag
+		}
+	}
+	return info
+}
+
+// Backups returns the descriptions of the rotated log files of the logger,
+// the oldest rotated log file will be the first element, so the applications
+// can build their own retention, upload or listing on top of it.
+func (l *Logger) Backups() ([]BackupInfo, error) {
+	backups, err := backupFiles(l.Filename, l.RotationOption)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backup files: %v", err)
+	}
+
+	codec := newBackupCodec(l.Filename, l.RotationOption)
+	infos := make([]BackupInfo, 0, len(backups))
+	for _, f := range backups {
+		infos = append(infos, backupInfo(codec, f))
+	}
+	return infos, nil
+}
+
+// Pin excludes the rotated log file from all the retention policies till it
+// is released by Unpin, ex- for the incident forensics and the compliance
+// holds. The filename can be the path or the base name of the rotated log
+// file, and the pin survives the compression of the rotated log file. The
+// pins are held in memory, so they are released when the process exits.
+func (l *Logger) Pin(filename string) error {
+	backups, err := backupFiles(l.Filename, l.RotationOption)
+	if err != nil {
+		return fmt.Errorf("failed to list backup files: %v", err)
+	}
+
+	key := l.pinKey(filename)
+	for _, f := range backups {
+		if l.pinKey(f.Name()) == key {
+			l.pinMutex.Lock()
+			defer l.pinMutex.Unlock()
+			if l.pinned == nil {
+				l.pinned = make(map[string]bool)
+			}
+			l.pinned[key] = true
+			return nil
+		}
+	}
+	return fmt.Errorf("backup file %q does not exist", filename)
+}
+
+// Unpin releases the rotated log file pinned by Pin, so it is
+// subject to the retention policies again
+func (l *Logger) Unpin(filename string) {
+	l.pinMutex.Lock()
+	defer l.pinMutex.Unlock()
+	delete(l.pinned, l.pinKey(filename))
+}
+
+// isPinned returns true if the rotated log file is pinned
+func (l *Logger) isPinned(filename string) bool {
+	l.pinMutex.Lock()
+	defer l.pinMutex.Unlock()
+	return l.pinned[l.pinKey(filename)]
+}
+
+// pinKey returns the base name of the rotated log file without
+// the compression extension, so the pin survives the compression
+func (l *Logger) pinKey(filename string) string {
+	return newBackupCodec(l.Filename, l.RotationOption).uncompressedName(filepath.Base(filename))
+}
+
+// RetentionPlan returns the rotated log files, which would be removed by the
+// retention with the reason of the removal, without removing them. It can
+// be used to preview the changes of the retention policies.
+func (l *Logger) RetentionPlan() ([]PrunedBackup, error) {
+	return l.retentionPlan()
+}
+
+// Prune synchronously applies the retention policies on demand, instead of
+// waiting for the retention ticker, and returns the removed rotated log
+// files. The removal stops, if the context is done. The removed log files
+// are also passed to the Callback.Pruned.
+func (l *Logger) Prune(ctx context.Context) ([]PrunedBackup, error) {
+	return l.prune(ctx)
+}
+
+// retention returns the maximum age of the rotated log files. The
+// RetentionDuration takes precedence over the RetentionPeriod.
+func (o *Options) retention() time.Duration {
+	if o.RetentionDuration > 0 {
+		return o.RetentionDuration
+	}
+	return time.Duration(o.RetentionPeriod) * 24 * time.Hour
+}
+
+// cleanupInterval returns the interval between two scans for the rotated
+// log files exceeding the retention
+func (o *Options) cleanupInterval() time.Duration {
+	if o.CleanupInterval > 0 {
+		return o.CleanupInterval
+	}
+	if o.retention() > 0 && o.retention() < defaultCleanupInterval {
+		return o.retention()
+	}
+	return defaultCleanupInterval
+}
+
+// backupTime returns the rotation time of the rotated log file parsed from
+// the file name, ignoring the tag, if any. The modification time of the file
+// is used instead, if the file name carries no timestamp, ex- the sequence
+// named backups, or the timestamp can not be parsed.
+func backupTime(codec backupCodec, backup backupFile) time.Time {
+	if codec.options.BackupNaming == SequenceNaming {
+		return backup.ModTime()
+	}
+	parsed, err := codec.parse(backup.Name())
+	if err != nil || parsed.time.IsZero() {
+		return backup.ModTime()
+	}
+	return parsed.time
+}
+
+// retentionPlan returns the rotated log files whose retention period has
+// exceeded and, if a MaxTotalSize is configured, the oldest rotated log
+// files till the cumulative size of the log files is within the limit.
+func (l *Logger) retentionPlan() ([]PrunedBackup, error) {
+	file, options := l.Filename, l.RotationOption
+	codec := newBackupCodec(file, options)
+
+	files, err := backupFiles(file, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan []PrunedBackup
+	// backups holds the rotated log files which are retained after the
+	// retention period check, the oldest backup will be the first element
+	var backups []PrunedBackup
+	// totalSize holds the cumulative size of the active log file and the retained backups
+	var totalSize int64
+	if fileInfo, err := os.Stat(file); err == nil {
+		totalSize += fileInfo.Size()
+	}
+
+	for _, f := range files {
+		age := options.now().Sub(backupTime(codec, f))
+
+		// The rotated log files pinned or protected by the RetentionFilter
+		// are retained, but they are accounted in the cumulative size
+		if l.isPinned(f.Name()) ||
+			options.RetentionFilter != nil && !options.RetentionFilter(backupInfo(codec, f)) {
+			totalSize += f.Size()
+			continue
+		}
+
+		// Checking the age of the file, if the age is greater than
+		// the provided retention period, then remove the file
+		if options.retention() > 0 && age > options.retention() {
+			plan = append(plan, PrunedBackup{File: f.path, Size: f.Size(), Age: age, Reason: RetentionAge, backup: f})
+			continue
+		}
+		backups = append(backups, PrunedBackup{File: f.path, Size: f.Size(), Age: age, Reason: RetentionSize, backup: f})
+		totalSize += f.Size()
+	}
+
+	// If the MaxTotalSize is 0 then the logs files will not be removed based on disk usage
+	if options.MaxTotalSize <= 0 {
+		return plan, nil
+	}
+
+	// Removing the oldest backups till the cumulative size is within the limit
+	maxTotalSize := int64(options.MaxTotalSize) * int64(megabyte)
+	for _, f := range backups {
+		if totalSize <= maxTotalSize {
+			break
+		}
+		plan = append(plan, f)
+		totalSize -= f.Size
+	}
+	return plan, nil
+}
+
+// cleanUpOldLogs removes the rotated log files whose retention period has
+// exceeded and, if a MaxTotalSize is configured, removes the oldest rotated
+// log files until the cumulative size of the log files is within the limit.
+// The removed log files are passed to the Callback.Pruned.
+func (l *Logger) cleanUpOldLogs() {
+	if _, err := l.prune(context.Background()); err != nil {
+		l.reportError(err)
+	}
+}
+
+// prune removes the rotated log files of the retention plan till the context
+// is done, and returns the removed log files and the last error, if any
+func (l *Logger) prune(ctx context.Context) ([]PrunedBackup, error) {
+	// The retention is enforced by a single thread at a time, so a
+	// rotated log file is not archived twice by the concurrent scans
+	l.retentionMutex.Lock()
+	defer l.retentionMutex.Unlock()
+
+	plan, err := l.retentionPlan()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backup files: %v", err)
+	}
+
+	var prunedBackups []PrunedBackup
+	for _, pruned := range plan {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		if pruneErr := l.pruneBackup(pruned.backup); pruneErr != nil {
+			err = pruneErr
+			continue
+		}
+		prunedBackups = append(prunedBackups, pruned)
+		l.callback.Hooks.OnDelete(pruned.File)
+	}
+	if len(prunedBackups) > 0 {
+		l.callback.Pruned(prunedBackups)
+	}
+	return prunedBackups, err
+}
+
+// pruneBackup archives the expired rotated log file before its removal.
+// The Callback.Archive is triggered first, and if it succeeds, then the
+// rotated log file is moved into the ArchiveDir, if any, or removed.
+// If the archival fails, then the rotated log file is retained.
+func (l *Logger) pruneBackup(backup backupFile) error {
+	if err := l.callback.Archive(backup.path); err != nil {
+		return fmt.Errorf("failed to archive backup file: %v", err)
+	}
+	if l.RotationOption.ArchiveDir == "" {
+		return removeBackup(l.Filename, backup, l.RotationOption)
+	}
+	return archiveBackup(l.Filename, backup, l.RotationOption)
+}
+
+// archiveBackup moves the rotated log file and its checksum file, if any,
+// into the ArchiveDir, retaining the date partition of the rotated log file.
+// A sequence suffix is appended, if the archived file exists. If the
+// ArchiveDir is on a different filesystem, then the files are copied and
+// removed. The checksum file is moved after the rotated log file, so it is
+// never separated from a rotated log file, which failed to be archived.
+func archiveBackup(file string, backup backupFile, options *Options) error {
+	relativeName, err := filepath.Rel(backupRoot(file, backup.path, options), backup.path)
+	if err != nil {
+		relativeName = backup.Name()
+	}
+	archivedFileName := uniqueBackupName(file, filepath.Join(options.ArchiveDir, relativeName), options)
+	if err := os.MkdirAll(filepath.Dir(archivedFileName), 0755); err != nil {
+		return fmt.Errorf("failed to archive backup file: %v", err)
+	}
+
+	if err := moveFile(backup.path, archivedFileName, backup.FileInfo); err != nil {
+		return fmt.Errorf("failed to archive backup file: %v", err)
+	}
+	checksumFileName := backup.path + checksumExtension
+	if fileInfo, err := os.Stat(checksumFileName); err == nil {
+		_ = moveFile(checksumFileName, archivedFileName+checksumExtension, fileInfo)
+	}
+	removeEmptyPartitions(file, backup.path, options)
+	return nil
+}
+
+// moveFile renames the file, or copies and removes it, if the
+// destination is on a different filesystem. The file is removed
+// only after a successful copy.
+func moveFile(sourceFile, destinationFile string, fileInfo os.FileInfo) error {
+	if os.Rename(sourceFile, destinationFile) == nil {
+		return nil
+	}
+	if err := copyFile(sourceFile, destinationFile, fileInfo); err != nil {
+		return err
+	}
+	return os.Remove(sourceFile)
+}
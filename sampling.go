@@ -0,0 +1,94 @@
+package eidos
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"sync/atomic"
+)
+
+// SamplingRule samples the lines matching the Prefix and the Pattern, ex-
+// SamplingRule{Prefix: "DEBUG", Every: 100} keeps 1 of every 100 debug lines
+type SamplingRule struct {
+	// Prefix matches the lines starting with the prefix, and Pattern matches
+	// the lines matching the regular expression. A line matches the rule, if
+	// it matches both of them, an empty Prefix or Pattern matches any line.
+	Prefix  string `json:"prefix"`
+	Pattern string `json:"pattern"`
+
+	// Every keeps the first of every Every matching lines, and Probability
+	// keeps every matching line with the probability within (0, 1]. Exactly
+	// one of them should be provided.
+	Every       int     `json:"every"`
+	Probability float64 `json:"probability"`
+}
+
+// samplingRule is a SamplingRule with the compiled pattern
+// and the number of the lines matched by the rule
+type samplingRule struct {
+	SamplingRule
+	pattern *regexp.Regexp
+	matched uint64
+}
+
+// sampler drops the lines sampled out by the first matching rule,
+// the lines not matching any rule are kept
+type sampler struct {
+	rules []*samplingRule
+}
+
+// newSampler validates the sampling rules and compiles their patterns
+func newSampler(rules []SamplingRule) (*sampler, error) {
+	s := &sampler{}
+	for _, rule := range rules {
+		if rule.Every < 0 || rule.Probability < 0 || rule.Probability > 1 ||
+			(rule.Every > 0) == (rule.Probability > 0) {
+			return nil, fmt.Errorf("%w: sampling rule every %v probability %v",
+				ErrInvalidOption, rule.Every, rule.Probability)
+		}
+		compiled := &samplingRule{SamplingRule: rule}
+		if rule.Pattern != "" {
+			pattern, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("%w: sampling pattern %q-%v", ErrInvalidOption, rule.Pattern, err)
+			}
+			compiled.pattern = pattern
+		}
+		s.rules = append(s.rules, compiled)
+	}
+	return s, nil
+}
+
+// filter appends the lines of p, which are kept by the sampling rules, to out.
+// A trailing data without any newline is sampled as a line.
+func (s *sampler) filter(out, p []byte) []byte {
+	for len(p) > 0 {
+		end := bytes.IndexByte(p, '\n') + 1
+		if end == 0 {
+			end = len(p)
+		}
+		line := p[:end]
+		p = p[end:]
+
+		if s.keep(line) {
+			out = append(out, line...)
+		}
+	}
+	return out
+}
+
+// keep returns true, if the line is kept by the first matching rule
+func (s *sampler) keep(line []byte) bool {
+	for _, rule := range s.rules {
+		if !bytes.HasPrefix(line, []byte(rule.Prefix)) ||
+			rule.pattern != nil && !rule.pattern.Match(line) {
+			continue
+		}
+		if rule.Every > 0 {
+			return (atomic.AddUint64(&rule.matched, 1)-1)%uint64(rule.Every) == 0
+		}
+		return rand.Float64() < rule.Probability
+	}
+	return true
+}
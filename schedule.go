@@ -0,0 +1,70 @@
+package eidos
+
+import (
+	"fmt"
+	"time"
+)
+
+// RotationBoundary represents a calendar boundary at which the log file is rotated
+type RotationBoundary int
+
+const (
+	// NoBoundary rotates the log file based on the Period
+	NoBoundary RotationBoundary = iota
+	// Hourly rotates the log file at the start of every hour
+	Hourly
+	// Daily rotates the log file at every midnight
+	Daily
+	// Weekly rotates the log file at the midnight between Sunday and Monday
+	Weekly
+	// Monthly rotates the log file at the midnight of the first day of every month
+	Monthly
+)
+
+// String returns the name of the rotation boundary
+func (b RotationBoundary) String() string {
+	switch b {
+	case NoBoundary:
+		return "none"
+	case Hourly:
+		return "hourly"
+	case Daily:
+		return "daily"
+	case Weekly:
+		return "weekly"
+	case Monthly:
+		return "monthly"
+	}
+	return fmt.Sprintf("RotationBoundary(%d)", int(b))
+}
+
+// schedule returns the next rotation instant after the given time
+type schedule interface {
+	next(t time.Time) time.Time
+}
+
+// boundarySchedule aligns the rotation instants to the calendar boundaries
+type boundarySchedule struct {
+	boundary RotationBoundary
+	location *time.Location
+}
+
+// next returns the start of the next calendar boundary after t. The
+// boundaries are calculated using the wall-clock of the location, so
+// the daylight saving transitions do not shift the rotation instants.
+func (b *boundarySchedule) next(t time.Time) time.Time {
+	t = t.In(b.location)
+	year, month, day := t.Date()
+	switch b.boundary {
+	case Hourly:
+		return time.Date(year, month, day, t.Hour()+1, 0, 0, 0, b.location)
+	case Daily:
+		return time.Date(year, month, day+1, 0, 0, 0, 0, b.location)
+	case Weekly:
+		// time.Weekday starts from Sunday, the weeks start from Monday
+		return time.Date(year, month, day+7-(int(t.Weekday())+6)%7, 0, 0, 0, 0, b.location)
+	case Monthly:
+		return time.Date(year, month+1, 1, 0, 0, 0, 0, b.location)
+	}
+	return time.Time{}
+}
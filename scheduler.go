@@ -0,0 +1,134 @@
+package eidos
+
+import (
+	"time"
+)
+
+// task represents a periodic job of the logger, ex- the period based
+// rotation, the retention and the monitoring of the free disk space
+type task struct {
+	// name identifies the task
+	name string
+	// next returns the time of the next run of the task,
+	// after a run which was due at the provided time
+	next func(due, now time.Time) time.Time
+	// run performs the job of the task
+	run func()
+	// restart returns the time of the next run of the task, when the
+	// period is restarted by a manual or a size based rotation. The
+	// task is not affected by the restarts, if restart is nil.
+	restart func(now time.Time) time.Time
+	// due is the time of the next run of the task
+	due time.Time
+}
+
+// every returns the next function of a task, which runs once in every
+// interval. If a run has been missed by more than an interval, ex- the
+// previous run took longer than the interval, then the next run is
+// scheduled an interval after the current time.
+func every(interval time.Duration) func(due, now time.Time) time.Time {
+	return func(due, now time.Time) time.Time {
+		if next := due.Add(interval); next.After(now) {
+			return next
+		}
+		return now.Add(interval)
+	}
+}
+
+// jittered delays every run of the task by a random duration within the
+// RotationJitter window. The delay is added to the due time of the task, so
+// the scheduler keeps running the other tasks and watching the context in
+// the meantime, while the period of the task is kept on the undelayed times.
+func (l *Logger) jittered(t *task) *task {
+	if l.RotationOption.RotationJitter <= 0 {
+		return t
+	}
+	// scheduled is the undelayed time of the next run of the task
+	scheduled := t.due
+	next, restart := t.next, t.restart
+	t.due = scheduled.Add(l.jitter())
+	t.next = func(_, now time.Time) time.Time {
+		scheduled = next(scheduled, now)
+		return scheduled.Add(l.jitter())
+	}
+	if restart != nil {
+		t.restart = func(now time.Time) time.Time {
+			scheduled = restart(now)
+			return scheduled.Add(l.jitter())
+		}
+	}
+	return t
+}
+
+// schedule registers the task in the scheduler of the logger. The tasks are
+// registered before the scheduler is started.
+func (l *Logger) schedule(t *task) {
+	l.tasks = append(l.tasks, t)
+}
+
+// resetPeriod restarts the period of the period based rotation, so a log
+// file rotated manually or on reaching the maximum size is not rotated
+// again before a full period elapses
+func (l *Logger) resetPeriod() {
+	select {
+	case l.periodReset <- struct{}{}:
+	default:
+		// A reset is already pending
+	}
+}
+
+// runScheduler is the single daemon go-routine of the logger, which runs
+// all the periodic tasks one after another, till the logger is closed or
+// its context is cancelled. The rotated file names are passed to the
+// callback worker, so a slow callback does not delay the rotations.
+func (l *Logger) runScheduler() {
+	defer l.daemons.Done()
+
+	// The logger is closed, when its context is cancelled, so the
+	// log file can be opened by a new logger of the same process
+	defer l.Close()
+
+	// Starting the callback worker owned by the scheduler
+	l.daemons.Add(1)
+	go func() {
+		defer l.daemons.Done()
+		l.runCallbacks()
+	}()
+
+	if len(l.tasks) == 0 {
+		<-l.ctx.Done()
+		return
+	}
+
+	for {
+		// Waiting for the earliest task to be due
+		first := l.tasks[0]
+		for _, t := range l.tasks[1:] {
+			if t.due.Before(first.due) {
+				first = t
+			}
+		}
+
+		timer := time.NewTimer(first.due.Sub(l.RotationOption.now()))
+		select {
+		case <-timer.C:
+			// Running the earliest task and all the other tasks, which are due
+			for _, t := range l.tasks {
+				if t == first || !t.due.After(l.RotationOption.now()) {
+					t.run()
+					t.due = t.next(t.due, l.RotationOption.now())
+				}
+			}
+		case <-l.periodReset:
+			timer.Stop()
+			for _, t := range l.tasks {
+				if t.restart != nil {
+					t.due = t.restart(l.RotationOption.now())
+				}
+			}
+		case <-l.ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package eidos
+
+import (
+	"context"
+	"sync"
+)
+
+// lifecycle represents the state of the logger
+type lifecycle int
+
+const (
+	// lifecycleRunning accepts the writes and the rotations
+	lifecycleRunning lifecycle = iota
+	// lifecycleClosed rejects the writes and the rotations with the ErrClosed,
+	// it is entered by the first Close or Shutdown and never left
+	lifecycleClosed
+)
+
+// markClosed moves the logger to the closed state and closes the log file.
+// It returns false, if the logger was already closed.
+func (l *Logger) markClosed() (bool, error) {
+	// The summary and the partial line written on close may rotate the log file
+	defer l.runPendingRotations()
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.lifecycle == lifecycleClosed {
+		return false, nil
+	}
+
+	// Writing the summary of the collapsed lines and the held partial line, if any
+	if l.dedup != nil {
+		if summary := l.dedup.drain(nil); len(summary) > 0 {
+			_, _ = l.writeLocked(summary)
+		}
+	}
+	if l.partial != nil {
+		if pending := l.partial.drain(nil); len(pending) > 0 {
+			_, _ = l.writeLocked(pending)
+		}
+	}
+	l.lifecycle = lifecycleClosed
+	unregister(l)
+	return true, l.close()
+}
+
+// inflight counts the compressions, the callbacks and the deliveries
+// of the rotated log files, which have not finished yet
+type inflight struct {
+	mutex sync.Mutex
+	count int
+	idle  chan struct{}
+}
+
+// add accounts a started operation
+func (f *inflight) add() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.count == 0 {
+		f.idle = make(chan struct{})
+	}
+	f.count++
+}
+
+// done accounts a finished operation
+func (f *inflight) done() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.count--
+	if f.count == 0 {
+		close(f.idle)
+	}
+}
+
+// wait returns a channel, which is closed when all the operations are finished
+func (f *inflight) wait() <-chan struct{} {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.count == 0 {
+		idle := make(chan struct{})
+		close(idle)
+		return idle
+	}
+	return f.idle
+}
+
+// goTracked runs the function in a new go-routine,
+// which is awaited by the Shutdown
+func (l *Logger) goTracked(f func()) {
+	l.inflight.add()
+	go func() {
+		defer l.inflight.done()
+		f()
+	}()
+}
+
+// submitTracked queues the function in the compression
+// pool, the function is awaited by the Shutdown
+func (l *Logger) submitTracked(f func()) {
+	l.inflight.add()
+	submitCompression(func() {
+		defer l.inflight.done()
+		f()
+	})
+}
+
+// Shutdown closes the logger gracefully. It closes the log file and waits for
+// the pending compressions, the queued callbacks and the deliveries of the
+// rotated log files to finish, so the last rotated log file is not left half
+// compressed when the process exits. If the ctx is done before that, then the
+// long-running callbacks are interrupted and the error of the ctx is returned.
+// The daemon go-routines of the logger have exited, when it returns nil.
+// Calling the Shutdown on a closed logger only waits for the pending work.
+func (l *Logger) Shutdown(ctx context.Context) error {
+	_, err := l.markClosed()
+
+	select {
+	case <-l.inflight.wait():
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	// Interrupting the long-running callbacks, ex- the uploads,
+	// and waiting for the scheduler and the callback worker to exit
+	l.cancel()
+	daemons := make(chan struct{})
+	go func() {
+		l.daemons.Wait()
+		close(daemons)
+	}()
+	select {
+	case <-daemons:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	l.saveState()
+	return err
+}
@@ -0,0 +1,57 @@
+package eidos
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// sizeUnits maps the size units to their number of bytes. Like the Size
+// option, the units are powers of 1024, so "KB" and "KiB" are equivalent.
+var sizeUnits = map[string]int64{
+	"":    1,
+	"b":   1,
+	"k":   1 << 10,
+	"kb":  1 << 10,
+	"kib": 1 << 10,
+	"m":   1 << 20,
+	"mb":  1 << 20,
+	"mib": 1 << 20,
+	"g":   1 << 30,
+	"gb":  1 << 30,
+	"gib": 1 << 30,
+	"t":   1 << 40,
+	"tb":  1 << 40,
+	"tib": 1 << 40,
+}
+
+// ParseSize parses a human-readable size, ex- "512KB", "1.5GiB", "100 MB"
+// and returns the number of bytes. The units are case-insensitive and are
+// powers of 1024. A size without any unit is considered as bytes.
+func ParseSize(size string) (int64, error) {
+	s := strings.TrimSpace(size)
+	index := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if index == -1 {
+		index = len(s)
+	}
+
+	value, err := strconv.ParseFloat(s[:index], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+
+	unit, ok := sizeUnits[strings.ToLower(strings.TrimSpace(s[index:]))]
+	if !ok {
+		return 0, fmt.Errorf("invalid size unit in %q", size)
+	}
+
+	// The float64(math.MaxInt64) is rounded up to 2^63, which overflows the int64
+	bytes := value * float64(unit)
+	if bytes >= math.MaxInt64 {
+		return 0, fmt.Errorf("size %q is too large", size)
+	}
+	return int64(bytes), nil
+}
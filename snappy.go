@@ -0,0 +1,166 @@
+package eidos
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	// snappyChunkSize is the maximum size of the uncompressed data in a chunk
+	snappyChunkSize = 65536
+	// snappyHashLog is the number of bits of the hash of a 4 byte sequence
+	snappyHashLog = 14
+	// snappyMaxCopyLength is the maximum length of a copy with a 2 byte offset
+	snappyMaxCopyLength = 64
+	// snappySkipTrigger is the log2 of the number of the failed match searches
+	// after which the search advances by one more byte per search
+	snappySkipTrigger = 5
+)
+
+// snappyStreamIdentifier is the first chunk of every snappy framed stream
+var snappyStreamIdentifier = []byte{0xff, 0x06, 0x00, 0x00, 's', 'N', 'a', 'P', 'p', 'Y'}
+
+// snappyCRCTable is the Castagnoli table used for the chunk checksums
+var snappyCRCTable = crc32.MakeTable(crc32.Castagnoli)
+
+// snappyWriter compresses the data in the snappy framing format
+type snappyWriter struct {
+	w             io.Writer
+	buffer        []byte
+	compressed    []byte
+	headerWritten bool
+}
+
+// newSnappyWriter returns a snappyWriter writing the stream to w
+func newSnappyWriter(w io.Writer) *snappyWriter {
+	return &snappyWriter{w: w}
+}
+
+// Write buffers the data and writes the complete chunks
+func (s *snappyWriter) Write(p []byte) (int, error) {
+	s.buffer = append(s.buffer, p...)
+	for len(s.buffer) >= snappyChunkSize {
+		if err := s.writeChunk(s.buffer[:snappyChunkSize]); err != nil {
+			return 0, err
+		}
+		s.buffer = s.buffer[snappyChunkSize:]
+	}
+	return len(p), nil
+}
+
+// Close writes the remaining data
+func (s *snappyWriter) Close() error {
+	if err := s.writeHeader(); err != nil {
+		return err
+	}
+	if len(s.buffer) > 0 {
+		if err := s.writeChunk(s.buffer); err != nil {
+			return err
+		}
+		s.buffer = nil
+	}
+	return nil
+}
+
+// writeHeader writes the stream identifier, if it has not been written yet
+func (s *snappyWriter) writeHeader() error {
+	if s.headerWritten {
+		return nil
+	}
+	s.headerWritten = true
+	_, err := s.w.Write(snappyStreamIdentifier)
+	return err
+}
+
+// writeChunk compresses and writes a chunk. If the data can not be
+// compressed, then the chunk is stored uncompressed.
+func (s *snappyWriter) writeChunk(chunk []byte) error {
+	if err := s.writeHeader(); err != nil {
+		return err
+	}
+
+	chunkType := byte(0x00)
+	s.compressed = snappyCompressBlock(s.compressed[:0], chunk)
+	compressed := s.compressed
+	if len(compressed) >= len(chunk) {
+		chunkType = 0x01
+		compressed = chunk
+	}
+
+	// The chunk checksum is the masked crc32c of the uncompressed data
+	checksum := crc32.Checksum(chunk, snappyCRCTable)
+	checksum = (checksum>>15 | checksum<<17) + 0xa282ead8
+
+	length := len(compressed) + 4
+	header := []byte{chunkType, byte(length), byte(length >> 8), byte(length >> 16), 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(header[4:], checksum)
+	if _, err := s.w.Write(header); err != nil {
+		return err
+	}
+	_, err := s.w.Write(compressed)
+	return err
+}
+
+// snappyCompressBlock appends the block compressed in the snappy block format
+// to dst using a greedy match search over a hash table of 4 byte sequences.
+// The block should not be larger than snappyChunkSize, so every offset
+// fits into a copy with a 2 byte offset.
+func snappyCompressBlock(dst, src []byte) []byte {
+	// The block starts with the uncompressed length as a varint
+	var length [binary.MaxVarintLen64]byte
+	dst = append(dst, length[:binary.PutUvarint(length[:], uint64(len(src)))]...)
+	// table holds the position+1 of the last occurrence of a hash
+	pooledTable := snappyTables.Get().(*[]int32)
+	defer snappyTables.Put(pooledTable)
+	table := *pooledTable
+	for i := range table {
+		table[i] = 0
+	}
+
+	anchor, i, misses := 0, 0, 0
+	for i+4 <= len(src) {
+		sequence := binary.LittleEndian.Uint32(src[i:])
+		hash := (sequence * 0x1e35a7bd) >> (32 - snappyHashLog)
+		reference := int(table[hash]) - 1
+		table[hash] = int32(i + 1)
+
+		if reference < 0 || binary.LittleEndian.Uint32(src[reference:]) != sequence {
+			// The search skips faster through the incompressible data
+			i += 1 + misses>>snappySkipTrigger
+			misses++
+			continue
+		}
+
+		// Extending the match
+		end := extendMatch(src, reference+4, i+4, len(src))
+		dst = snappyAppendLiteral(dst, src[anchor:i])
+		for length := end - i; length > 0; length -= snappyMaxCopyLength {
+			dst = snappyAppendCopy(dst, i-reference, min(length, snappyMaxCopyLength))
+		}
+		i, anchor, misses = end, end, 0
+	}
+	return snappyAppendLiteral(dst, src[anchor:])
+}
+
+// snappyAppendLiteral appends a literal element
+func snappyAppendLiteral(dst, literal []byte) []byte {
+	if len(literal) == 0 {
+		return dst
+	}
+	n := len(literal) - 1
+	switch {
+	case n < 60:
+		dst = append(dst, byte(n<<2))
+	case n < 1<<8:
+		dst = append(dst, 60<<2, byte(n))
+	default:
+		dst = append(dst, 61<<2, byte(n), byte(n>>8))
+	}
+	return append(dst, literal...)
+}
+
+// snappyAppendCopy appends a copy element with a 2 byte offset
+func snappyAppendCopy(dst []byte, offset, length int) []byte {
+	return append(dst, byte((length-1)<<2|0x02), byte(offset), byte(offset>>8))
+}
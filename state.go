@@ -0,0 +1,131 @@
+package eidos
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// state represents the rotation metadata of the logger,
+// which is persisted across the restarts of the process
+type state struct {
+	// NextRotation is the deadline of the next period based rotation
+	NextRotation time.Time `json:"next_rotation,omitempty"`
+	// LastRotation is the time of the last rotation of the log file
+	LastRotation time.Time `json:"last_rotation,omitempty"`
+	// Size is the size of the log file
+	Size int64 `json:"size"`
+	// Backups holds the paths of the rotated log files, oldest first
+	Backups []string `json:"backups"`
+	// PendingCallbacks holds the paths of the rotated log
+	// files, which are not delivered to the Callback.Deliver yet
+	PendingCallbacks []string `json:"pending_callbacks,omitempty"`
+}
+
+// stateFileName returns the name of the state file of the log file
+func stateFileName(filename string) string {
+	return filename + ".state"
+}
+
+// readState reads the state file of the log file. If the state
+// file does not exist or is corrupted, an empty state is returned.
+func readState(filename string) state {
+	var s state
+	if data, err := ioutil.ReadFile(stateFileName(filename)); err == nil {
+		_ = json.Unmarshal(data, &s)
+	}
+	return s
+}
+
+// writeState writes the state file of the log file. The state is written
+// to a temporary file, which is then renamed, so a crash never leaves a
+// partially written state file behind.
+func writeState(filename string, s state) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	tempFileName := stateFileName(filename) + ".tmp"
+	if err := ioutil.WriteFile(tempFileName, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tempFileName, stateFileName(filename)); err != nil {
+		_ = os.Remove(tempFileName)
+		return err
+	}
+	return nil
+}
+
+// updateState applies the update on the state file of the log file
+func (l *Logger) updateState(update func(s *state)) {
+	l.stateMutex.Lock()
+	defer l.stateMutex.Unlock()
+
+	s := readState(l.Filename)
+	update(&s)
+	if err := writeState(l.Filename, s); err != nil {
+		l.reportError(err)
+	}
+}
+
+// saveState writes the rotation metadata (last rotation time, size of the log
+// file and the backup inventory) to the state file, if MaintainState is enabled
+func (l *Logger) saveState() {
+	if !l.RotationOption.MaintainState {
+		return
+	}
+
+	l.mutex.Lock()
+	lastRotation, size := l.lastRotation, l.currentSize()
+	l.mutex.Unlock()
+
+	var backups []string
+	if files, err := backupFiles(l.Filename, l.RotationOption); err == nil {
+		for _, f := range files {
+			backups = append(backups, f.path)
+		}
+	}
+
+	l.updateState(func(s *state) {
+		s.LastRotation = lastRotation
+		s.Size = size
+		s.Backups = backups
+	})
+}
+
+// persistedPeriodTask returns the task, which rotates the log file once in
+// every period. The deadline of the next rotation is persisted in the state
+// file, so the log file is rotated once in every period of wall-clock time,
+// even if the process restarts. A deadline which has passed while the process
+// was not running triggers an immediate rotation. The deadline is postponed
+// by a period after a manual or a size based rotation.
+func (l *Logger) persistedPeriodTask(period time.Duration) *task {
+	// persist writes the deadline of the next rotation to the state file
+	persist := func(deadline time.Time) time.Time {
+		l.updateState(func(s *state) {
+			s.NextRotation = deadline
+		})
+		return deadline
+	}
+
+	deadline := readState(l.Filename).NextRotation
+	if deadline.IsZero() {
+		deadline = persist(l.RotationOption.now().Add(period))
+	}
+
+	return &task{
+		name: "rotation",
+		// If the deadline has been missed by more than a period,
+		// then the next deadline is calculated from the current time
+		next: func(due, now time.Time) time.Time {
+			return persist(every(period)(due, now))
+		},
+		run: l.timedRotate,
+		restart: func(now time.Time) time.Time {
+			return persist(now.Add(period))
+		},
+		due: deadline,
+	}
+}
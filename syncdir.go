@@ -0,0 +1,20 @@
+//go:build !windows
+// +build !windows
+
+package eidos
+
+import "os"
+
+// syncDir commits the entries of the directory to the disk, so a renamed
+// or a newly created file is not lost on a power failure
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		d.Close()
+		return err
+	}
+	return d.Close()
+}
@@ -0,0 +1,6 @@
+package eidos
+
+// syncDir is a no-op on windows, as the directories can not be synced
+func syncDir(_ string) error {
+	return nil
+}
@@ -0,0 +1,76 @@
+package eidos
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// WebhookNotifier returns a Callback.Rotated function, which POSTs the rotation
+// event in json format to the url with the headers, ex- an authorization
+// header, so the downstream pipelines can be triggered on the rotations. A
+// failed request or a non 2xx response is retried with a backoff, which is
+// doubled after every failure. The event is dropped after the retries and the
+// failure is passed to the onError function, if any, ex- the Callback.OnError.
+// The pending request and the backoff are interrupted, when the ctx is done,
+// ex- the ctx passed to the NewWithContext.
+func WebhookNotifier(ctx context.Context, url string, headers map[string]string, onError func(error)) func(RotationEvent) {
+	client := &http.Client{Timeout: webhookTimeout}
+	return func(event RotationEvent) {
+		err := notifyWebhook(ctx, client, url, headers, event)
+		if err != nil && onError != nil {
+			onError(fmt.Errorf("failed to notify webhook of %s: %v", event.rotatedFile(), err))
+		}
+	}
+}
+
+// notifyWebhook POSTs the rotation event to the url, retrying the failures
+// with the backoff till the retries are exhausted or the ctx is done
+func notifyWebhook(ctx context.Context, client *http.Client, url string, headers map[string]string, event RotationEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	backoff := webhookBackoff
+	for attempt := 0; ; attempt++ {
+		if err = postWebhook(ctx, client, url, headers, body); err == nil || attempt == webhookRetries {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+			backoff *= 2
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// postWebhook POSTs the json body to the url with the headers
+func postWebhook(ctx context.Context, client *http.Client, url string, headers map[string]string, body []byte) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %v", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to post webhook: %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", response.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,938 @@
+package eidos
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"math"
+	"math/bits"
+)
+
+const (
+	// xzDictionarySize is the size of the dictionary, the input is
+	// compressed in the independent segments of the same size
+	xzDictionarySize = 4 << 20
+	// xzDictionaryProperty encodes the dictionary size, 2^(20/2+12)
+	xzDictionaryProperty = 20
+	// lzmaProperties encodes lc=3, lp=0 and pb=2, (pb*5+lp)*9+lc
+	lzmaProperties = 93
+	// lzma2MaxUnpackedSize and lzma2MaxPackedSize are the maximum
+	// sizes of the uncompressed and the compressed data of a chunk
+	lzma2MaxUnpackedSize = 1 << 21
+	lzma2MaxPackedSize   = 1 << 16
+	// lzma2MaxUncompressedChunkSize is the maximum size of an uncompressed chunk
+	lzma2MaxUncompressedChunkSize = 1 << 16
+
+	lzmaMinMatch   = 4
+	lzmaMaxMatch   = 273
+	lzmaHashLog    = 20
+	lzmaChainDepth = 48
+	lzmaNiceLength = 64
+	// lzmaOptimumSize is the maximum number of positions of an optimal parse
+	lzmaOptimumSize = 1 << 12
+	// lzmaPriceInterval is the number of matches after which the prices are updated
+	lzmaPriceInterval = 128
+	// lzmaBitPrice is the price of a bit with the probability of one half
+	lzmaBitPrice     = 16
+	lzmaStates       = 12
+	lzmaPosStates    = 4
+	lzmaEndPosModel  = 14
+	lzmaFullDistance = 128
+)
+
+// xzStreamFlags selects the CRC32 check of the uncompressed data
+var xzStreamFlags = []byte{0x00, 0x01}
+
+// xzWriter compresses the data in the xz format, the stream holds a single
+// block of lzma2 chunks. The input is buffered and compressed in segments
+// of the dictionary size, so the memory usage is bounded.
+type xzWriter struct {
+	w                io.Writer
+	segment          []byte
+	headerWritten    bool
+	compressedSize   int64
+	uncompressedSize int64
+	check            uint32
+}
+
+// newXZWriter returns a xzWriter writing the stream to w
+func newXZWriter(w io.Writer) *xzWriter {
+	return &xzWriter{w: w}
+}
+
+// Write buffers the data and compresses the complete segments
+func (x *xzWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		size := min(len(p), xzDictionarySize-len(x.segment))
+		x.segment = append(x.segment, p[:size]...)
+		p = p[size:]
+		if len(x.segment) == xzDictionarySize {
+			if err := x.writeSegment(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Close compresses the remaining data and writes the index and the footer
+func (x *xzWriter) Close() error {
+	if err := x.writeSegment(); err != nil {
+		return err
+	}
+
+	// The end of the lzma2 chunks, followed by the block padding and the check
+	x.compressedSize++
+	block := make([]byte, 1+(4-x.compressedSize%4)%4)
+	block = appendUint32(block, x.check)
+	if _, err := x.w.Write(block); err != nil {
+		return err
+	}
+
+	// The index holds a single record of the unpadded and the uncompressed block size
+	unpaddedSize := int64(len(xzBlockHeader())) + x.compressedSize + 4
+	index := []byte{0x00, 0x01}
+	index = appendUvarint(index, uint64(unpaddedSize))
+	index = appendUvarint(index, uint64(x.uncompressedSize))
+	for len(index)%4 != 0 {
+		index = append(index, 0x00)
+	}
+	index = appendUint32(index, crc32.ChecksumIEEE(index))
+
+	footer := appendUint32(nil, uint32(len(index)/4-1))
+	footer = append(footer, xzStreamFlags...)
+	footer = append(appendUint32(nil, crc32.ChecksumIEEE(footer)), footer...)
+	footer = append(footer, 'Y', 'Z')
+
+	_, err := x.w.Write(append(index, footer...))
+	return err
+}
+
+// writeHeader writes the stream header and the block header,
+// if they have not been written yet
+func (x *xzWriter) writeHeader() error {
+	if x.headerWritten {
+		return nil
+	}
+	x.headerWritten = true
+
+	header := []byte{0xFD, '7', 'z', 'X', 'Z', 0x00}
+	header = append(header, xzStreamFlags...)
+	header = appendUint32(header, crc32.ChecksumIEEE(xzStreamFlags))
+	_, err := x.w.Write(append(header, xzBlockHeader()...))
+	return err
+}
+
+// xzBlockHeader returns the block header with a single lzma2 filter
+func xzBlockHeader() []byte {
+	// Header size, block flags, lzma2 filter id, size of the filter properties,
+	// dictionary size property and the padding to a multiple of four bytes
+	header := []byte{0x02, 0x00, 0x21, 0x01, xzDictionaryProperty, 0x00, 0x00, 0x00}
+	return appendUint32(header, crc32.ChecksumIEEE(header))
+}
+
+// writeSegment compresses the buffered segment into the lzma2 chunks
+func (x *xzWriter) writeSegment() error {
+	if err := x.writeHeader(); err != nil {
+		return err
+	}
+	if len(x.segment) == 0 {
+		return nil
+	}
+
+	chunks := lzma2Compress(x.segment)
+	if _, err := x.w.Write(chunks); err != nil {
+		return err
+	}
+	x.compressedSize += int64(len(chunks))
+	x.uncompressedSize += int64(len(x.segment))
+	x.check = crc32.Update(x.check, crc32.IEEETable, x.segment)
+	x.segment = x.segment[:0]
+	return nil
+}
+
+// lzma2Compress compresses the data into the lzma2 chunks. The dictionary
+// is reset at the start of the data, and the state is reset at the start
+// of every chunk. If a chunk can not be compressed, then it is stored in
+// the uncompressed chunks.
+func lzma2Compress(data []byte) []byte {
+	var out []byte
+	encoder := newLZMAEncoder(data)
+	dictionaryReset, propertiesNeeded := true, true
+
+	for start := 0; start < len(data); {
+		compressed, end := encoder.encodeChunk(start)
+		if len(compressed) < end-start {
+			control := byte(0xA0)
+			switch {
+			case dictionaryReset:
+				control = 0xE0
+			case propertiesNeeded:
+				control = 0xC0
+			}
+			unpacked, packed := end-start-1, len(compressed)-1
+			out = append(out, control|byte(unpacked>>16), byte(unpacked>>8), byte(unpacked), byte(packed>>8), byte(packed))
+			if control >= 0xC0 {
+				out = append(out, lzmaProperties)
+			}
+			out = append(out, compressed...)
+			dictionaryReset, propertiesNeeded = false, false
+		} else {
+			for chunkStart := start; chunkStart < end; chunkStart += lzma2MaxUncompressedChunkSize {
+				chunk := data[chunkStart:min(end, chunkStart+lzma2MaxUncompressedChunkSize)]
+				control := byte(0x02)
+				if dictionaryReset {
+					// The properties are needed again after a dictionary reset
+					control = 0x01
+					dictionaryReset, propertiesNeeded = false, true
+				}
+				out = append(out, control, byte((len(chunk)-1)>>8), byte(len(chunk)-1))
+				out = append(out, chunk...)
+			}
+		}
+		start = end
+	}
+	return out
+}
+
+// appendUint32 appends the little endian encoding of the value
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+// appendUvarint appends the varint encoding of the value
+func appendUvarint(b []byte, v uint64) []byte {
+	for ; v >= 0x80; v >>= 7 {
+		b = append(b, byte(v)|0x80)
+	}
+	return append(b, byte(v))
+}
+
+// lzmaEncoder encodes the data with an optimal parse, the cheapest sequence
+// of the literals, the matches and the repeated matches is searched with the
+// prices of the current probabilities. The matches are found over the hash
+// chains of 4 byte sequences.
+type lzmaEncoder struct {
+	data  []byte
+	head  []int32
+	chain []int32
+	// inserted is the position till which the hash chains are updated
+	inserted int
+	// matches are the matches found at the position matchesPos
+	matches    []lzmaMatch
+	matchesPos int
+	// nodes are the positions of the optimal parse, till the end position
+	nodes []lzmaNode
+	end   int
+	path  []lzmaNode
+
+	rc    rangeEncoder
+	state int
+	// reps are the distances of the last four matches
+	reps [4]int
+
+	isMatch    [lzmaStates][lzmaPosStates]uint16
+	isRep      [lzmaStates]uint16
+	isRepG0    [lzmaStates]uint16
+	isRepG1    [lzmaStates]uint16
+	isRepG2    [lzmaStates]uint16
+	isRep0Long [lzmaStates][lzmaPosStates]uint16
+	literal    [0x300 << 3]uint16
+	length     lengthEncoder
+	repLength  lengthEncoder
+	posSlot    [4][1 << 6]uint16
+	// posSpecial has an additional leading element, as the bit trees
+	// of the distance slots start one element before each other
+	posSpecial [1 + lzmaFullDistance - lzmaEndPosModel]uint16
+	posAlign   [1 << 4]uint16
+
+	// prices are updated after every lzmaPriceInterval matches, the
+	// literals are priced with the current probabilities
+	prices    lzmaPrices
+	pricesAge int
+}
+
+// lzmaMatch is a match found at a position
+type lzmaMatch struct {
+	length, distance int
+}
+
+// lzmaNode is a position of the optimal parse, it holds the price of the
+// cheapest symbols reaching the position and the last of the symbols
+type lzmaNode struct {
+	price  int
+	prev   int
+	length int
+	symbol int
+	// distance is the distance of a match, or the index of the distance
+	// of a repeated match
+	distance int
+	// state and reps are set once the node is reached by the parse
+	state int
+	reps  [4]int
+}
+
+// The symbols of the lzma stream
+const (
+	lzmaSymbolLiteral = iota
+	lzmaSymbolMatch
+	lzmaSymbolRep
+	lzmaSymbolShortRep
+)
+
+// lzmaPrices caches the prices of the lengths and the distances
+type lzmaPrices struct {
+	length    [lzmaPosStates][lzmaMaxMatch - 1]int
+	repLength [lzmaPosStates][lzmaMaxMatch - 1]int
+	slot      [4][1 << 6]int
+	distance  [4][lzmaFullDistance]int
+	align     [1 << 4]int
+}
+
+// newLZMAEncoder returns a lzmaEncoder for the data
+func newLZMAEncoder(data []byte) *lzmaEncoder {
+	e := &lzmaEncoder{
+		data:       data,
+		head:       make([]int32, 1<<lzmaHashLog),
+		chain:      make([]int32, len(data)),
+		matchesPos: -1,
+		nodes:      make([]lzmaNode, lzmaOptimumSize+lzmaMaxMatch+1),
+	}
+	for i := range e.head {
+		e.head[i] = -1
+	}
+	return e
+}
+
+// reset resets the state and the probabilities of the encoder
+func (e *lzmaEncoder) reset() {
+	e.state, e.reps = 0, [4]int{1, 1, 1, 1}
+	e.rc = rangeEncoder{rng: 0xFFFFFFFF, cacheSize: 1}
+	resetProbabilities := func(probabilities []uint16) {
+		for i := range probabilities {
+			probabilities[i] = 1024
+		}
+	}
+	for i := range e.isMatch {
+		resetProbabilities(e.isMatch[i][:])
+		resetProbabilities(e.isRep0Long[i][:])
+	}
+	resetProbabilities(e.isRep[:])
+	resetProbabilities(e.isRepG0[:])
+	resetProbabilities(e.isRepG1[:])
+	resetProbabilities(e.isRepG2[:])
+	resetProbabilities(e.literal[:])
+	for i := range e.posSlot {
+		resetProbabilities(e.posSlot[i][:])
+	}
+	resetProbabilities(e.posSpecial[:])
+	resetProbabilities(e.posAlign[:])
+	for _, length := range []*lengthEncoder{&e.length, &e.repLength} {
+		resetProbabilities(length.choice[:])
+		for i := range length.low {
+			resetProbabilities(length.low[i][:])
+			resetProbabilities(length.mid[i][:])
+		}
+		resetProbabilities(length.high[:])
+	}
+	e.updatePrices()
+}
+
+// encodeChunk encodes the data from the start position till either the
+// compressed or the uncompressed size limit of a chunk is reached. It
+// returns the compressed data and the end position of the chunk.
+func (e *lzmaEncoder) encodeChunk(start int) ([]byte, int) {
+	e.reset()
+	pos := start
+	for pos < len(e.data) {
+		for _, node := range e.optimum(pos) {
+			if pos-start+node.length > lzma2MaxUnpackedSize || e.rc.pending() >= lzma2MaxPackedSize-64 {
+				return e.rc.flush(), pos
+			}
+			e.encodeSymbol(pos, node)
+			pos += node.length
+		}
+	}
+	return e.rc.flush(), pos
+}
+
+// optimum returns the cheapest symbols from the position. The parse ends
+// at a position which no symbol reaches over, after lzmaOptimumSize
+// positions or before a match of the nice length, which is taken as is.
+func (e *lzmaEncoder) optimum(pos int) []lzmaNode {
+	if e.pricesAge >= lzmaPriceInterval {
+		e.updatePrices()
+	}
+	e.nodes[0] = lzmaNode{state: e.state, reps: e.reps}
+	e.end = 0
+
+	cur := 0
+	for ; cur < lzmaOptimumSize && pos+cur < len(e.data); cur++ {
+		if cur > 0 && cur == e.end {
+			break
+		}
+		node := &e.nodes[cur]
+		if cur > 0 {
+			prev := &e.nodes[node.prev]
+			node.state = lzmaNextState(prev.state, node.symbol)
+			node.reps = lzmaNextReps(prev.reps, node.symbol, node.distance)
+		}
+
+		p := pos + cur
+		maxLength := min(lzmaMaxMatch, len(e.data)-p)
+		var repLengths [4]int
+		longestRep := 0
+		for i, distance := range node.reps {
+			if distance > p {
+				continue
+			}
+			length := 0
+			for length < maxLength && e.data[p+length] == e.data[p+length-distance] {
+				length++
+			}
+			repLengths[i] = length
+			if length > repLengths[longestRep] {
+				longestRep = i
+			}
+		}
+		matches := e.findMatches(p)
+		longestMatch := lzmaMatch{}
+		if len(matches) > 0 {
+			longestMatch = matches[len(matches)-1]
+		}
+
+		if repLengths[longestRep] >= lzmaNiceLength || longestMatch.length >= lzmaNiceLength {
+			if cur > 0 {
+				break
+			}
+			if repLengths[longestRep] >= longestMatch.length {
+				return append(e.path[:0], lzmaNode{length: repLengths[longestRep], symbol: lzmaSymbolRep, distance: longestRep})
+			}
+			return append(e.path[:0], lzmaNode{length: longestMatch.length, symbol: lzmaSymbolMatch, distance: longestMatch.distance})
+		}
+
+		posState := p & (lzmaPosStates - 1)
+		literalPrice := node.price + bitPrice(e.isMatch[node.state][posState], 0) + e.literalPrice(p, node.state, node.reps[0])
+		e.relax(cur, 1, literalPrice, lzmaSymbolLiteral, 0)
+
+		matchPrice := node.price + bitPrice(e.isMatch[node.state][posState], 1)
+		repPrice := matchPrice + bitPrice(e.isRep[node.state], 1)
+		if node.reps[0] <= p && e.data[p] == e.data[p-node.reps[0]] {
+			price := repPrice + bitPrice(e.isRepG0[node.state], 0) + bitPrice(e.isRep0Long[node.state][posState], 0)
+			e.relax(cur, 1, price, lzmaSymbolShortRep, 0)
+		}
+		for i, length := range repLengths {
+			price := repPrice + e.repIndexPrice(i, node.state, posState)
+			for ; length >= 2; length-- {
+				e.relax(cur, length, price+e.prices.repLength[posState][length-2], lzmaSymbolRep, i)
+			}
+		}
+
+		price := matchPrice + bitPrice(e.isRep[node.state], 0)
+		length := 2
+		for _, match := range matches {
+			for ; length <= match.length; length++ {
+				lengthPrice := e.prices.length[posState][length-2] + e.distancePrice(match.distance-1, length)
+				e.relax(cur, length, price+lengthPrice, lzmaSymbolMatch, match.distance)
+			}
+		}
+	}
+
+	e.path = e.path[:0]
+	for i := cur; i > 0; i = e.nodes[i].prev {
+		e.path = append(e.path, e.nodes[i])
+	}
+	for i, j := 0, len(e.path)-1; i < j; i, j = i+1, j-1 {
+		e.path[i], e.path[j] = e.path[j], e.path[i]
+	}
+	return e.path
+}
+
+// relax records the symbol from the node, if it reaches the
+// node at the end of the symbol cheaper than the known symbols
+func (e *lzmaEncoder) relax(from, length, price, symbol, distance int) {
+	to := from + length
+	for ; e.end < to; e.end++ {
+		e.nodes[e.end+1].price = math.MaxInt32
+	}
+	if price < e.nodes[to].price {
+		e.nodes[to] = lzmaNode{price: price, prev: from, length: length, symbol: symbol, distance: distance}
+	}
+}
+
+// lzmaNextState returns the state after the symbol
+func lzmaNextState(state, symbol int) int {
+	switch symbol {
+	case lzmaSymbolLiteral:
+		switch {
+		case state < 4:
+			return 0
+		case state < 10:
+			return state - 3
+		default:
+			return state - 6
+		}
+	case lzmaSymbolMatch:
+		if state < 7 {
+			return 7
+		}
+		return 10
+	case lzmaSymbolRep:
+		if state < 7 {
+			return 8
+		}
+		return 11
+	default:
+		if state < 7 {
+			return 9
+		}
+		return 11
+	}
+}
+
+// lzmaNextReps returns the distances of the last matches after the symbol
+func lzmaNextReps(reps [4]int, symbol, distance int) [4]int {
+	switch symbol {
+	case lzmaSymbolMatch:
+		return [4]int{distance, reps[0], reps[1], reps[2]}
+	case lzmaSymbolRep:
+		rep := reps[distance]
+		copy(reps[1:distance+1], reps[:distance])
+		reps[0] = rep
+	}
+	return reps
+}
+
+// findMatches returns the matches at the position in the increasing order
+// of the length, each with the smallest distance found for the length.
+// The hash chains are updated till the position.
+func (e *lzmaEncoder) findMatches(pos int) []lzmaMatch {
+	if pos == e.matchesPos {
+		return e.matches
+	}
+	for ; e.inserted < pos; e.inserted++ {
+		e.insert(e.inserted)
+	}
+
+	e.matches, e.matchesPos = e.matches[:0], pos
+	if pos+lzmaMinMatch <= len(e.data) {
+		maxLength := min(lzmaMaxMatch, len(e.data)-pos)
+		bestLength := lzmaMinMatch - 1
+		candidate := int(e.head[e.hash(pos)])
+		for depth := 0; candidate >= 0 && depth < lzmaChainDepth; candidate = int(e.chain[candidate]) {
+			// The chains hold the positions inserted by an earlier parse
+			if candidate >= pos {
+				continue
+			}
+			depth++
+			// Skipping the candidates which can not be longer than the best match
+			if e.data[candidate+bestLength] != e.data[pos+bestLength] {
+				continue
+			}
+			length := 0
+			for length < maxLength && e.data[candidate+length] == e.data[pos+length] {
+				length++
+			}
+			if length > bestLength {
+				bestLength = length
+				e.matches = append(e.matches, lzmaMatch{length: length, distance: pos - candidate})
+				if length >= lzmaNiceLength || length == maxLength {
+					break
+				}
+			}
+		}
+	}
+
+	if e.inserted == pos {
+		e.insert(pos)
+		e.inserted++
+	}
+	return e.matches
+}
+
+// insert inserts the position into the hash chains
+func (e *lzmaEncoder) insert(pos int) {
+	if pos+lzmaMinMatch <= len(e.data) {
+		hash := e.hash(pos)
+		e.chain[pos] = e.head[hash]
+		e.head[hash] = int32(pos)
+	}
+}
+
+// hash returns the hash of the 4 byte sequence at the position
+func (e *lzmaEncoder) hash(pos int) uint32 {
+	return (binary.LittleEndian.Uint32(e.data[pos:]) * 2654435761) >> (32 - lzmaHashLog)
+}
+
+// encodeSymbol encodes the symbol at the position
+func (e *lzmaEncoder) encodeSymbol(pos int, node lzmaNode) {
+	posState := pos & (lzmaPosStates - 1)
+	if node.symbol == lzmaSymbolLiteral {
+		e.rc.encodeBit(&e.isMatch[e.state][posState], 0)
+		e.encodeLiteral(pos)
+	} else {
+		e.rc.encodeBit(&e.isMatch[e.state][posState], 1)
+		e.rc.encodeBit(&e.isRep[e.state], boolBit(node.symbol != lzmaSymbolMatch))
+		switch node.symbol {
+		case lzmaSymbolMatch:
+			e.length.encode(&e.rc, node.length-2, posState)
+			e.encodeDistance(node.distance-1, node.length)
+		case lzmaSymbolShortRep:
+			e.rc.encodeBit(&e.isRepG0[e.state], 0)
+			e.rc.encodeBit(&e.isRep0Long[e.state][posState], 0)
+		default:
+			e.encodeRepIndex(node.distance, posState)
+			e.repLength.encode(&e.rc, node.length-2, posState)
+		}
+		e.pricesAge++
+	}
+	e.state = lzmaNextState(e.state, node.symbol)
+	e.reps = lzmaNextReps(e.reps, node.symbol, node.distance)
+}
+
+// boolBit returns the bit of the condition
+func boolBit(condition bool) uint32 {
+	if condition {
+		return 1
+	}
+	return 0
+}
+
+// literalProbabilities returns the probabilities of the literal at the position
+func (e *lzmaEncoder) literalProbabilities(pos int) []uint16 {
+	previous := 0
+	if pos > 0 {
+		previous = int(e.data[pos-1])
+	}
+	return e.literal[0x300*(previous>>5):]
+}
+
+// encodeLiteral encodes the byte at the position as a literal. After a
+// match, the literal is encoded relative to the byte at the match distance.
+func (e *lzmaEncoder) encodeLiteral(pos int) {
+	probabilities := e.literalProbabilities(pos)
+	symbol := uint32(e.data[pos]) | 0x100
+
+	if e.state < 7 {
+		for ; symbol < 0x10000; symbol <<= 1 {
+			e.rc.encodeBit(&probabilities[symbol>>8], (symbol>>7)&1)
+		}
+		return
+	}
+
+	matchByte := uint32(e.data[pos-e.reps[0]]) << 1
+	offset := uint32(0x100)
+	for ; symbol < 0x10000; symbol <<= 1 {
+		matchBit := matchByte & offset
+		matchByte <<= 1
+		bit := (symbol >> 7) & 1
+		e.rc.encodeBit(&probabilities[offset+matchBit+(symbol>>8)], bit)
+		if bit == 1 {
+			offset = matchBit
+		} else {
+			offset ^= matchBit
+		}
+	}
+}
+
+// literalPrice returns the price of the byte at the position as a literal
+func (e *lzmaEncoder) literalPrice(pos, state, rep0 int) int {
+	probabilities := e.literalProbabilities(pos)
+	symbol := uint32(e.data[pos]) | 0x100
+	price := 0
+
+	if state < 7 {
+		for ; symbol < 0x10000; symbol <<= 1 {
+			price += bitPrice(probabilities[symbol>>8], (symbol>>7)&1)
+		}
+		return price
+	}
+
+	matchByte := uint32(e.data[pos-rep0]) << 1
+	offset := uint32(0x100)
+	for ; symbol < 0x10000; symbol <<= 1 {
+		matchBit := matchByte & offset
+		matchByte <<= 1
+		bit := (symbol >> 7) & 1
+		price += bitPrice(probabilities[offset+matchBit+(symbol>>8)], bit)
+		if bit == 1 {
+			offset = matchBit
+		} else {
+			offset ^= matchBit
+		}
+	}
+	return price
+}
+
+// encodeRepIndex encodes the index of the distance of a repeated match
+func (e *lzmaEncoder) encodeRepIndex(index, posState int) {
+	if index == 0 {
+		e.rc.encodeBit(&e.isRepG0[e.state], 0)
+		e.rc.encodeBit(&e.isRep0Long[e.state][posState], 1)
+		return
+	}
+	e.rc.encodeBit(&e.isRepG0[e.state], 1)
+	if index == 1 {
+		e.rc.encodeBit(&e.isRepG1[e.state], 0)
+		return
+	}
+	e.rc.encodeBit(&e.isRepG1[e.state], 1)
+	e.rc.encodeBit(&e.isRepG2[e.state], uint32(index-2))
+}
+
+// repIndexPrice returns the price of the index of the distance of a repeated match
+func (e *lzmaEncoder) repIndexPrice(index, state, posState int) int {
+	if index == 0 {
+		return bitPrice(e.isRepG0[state], 0) + bitPrice(e.isRep0Long[state][posState], 1)
+	}
+	price := bitPrice(e.isRepG0[state], 1)
+	if index == 1 {
+		return price + bitPrice(e.isRepG1[state], 0)
+	}
+	return price + bitPrice(e.isRepG1[state], 1) + bitPrice(e.isRepG2[state], uint32(index-2))
+}
+
+// encodeDistance encodes the distance-1 of a match
+func (e *lzmaEncoder) encodeDistance(distance, length int) {
+	slot := distanceSlot(distance)
+	e.rc.encodeBitTree(e.posSlot[min(length-2, 3)][:], 6, uint32(slot))
+	if slot < 4 {
+		return
+	}
+
+	footerBits := uint(slot>>1) - 1
+	base := (2 | slot&1) << footerBits
+	reduced := uint32(distance - base)
+	if slot < lzmaEndPosModel {
+		e.rc.encodeReverseBitTree(e.posSpecial[base-slot:], footerBits, reduced)
+		return
+	}
+	e.rc.encodeDirectBits(reduced>>4, footerBits-4)
+	e.rc.encodeReverseBitTree(e.posAlign[:], 4, reduced&0x0f)
+}
+
+// distancePrice returns the price of the distance-1 of a match
+func (e *lzmaEncoder) distancePrice(distance, length int) int {
+	lengthState := min(length-2, 3)
+	if distance < lzmaFullDistance {
+		return e.prices.distance[lengthState][distance]
+	}
+	return e.prices.slot[lengthState][distanceSlot(distance)] + e.prices.align[distance&0x0f]
+}
+
+// distanceSlot returns the slot of the distance-1 of a match
+func distanceSlot(distance int) int {
+	if distance < 4 {
+		return distance
+	}
+	n := bits.Len(uint(distance)) - 1
+	return 2*n + (distance>>(n-1))&1
+}
+
+// updatePrices updates the prices of the lengths and the distances
+func (e *lzmaEncoder) updatePrices() {
+	prices := &e.prices
+	for posState := 0; posState < lzmaPosStates; posState++ {
+		for length := range prices.length[posState] {
+			prices.length[posState][length] = e.length.price(length, posState)
+			prices.repLength[posState][length] = e.repLength.price(length, posState)
+		}
+	}
+	for lengthState := range e.posSlot {
+		for slot := range prices.slot[lengthState] {
+			price := bitTreePrice(e.posSlot[lengthState][:], 6, uint32(slot))
+			if slot >= lzmaEndPosModel {
+				// The direct bits of the distance, the lowest four bits are priced separately
+				price += (slot>>1 - 5) * lzmaBitPrice
+			}
+			prices.slot[lengthState][slot] = price
+		}
+		for distance := range prices.distance[lengthState] {
+			slot := distanceSlot(distance)
+			price := prices.slot[lengthState][slot]
+			if slot >= 4 {
+				footerBits := uint(slot>>1) - 1
+				base := (2 | slot&1) << footerBits
+				price += reverseBitTreePrice(e.posSpecial[base-slot:], footerBits, uint32(distance-base))
+			}
+			prices.distance[lengthState][distance] = price
+		}
+	}
+	for i := range prices.align {
+		prices.align[i] = reverseBitTreePrice(e.posAlign[:], 4, uint32(i))
+	}
+	e.pricesAge = 0
+}
+
+// lzmaBitPrices are the prices of a bit in the 1/lzmaBitPrice units of
+// a bit, indexed by the probability of the bit divided by 16
+var lzmaBitPrices = func() (prices [128]int) {
+	for i := range prices {
+		prices[i] = int(math.Round(-math.Log2((float64(i)+0.5)/128) * lzmaBitPrice))
+	}
+	return prices
+}()
+
+// bitPrice returns the price of encoding the bit with the probability
+func bitPrice(probability uint16, bit uint32) int {
+	if bit == 0 {
+		return lzmaBitPrices[probability>>4]
+	}
+	return lzmaBitPrices[(2048-probability)>>4]
+}
+
+// bitTreePrice returns the price of encoding the bits of the value from the highest bit
+func bitTreePrice(probabilities []uint16, count uint, value uint32) int {
+	price, m := 0, uint32(1)
+	for count > 0 {
+		count--
+		bit := (value >> count) & 1
+		price += bitPrice(probabilities[m], bit)
+		m = m<<1 | bit
+	}
+	return price
+}
+
+// reverseBitTreePrice returns the price of encoding the bits of the value from the lowest bit
+func reverseBitTreePrice(probabilities []uint16, count uint, value uint32) int {
+	price, m := 0, uint32(1)
+	for ; count > 0; count-- {
+		bit := value & 1
+		value >>= 1
+		price += bitPrice(probabilities[m], bit)
+		m = m<<1 | bit
+	}
+	return price
+}
+
+// lengthEncoder encodes the length-2 of a match
+type lengthEncoder struct {
+	choice [2]uint16
+	low    [lzmaPosStates][1 << 3]uint16
+	mid    [lzmaPosStates][1 << 3]uint16
+	high   [1 << 8]uint16
+}
+
+// encode encodes the length-2 of a match
+func (l *lengthEncoder) encode(rc *rangeEncoder, length, posState int) {
+	switch {
+	case length < 8:
+		rc.encodeBit(&l.choice[0], 0)
+		rc.encodeBitTree(l.low[posState][:], 3, uint32(length))
+	case length < 16:
+		rc.encodeBit(&l.choice[0], 1)
+		rc.encodeBit(&l.choice[1], 0)
+		rc.encodeBitTree(l.mid[posState][:], 3, uint32(length-8))
+	default:
+		rc.encodeBit(&l.choice[0], 1)
+		rc.encodeBit(&l.choice[1], 1)
+		rc.encodeBitTree(l.high[:], 8, uint32(length-16))
+	}
+}
+
+// price returns the price of encoding the length-2 of a match
+func (l *lengthEncoder) price(length, posState int) int {
+	switch {
+	case length < 8:
+		return bitPrice(l.choice[0], 0) + bitTreePrice(l.low[posState][:], 3, uint32(length))
+	case length < 16:
+		return bitPrice(l.choice[0], 1) + bitPrice(l.choice[1], 0) + bitTreePrice(l.mid[posState][:], 3, uint32(length-8))
+	default:
+		return bitPrice(l.choice[0], 1) + bitPrice(l.choice[1], 1) + bitTreePrice(l.high[:], 8, uint32(length-16))
+	}
+}
+
+// rangeEncoder is the binary range encoder of lzma
+type rangeEncoder struct {
+	out       []byte
+	low       uint64
+	rng       uint32
+	cache     byte
+	cacheSize int
+}
+
+// pending returns the maximum size of the compressed data after the flush
+func (rc *rangeEncoder) pending() int {
+	return len(rc.out) + rc.cacheSize + 5
+}
+
+// encodeBit encodes a bit with the adaptive probability
+func (rc *rangeEncoder) encodeBit(probability *uint16, bit uint32) {
+	bound := (rc.rng >> 11) * uint32(*probability)
+	if bit == 0 {
+		rc.rng = bound
+		*probability += (2048 - *probability) >> 5
+	} else {
+		rc.low += uint64(bound)
+		rc.rng -= bound
+		*probability -= *probability >> 5
+	}
+	rc.normalize()
+}
+
+// encodeDirectBits encodes the bits with a fixed probability of one half
+func (rc *rangeEncoder) encodeDirectBits(value uint32, count uint) {
+	for count > 0 {
+		count--
+		rc.rng >>= 1
+		if (value>>count)&1 == 1 {
+			rc.low += uint64(rc.rng)
+		}
+		rc.normalize()
+	}
+}
+
+// encodeBitTree encodes the bits of the value from the highest bit
+func (rc *rangeEncoder) encodeBitTree(probabilities []uint16, count uint, value uint32) {
+	m := uint32(1)
+	for count > 0 {
+		count--
+		bit := (value >> count) & 1
+		rc.encodeBit(&probabilities[m], bit)
+		m = m<<1 | bit
+	}
+}
+
+// encodeReverseBitTree encodes the bits of the value from the lowest bit
+func (rc *rangeEncoder) encodeReverseBitTree(probabilities []uint16, count uint, value uint32) {
+	m := uint32(1)
+	for ; count > 0; count-- {
+		bit := value & 1
+		value >>= 1
+		rc.encodeBit(&probabilities[m], bit)
+		m = m<<1 | bit
+	}
+}
+
+// normalize shifts out the settled bytes while the range is too small
+func (rc *rangeEncoder) normalize() {
+	for rc.rng < 1<<24 {
+		rc.rng <<= 8
+		rc.shiftLow()
+	}
+}
+
+// shiftLow outputs the top byte of low, the bytes are held back
+// in the cache till a carry can no longer change them
+func (rc *rangeEncoder) shiftLow() {
+	if uint32(rc.low) < 0xFF000000 || rc.low>>32 != 0 {
+		carry := byte(rc.low >> 32)
+		temp := rc.cache
+		for ; rc.cacheSize > 0; rc.cacheSize-- {
+			rc.out = append(rc.out, temp+carry)
+			temp = 0xFF
+		}
+		rc.cache = byte(rc.low >> 24)
+	}
+	rc.cacheSize++
+	rc.low = (rc.low & 0x00FFFFFF) << 8
+}
+
+// flush outputs the remaining bytes and returns the compressed data
+func (rc *rangeEncoder) flush() []byte {
+	for i := 0; i < 5; i++ {
+		rc.shiftLow()
+	}
+	return rc.out
+}